@@ -0,0 +1,75 @@
+// Command archive-mock serves archive contract (GET /car_information?regNum=X) for
+// offline development and end-to-end tests of /new. Cars come from fixture file,
+// other regNums get generated cars unless --generate=false
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"catalog/internal/lib/archive/archivetest"
+	"catalog/internal/lib/logger/sl"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("archive-mock", flag.ContinueOnError)
+	address := fs.String("address", "localhost:8080", "address to listen on")
+	fixture := fs.String("fixture", "", "JSON file with array of archive cars")
+	var opts archivetest.Options
+	fs.BoolVar(&opts.Generate, "generate", true, "generate cars for regNums missing in fixture")
+	fs.Int64Var(&opts.Seed, "seed", 1, "seed of generated cars and random failures")
+	fs.DurationVar(&opts.Latency, "latency", 0, "delay of every answer")
+	fs.DurationVar(&opts.Jitter, "jitter", 0, "random extra delay up to this value")
+	fs.Float64Var(&opts.ErrorRate, "error-rate", 0, "share of requests answered with 500, 0..1")
+	fs.Float64Var(&opts.NotFoundRate, "not-found-rate", 0, "share of requests answered with 404, 0..1")
+	notFound := fs.String("not-found", "", "comma separated regNums always answered with 404")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if opts.ErrorRate < 0 || opts.ErrorRate > 1 || opts.NotFoundRate < 0 || opts.NotFoundRate > 1 {
+		return errors.New("rates must be between 0 and 1")
+	}
+	if *fixture != "" {
+		cars, err := archivetest.LoadCars(*fixture)
+		if err != nil {
+			return err
+		}
+		opts.Cars = cars
+	}
+	opts.NotFound = make(map[string]bool)
+	for _, regNum := range strings.Split(*notFound, ",") {
+		if regNum = strings.TrimSpace(regNum); regNum != "" {
+			opts.NotFound[regNum] = true
+		}
+	}
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	srv := archivetest.NewServer(opts)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Debug("request", slog.String("method", r.Method), slog.String("url", r.URL.String()))
+		srv.ServeHTTP(w, r)
+	})
+
+	log.Info("starting archive mock", slog.String("address", *address),
+		slog.Int("fixture_cars", len(opts.Cars)), slog.Bool("generate", opts.Generate))
+	if err := http.ListenAndServe(*address, handler); err != nil {
+		log.Error("server stopped", sl.Err(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,670 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"catalog/internal/config"
+	"catalog/internal/http-handlers/apikeys"
+	"catalog/internal/http-handlers/cars"
+	"catalog/internal/http-handlers/health"
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/http-handlers/middleware/ratelimit"
+	"catalog/internal/lib/archive"
+	"catalog/internal/lib/archive/archivetest"
+	"catalog/internal/lib/blob"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/changefeed"
+	"catalog/internal/storage/entities"
+	"catalog/internal/storage/storagetest"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// End-to-end tests of API: real router and PostgreSQL, archive mock. Every test works in
+// rolled back transaction, so tenants and car numbers can repeat between tests
+
+const testJWTSecret = "test-secret"
+
+func TestMain(m *testing.M) {
+	os.Exit(storagetest.Main(m))
+}
+
+// testAPI is catalog server with its storage
+type testAPI struct {
+	t       *testing.T
+	url     string
+	storage *postgres.Storage
+	log     *slog.Logger
+	cfg     *config.Config
+	svc     services
+	// blobDir keeps blobs of attachments
+	blobDir string
+}
+
+func newTestAPI(t *testing.T, archiveOpts archivetest.Options) *testAPI {
+	t.Helper()
+
+	storage := storagetest.New(t)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	archiveSrv := httptest.NewServer(archivetest.NewServer(archiveOpts))
+	t.Cleanup(archiveSrv.Close)
+
+	verifier, err := auth.NewVerifier(testJWTSecret, "", "", "")
+	if err != nil {
+		t.Fatalf("failed to make verifier: %v", err)
+	}
+
+	blobDir := t.TempDir()
+	blobs, err := blob.NewLocal(blobDir)
+	if err != nil {
+		t.Fatalf("failed to make blob storage: %v", err)
+	}
+
+	// Zero rate limits are disabled
+	cfg := &config.Config{
+		AttachmentsMaxSize:      testMaxAttachmentSize,
+		ServiceIntervalDistance: testServiceDistance,
+		ServiceIntervalTime:     testServiceTime,
+	}
+	svc := services{
+		storage:     storage,
+		archive:     archive.New(archiveSrv.URL, 5*time.Second),
+		feed:        changefeed.New(log, storage, ""),
+		blobs:       blobs,
+		verifier:    verifier,
+		limiter:     ratelimit.NewMemory(),
+		readyChecks: []health.Check{{Name: "database", Fn: storage.DB.PingContext}},
+	}
+	srv := httptest.NewServer(newRouter(log, cfg, svc))
+	t.Cleanup(srv.Close)
+
+	return &testAPI{t: t, url: srv.URL, storage: storage, log: log, cfg: cfg, svc: svc, blobDir: blobDir}
+}
+
+// token returns JWT of principal bound to tenant
+func (a *testAPI) token(role, tenantID string, regions ...string) string {
+	a.t.Helper()
+
+	claims := auth.Claims{
+		Role:    role,
+		Regions: regions,
+		Tenant:  tenantID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   role + "@" + tenantID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		a.t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+// do sends request with bearer token and JSON body if they are set, answer is decoded into out
+func (a *testAPI) do(method, path, token string, body, out any) int {
+	a.t.Helper()
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			a.t.Fatalf("failed to code request: %v", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, a.url+path, reqBody)
+	if err != nil {
+		a.t.Fatalf("failed to make request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			a.t.Fatalf("%s %s: failed to decode answer: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+type catalogAnswer struct {
+	Cars       []entities.Car
+	Pagination entities.Pagination
+}
+
+// catalog returns regNums of catalog page for query
+func (a *testAPI) catalog(token, query string) (int, []string, entities.Pagination) {
+	a.t.Helper()
+
+	var ans catalogAnswer
+	status := a.do(http.MethodGet, "/catalog?"+query, token, nil, &ans)
+	regNums := []string{}
+	for _, c := range ans.Cars {
+		regNums = append(regNums, c.RegNum)
+	}
+	return status, regNums, ans.Pagination
+}
+
+// addCars adds cars to tenant in order, so later cars come first in catalog
+func (a *testAPI) addCars(tenantID string, cars ...entities.Car) map[string]int {
+	a.t.Helper()
+
+	ctx := context.Background()
+	scope := entities.Scope{TenantID: tenantID}
+	for i := range cars {
+		if err := cars[i].New(ctx, a.storage, scope); err != nil {
+			a.t.Fatalf("failed to add car %s: %v", cars[i].RegNum, err)
+		}
+	}
+
+	ids := make(map[string]int)
+	for page := 1; ; page++ {
+		var cp entities.CatalogPage
+		if err := cp.GetCatalogPage(ctx, a.storage, &entities.Car{Status: entities.StatusAny}, page, scope); err != nil {
+			a.t.Fatalf("failed to get car ids: %v", err)
+		}
+		for _, c := range cp.Cars {
+			ids[c.RegNum] = c.CarID
+		}
+		if cp.Pagination.Next == 0 {
+			return ids
+		}
+	}
+}
+
+var (
+	ivan = entities.Person{Name: "Ivan", Surname: "Petrov", Patronymic: "Sergeevich"}
+	anna = entities.Person{Name: "Anna", Surname: "Ivanova", Patronymic: "Petrovna"}
+
+	testCars = []entities.Car{
+		{RegNum: "C003CC16", Mark: "Kia", Model: "Rio", Year: 2018, Region: "tatarstan", Owner: ivan},
+		{RegNum: "B002BB77", Mark: "Lada", Model: "Granta", Year: 2018, Region: "moscow", Owner: anna},
+		{RegNum: "A001AA77", Mark: "Lada", Model: "Vesta", Year: 2020, Region: "moscow", Owner: ivan},
+	}
+)
+
+func TestCatalogFilters(t *testing.T) {
+	api := newTestAPI(t, archivetest.Options{})
+	ids := api.addCars("depot", testCars...)
+	api.addCars("other", entities.Car{RegNum: "A001AA77", Mark: "Lada", Model: "Vesta", Year: 2020, Owner: ivan})
+
+	viewer := api.token(auth.RoleViewer, "depot")
+	moscowViewer := api.token(auth.RoleViewer, "depot", "moscow")
+
+	tests := []struct {
+		name       string
+		token      string
+		query      string
+		wantStatus int
+		want       []string
+	}{
+		{name: "no filter", token: viewer, want: []string{"A001AA77", "B002BB77"}},
+		{name: "second page", token: viewer, query: "page=2", want: []string{"C003CC16"}},
+		{name: "page out of range", token: viewer, query: "page=3", wantStatus: 400},
+		{name: "negative page", token: viewer, query: "page=-1", wantStatus: 400},
+		{name: "invalid page", token: viewer, query: "page=x", wantStatus: 400},
+		{name: "car id", token: viewer, query: "carId=" + strconv.Itoa(ids["B002BB77"]), want: []string{"B002BB77"}},
+		{name: "invalid car id", token: viewer, query: "carId=x", wantStatus: 400},
+		{name: "reg num", token: viewer, query: "regNum=C003CC16", want: []string{"C003CC16"}},
+		{name: "mark", token: viewer, query: "mark=Lada", want: []string{"A001AA77", "B002BB77"}},
+		{name: "model", token: viewer, query: "model=Rio", want: []string{"C003CC16"}},
+		{name: "year", token: viewer, query: "year=2018", want: []string{"B002BB77", "C003CC16"}},
+		{name: "invalid year", token: viewer, query: "year=x", wantStatus: 400},
+		{name: "region", token: viewer, query: "region=tatarstan", want: []string{"C003CC16"}},
+		{name: "owner", token: viewer, query: "name=Ivan&surname=Petrov&patronymic=Sergeevich",
+			want: []string{"A001AA77", "C003CC16"}},
+		{name: "owner and car fields", token: viewer, query: "name=Ivan&surname=Petrov&patronymic=Sergeevich&mark=Lada",
+			want: []string{"A001AA77"}},
+		{name: "car id after owner", token: viewer,
+			query: "name=Ivan&surname=Petrov&patronymic=Sergeevich&carId=" + strconv.Itoa(ids["C003CC16"]),
+			want:  []string{"C003CC16"}},
+		{name: "all car fields", token: viewer, query: "mark=Lada&model=Granta&year=2018&region=moscow&regNum=B002BB77",
+			want: []string{"B002BB77"}},
+		{name: "nothing found", token: viewer, query: "mark=Volga", want: []string{}},
+		{name: "region scope", token: moscowViewer, query: "year=2018", want: []string{"B002BB77"}},
+		{name: "car outside of scope", token: moscowViewer, query: "regNum=C003CC16", want: []string{}},
+		{name: "no token", query: "", wantStatus: 401},
+		{name: "foreign tenant", token: api.token(auth.RoleViewer, "other"), query: "mark=Kia", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantStatus == 0 {
+				tt.wantStatus = 200
+			}
+			status, got, _ := api.catalog(tt.token, tt.query)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if tt.wantStatus == 200 && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("cars = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	api := newTestAPI(t, archivetest.Options{})
+	api.addCars("depot", testCars...)
+	viewer := api.token(auth.RoleViewer, "depot")
+	moscowViewer := api.token(auth.RoleViewer, "depot", "moscow")
+
+	var counts entities.CarCounts
+	if status := api.do(http.MethodGet, "/stats/counts?by=mark&year=2018", viewer, nil, &counts); status != 200 {
+		t.Fatalf("counts status = %d, want 200", status)
+	}
+	wantCounts := []entities.CarCount{
+		{Group: entities.StatsGroup{"mark": "Kia"}, Count: 1},
+		{Group: entities.StatsGroup{"mark": "Lada"}, Count: 1},
+	}
+	if !reflect.DeepEqual(counts.Counts, wantCounts) {
+		t.Fatalf("counts = %+v, want %+v", counts.Counts, wantCounts)
+	}
+
+	// Stats see cars of caller scope only
+	var owners entities.TopOwners
+	if status := api.do(http.MethodGet, "/stats/owners?minCars=1&limit=1", moscowViewer, nil, &owners); status != 200 {
+		t.Fatalf("owners status = %d, want 200", status)
+	}
+	if len(owners.Owners) != 1 || owners.Owners[0].Cars != 1 {
+		t.Fatalf("owners = %+v, want one owner of one car", owners.Owners)
+	}
+
+	var growth entities.Growth
+	if status := api.do(http.MethodGet, "/stats/growth?interval=year", viewer, nil, &growth); status != 200 {
+		t.Fatalf("growth status = %d, want 200", status)
+	}
+	if len(growth.Points) != 1 || growth.Points[0].Total != len(testCars) {
+		t.Fatalf("growth = %+v, want all cars added this year", growth.Points)
+	}
+
+	var ages entities.AgeStats
+	if status := api.do(http.MethodGet, "/stats/ages?by=region&bucket=10", viewer, nil, &ages); status != 200 {
+		t.Fatalf("ages status = %d, want 200", status)
+	}
+	if len(ages.Ages) != 2 || ages.Ages[0].Group["region"] != "moscow" || ages.Ages[0].Count != 2 {
+		t.Fatalf("ages = %+v, want moscow with 2 cars first", ages.Ages)
+	}
+
+	for _, query := range []string{
+		"/stats/counts?by=owner",
+		"/stats/ages?bucket=0",
+		"/stats/owners?limit=1000",
+		"/stats/growth?interval=day",
+		"/stats/counts?year=x",
+	} {
+		if status := api.do(http.MethodGet, query, viewer, nil, nil); status != 400 {
+			t.Fatalf("%s: status = %d, want 400", query, status)
+		}
+	}
+}
+
+func TestCatalogPagination(t *testing.T) {
+	api := newTestAPI(t, archivetest.Options{})
+	api.addCars("depot", testCars...)
+	viewer := api.token(auth.RoleViewer, "depot")
+
+	_, _, p := api.catalog(viewer, "")
+	if want := (entities.Pagination{Next: 2, RecordPerPage: 2, CurrentPage: 1, TotalPage: 2}); p != want {
+		t.Fatalf("first page = %+v, want %+v", p, want)
+	}
+	_, _, p = api.catalog(viewer, "page=2")
+	if want := (entities.Pagination{Previous: 1, RecordPerPage: 2, CurrentPage: 2, TotalPage: 2}); p != want {
+		t.Fatalf("last page = %+v, want %+v", p, want)
+	}
+	status, _, p := api.catalog(viewer, "mark=Volga")
+	if want := (entities.Pagination{RecordPerPage: 2, CurrentPage: 1}); status != 200 || p != want {
+		t.Fatalf("empty page = %d %+v, want %+v", status, p, want)
+	}
+}
+
+func TestCatalogFacets(t *testing.T) {
+	api := newTestAPI(t, archivetest.Options{})
+	api.addCars("depot", testCars...)
+	api.addCars("other", entities.Car{RegNum: "A001AA77", Mark: "Lada", Model: "Vesta", Year: 2020, Owner: ivan})
+	viewer := api.token(auth.RoleViewer, "depot")
+
+	var ans struct{ Facets entities.Facets }
+	if status := api.do(http.MethodGet, "/catalog?mark=Lada&facets=mark,model&facets=year,mark", viewer, nil, &ans); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	// Faceted field itself is not filtered
+	want := entities.Facets{
+		"mark":  {{Value: "Lada", Count: 2}, {Value: "Kia", Count: 1}},
+		"model": {{Value: "Granta", Count: 1}, {Value: "Vesta", Count: 1}},
+		"year":  {{Value: "2018", Count: 1}, {Value: "2020", Count: 1}},
+	}
+	if !reflect.DeepEqual(ans.Facets, want) {
+		t.Fatalf("facets = %+v, want %+v", ans.Facets, want)
+	}
+
+	ans.Facets = nil
+	if status := api.do(http.MethodGet, "/catalog", viewer, nil, &ans); status != 200 || ans.Facets != nil {
+		t.Fatalf("without facets = %d %+v, want 200 and no facets", status, ans.Facets)
+	}
+	if status := api.do(http.MethodGet, "/catalog?facets=owner", viewer, nil, nil); status != 400 {
+		t.Fatalf("unknown facet status = %d, want 400", status)
+	}
+}
+
+func TestCarByVIN(t *testing.T) {
+	api := newTestAPI(t, archivetest.Options{})
+	ids := api.addCars("depot",
+		entities.Car{RegNum: "K001KK16", Mark: "Lada", Model: "2109", Year: 1992, Region: "tatarstan",
+			VIN: "XTA21099XN1234567", Owner: ivan},
+		entities.Car{RegNum: "K002KK77", Mark: "Kia", Model: "Rio", Year: 2012, Region: "moscow",
+			VIN: "WBA3A5C53CF256985", Owner: anna},
+	)
+	viewer := api.token(auth.RoleViewer, "depot")
+	editor := api.token(auth.RoleEditor, "depot")
+
+	var ans cars.VINResponse
+	if status := api.do(http.MethodGet, "/cars/by-vin/xta21099xn1234567", viewer, nil, &ans); status != 200 {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if ans.Car.RegNum != "K001KK16" || ans.Decoded.Manufacturer != "AvtoVAZ" || ans.Decoded.ModelYear != 1992 ||
+		len(ans.Mismatches) != 0 {
+		t.Fatalf("answer = %+v, want K001KK16 of AvtoVAZ 1992 without mismatches", ans)
+	}
+	if api.do(http.MethodGet, "/cars/by-vin/WBA3A5C53CF256985", viewer, nil, &ans); !reflect.DeepEqual(ans.Mismatches, []string{"mark"}) {
+		t.Fatalf("mismatches = %v, want [mark]", ans.Mismatches)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		vin        string
+		wantStatus int
+	}{
+		{name: "check digit", token: viewer, vin: "XTA21099XN1234568", wantStatus: 400},
+		{name: "short", token: viewer, vin: "XTA21099XN123456", wantStatus: 400},
+		{name: "unknown", token: viewer, vin: "1M8GDM9AXKP042788", wantStatus: 404},
+		{name: "out of scope", token: api.token(auth.RoleViewer, "depot", "tatarstan"), vin: "WBA3A5C53CF256985",
+			wantStatus: 404},
+		{name: "other tenant", token: api.token(auth.RoleViewer, "other"), vin: "XTA21099XN1234567", wantStatus: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := api.do(http.MethodGet, "/cars/by-vin/"+tt.vin, tt.token, nil, nil); status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+
+	if _, regNums, _ := api.catalog(viewer, "vin=WBA3A5C53CF256985"); !reflect.DeepEqual(regNums, []string{"K002KK77"}) {
+		t.Fatalf("filtered by VIN = %v, want [K002KK77]", regNums)
+	}
+
+	edit := map[string]any{"carId": ids["K002KK77"], "vin": "XTA21099XN1234567"}
+	if status := api.do(http.MethodPost, "/edit", editor, edit, nil); status != 409 {
+		t.Fatalf("duplicate VIN status = %d, want 409", status)
+	}
+	edit["vin"] = "XTA21099XN1234568"
+	if status := api.do(http.MethodPost, "/edit", editor, edit, nil); status != 400 {
+		t.Fatalf("invalid VIN status = %d, want 400", status)
+	}
+}
+
+func TestNew(t *testing.T) {
+	fixture := archive.Car{RegNum: "X123XX16", Mark: "Kia", Model: "Rio", Year: 2015,
+		Owner: archive.Person{Name: "Oleg", Surname: "Orlov", Patronymic: "Olegovich"}}
+	api := newTestAPI(t, archivetest.Options{
+		Cars:     map[string]archive.Car{fixture.RegNum: fixture},
+		Generate: true,
+		NotFound: map[string]bool{"M000MM00": true},
+	})
+	editor := api.token(auth.RoleEditor, "depot")
+	tatarstanEditor := api.token(auth.RoleEditor, "depot", "tatarstan")
+
+	tests := []struct {
+		name       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{name: "fixture car", token: editor, body: map[string]string{"regNum": fixture.RegNum}, wantStatus: 200},
+		{name: "generated car", token: editor, body: map[string]string{"regNum": "E555EE77", "region": "moscow"},
+			wantStatus: 200},
+		{name: "unknown car", token: editor, body: map[string]string{"regNum": "M000MM00"}, wantStatus: 404},
+		{name: "no reg num", token: editor, body: map[string]string{}, wantStatus: 400},
+		{name: "empty body", token: editor, wantStatus: 400},
+		{name: "viewer", token: api.token(auth.RoleViewer, "depot"), body: map[string]string{"regNum": "E556EE77"},
+			wantStatus: 403},
+		{name: "region outside of scope", token: tatarstanEditor,
+			body: map[string]string{"regNum": "E557EE77", "region": "moscow"}, wantStatus: 403},
+		{name: "the only region of scope", token: tatarstanEditor, body: map[string]string{"regNum": "E558EE16"},
+			wantStatus: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := api.do(http.MethodPost, "/new", tt.token, tt.body, nil); status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+
+	var ans catalogAnswer
+	api.do(http.MethodGet, "/catalog?regNum="+fixture.RegNum, editor, nil, &ans)
+	if len(ans.Cars) != 1 {
+		t.Fatalf("added car is not found: %+v", ans)
+	}
+	got := ans.Cars[0]
+	if got.Mark != fixture.Mark || got.Model != fixture.Model || got.Year != fixture.Year ||
+		got.Owner.Name != fixture.Owner.Name || got.Owner.Surname != fixture.Owner.Surname {
+		t.Fatalf("added car = %+v, want %+v", got, fixture)
+	}
+
+	if _, regNums, _ := api.catalog(editor, "regNum=E558EE16"); len(regNums) != 1 {
+		t.Fatal("car added with the only region of scope is not found")
+	}
+	if _, regNums, _ := api.catalog(editor, "region=tatarstan"); !reflect.DeepEqual(regNums, []string{"E558EE16"}) {
+		t.Fatalf("cars of tatarstan = %v", regNums)
+	}
+}
+
+func TestNewArchiveFailure(t *testing.T) {
+	api := newTestAPI(t, archivetest.Options{Generate: true, ErrorRate: 1})
+
+	status := api.do(http.MethodPost, "/new", api.token(auth.RoleEditor, "depot"),
+		map[string]string{"regNum": "E555EE77"}, nil)
+	if status != 502 {
+		t.Fatalf("status = %d, want 502", status)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	api := newTestAPI(t, archivetest.Options{})
+	ids := api.addCars("depot", testCars...)
+	foreign := api.addCars("other", entities.Car{RegNum: "O001OO77", Mark: "Lada", Model: "Vesta", Owner: ivan})
+	editor := api.token(auth.RoleEditor, "depot")
+	moscowEditor := api.token(auth.RoleEditor, "depot", "moscow")
+
+	tests := []struct {
+		name       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{name: "model", token: editor, body: map[string]any{"carId": ids["A001AA77"], "model": "Largus", "year": 2021},
+			wantStatus: 200},
+		{name: "owner only", token: editor,
+			body: map[string]any{"carId": ids["B002BB77"], "owner": map[string]string{"name": "Ivan",
+				"surname": "Petrov", "patronymic": "Sergeevich"}}, wantStatus: 200},
+		{name: "nothing to edit", token: editor, body: map[string]any{"carId": ids["A001AA77"]}, wantStatus: 400},
+		{name: "no car id", token: editor, body: map[string]any{"model": "Largus"}, wantStatus: 400},
+		{name: "missing car", token: editor, body: map[string]any{"carId": 1 << 30, "model": "Largus"}, wantStatus: 404},
+		{name: "foreign car", token: editor, body: map[string]any{"carId": foreign["O001OO77"], "model": "Largus"},
+			wantStatus: 404},
+		{name: "car outside of scope", token: moscowEditor, body: map[string]any{"carId": ids["C003CC16"], "model": "Ceed"},
+			wantStatus: 404},
+		{name: "move outside of scope", token: moscowEditor,
+			body: map[string]any{"carId": ids["A001AA77"], "region": "tatarstan"}, wantStatus: 403},
+		{name: "viewer", token: api.token(auth.RoleViewer, "depot"),
+			body: map[string]any{"carId": ids["A001AA77"], "model": "Largus"}, wantStatus: 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := api.do(http.MethodPost, "/edit", tt.token, tt.body, nil); status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+
+	if _, regNums, _ := api.catalog(editor, "model=Largus&year=2021"); !reflect.DeepEqual(regNums, []string{"A001AA77"}) {
+		t.Fatalf("edited cars = %v", regNums)
+	}
+	_, regNums, _ := api.catalog(editor, "name=Ivan&surname=Petrov&patronymic=Sergeevich")
+	if want := []string{"A001AA77", "B002BB77"}; !reflect.DeepEqual(regNums, want) {
+		t.Fatalf("cars of new owner on the first page = %v, want %v", regNums, want)
+	}
+	if _, regNums, _ := api.catalog(editor, "regNum=C003CC16&model=Rio"); len(regNums) != 1 {
+		t.Fatal("car outside of scope was changed")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	api := newTestAPI(t, archivetest.Options{})
+	ids := api.addCars("depot", testCars...)
+	editor := api.token(auth.RoleEditor, "depot")
+	moscowEditor := api.token(auth.RoleEditor, "depot", "moscow")
+
+	tests := []struct {
+		name       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{name: "car", token: editor, body: map[string]int{"carId": ids["A001AA77"]}, wantStatus: 200},
+		{name: "deleted car", token: editor, body: map[string]int{"carId": ids["A001AA77"]}, wantStatus: 404},
+		{name: "no car id", token: editor, body: map[string]int{}, wantStatus: 400},
+		{name: "car outside of scope", token: moscowEditor, body: map[string]int{"carId": ids["C003CC16"]},
+			wantStatus: 404},
+		{name: "foreign tenant", token: api.token(auth.RoleEditor, "other"), body: map[string]int{"carId": ids["B002BB77"]},
+			wantStatus: 404},
+		{name: "viewer", token: api.token(auth.RoleViewer, "depot"), body: map[string]int{"carId": ids["B002BB77"]},
+			wantStatus: 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := api.do(http.MethodPost, "/delete", tt.token, tt.body, nil); status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+
+	if _, regNums, _ := api.catalog(editor, ""); !reflect.DeepEqual(regNums, []string{"B002BB77", "C003CC16"}) {
+		t.Fatalf("cars left = %v", regNums)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	api := newTestAPI(t, archivetest.Options{})
+	api.addCars("depot", testCars...)
+	admin := api.token(auth.RoleAdmin, "depot")
+
+	if status := api.do(http.MethodPost, "/api-keys", api.token(auth.RoleEditor, "depot"),
+		map[string]any{"name": "ci", "role": "viewer"}, nil); status != 403 {
+		t.Fatalf("editor created key: status = %d, want 403", status)
+	}
+	if status := api.do(http.MethodPost, "/api-keys", admin, map[string]any{"name": "ci", "role": "root"}, nil); status != 400 {
+		t.Fatalf("key with unknown role: status = %d, want 400", status)
+	}
+
+	var created apikeys.Response
+	status := api.do(http.MethodPost, "/api-keys", admin,
+		map[string]any{"name": "ci", "role": "viewer", "regions": []string{"tatarstan"}}, &created)
+	if status != 201 || created.Key == "" {
+		t.Fatalf("failed to create key: status = %d, answer = %+v", status, created)
+	}
+
+	// Key is bound to tenant and regions of creator request
+	if _, regNums, _ := api.catalog(created.Key, ""); !reflect.DeepEqual(regNums, []string{"C003CC16"}) {
+		t.Fatalf("cars visible by key = %v", regNums)
+	}
+	if status := api.do(http.MethodPost, "/delete", created.Key, map[string]int{"carId": 1}, nil); status != 403 {
+		t.Fatalf("viewer key deleted car: status = %d, want 403", status)
+	}
+
+	var keys []entities.APIKey
+	if status := api.do(http.MethodGet, "/api-keys", admin, nil, &keys); status != 200 || len(keys) != 1 {
+		t.Fatalf("keys = %d %+v, want the created one", status, keys)
+	}
+	if status := api.do(http.MethodGet, "/api-keys", api.token(auth.RoleAdmin, "other"), nil, &keys); status != 200 || len(keys) != 0 {
+		t.Fatalf("keys of other tenant = %d %+v, want none", status, keys)
+	}
+
+	id := strconv.Itoa(created.KeyID)
+	var rotated apikeys.Response
+	if status := api.do(http.MethodPost, "/api-keys/"+id+"/rotate", admin, nil, &rotated); status != 201 {
+		t.Fatalf("failed to rotate key: status = %d", status)
+	}
+	if status, _, _ := api.catalog(created.Key, ""); status != 401 {
+		t.Fatalf("rotated key: status = %d, want 401", status)
+	}
+	if status, _, _ := api.catalog(rotated.Key, ""); status != 200 {
+		t.Fatalf("new key: status = %d, want 200", status)
+	}
+
+	id = strconv.Itoa(rotated.KeyID)
+	if status := api.do(http.MethodPost, "/api-keys/"+id+"/revoke", admin, nil, nil); status != 200 {
+		t.Fatalf("failed to revoke key: status = %d", status)
+	}
+	if status, _, _ := api.catalog(rotated.Key, ""); status != 401 {
+		t.Fatalf("revoked key: status = %d, want 401", status)
+	}
+	if status := api.do(http.MethodPost, "/api-keys/"+id+"/revoke", admin, nil, nil); status != 404 {
+		t.Fatalf("revoked key revoked again: status = %d, want 404", status)
+	}
+
+	// Admin restricted by regions can't make keys seeing more
+	tatarstanAdmin := api.token(auth.RoleAdmin, "depot", "tatarstan")
+	for _, regions := range [][]string{nil, {"moscow"}, {"tatarstan", "moscow"}} {
+		status := api.do(http.MethodPost, "/api-keys", tatarstanAdmin,
+			map[string]any{"name": "ci", "role": "viewer", "regions": regions}, nil)
+		if status != 403 {
+			t.Fatalf("key with regions %v of tatarstan admin: status = %d, want 403", regions, status)
+		}
+	}
+	if status := api.do(http.MethodPost, "/api-keys", tatarstanAdmin,
+		map[string]any{"name": "ci", "role": "viewer", "regions": []string{"tatarstan"}}, nil); status != 201 {
+		t.Fatalf("key of tatarstan admin: status = %d, want 201", status)
+	}
+	var unrestricted apikeys.Response
+	if status := api.do(http.MethodPost, "/api-keys", admin, map[string]any{"name": "ci", "role": "viewer"}, &unrestricted); status != 201 {
+		t.Fatalf("failed to create key: status = %d", status)
+	}
+	id = strconv.Itoa(unrestricted.KeyID)
+	if status := api.do(http.MethodPost, "/api-keys/"+id+"/rotate", tatarstanAdmin, nil, nil); status != 403 {
+		t.Fatalf("tatarstan admin rotated key of all regions: status = %d, want 403", status)
+	}
+}
+
+func TestProbes(t *testing.T) {
+	api := newTestAPI(t, archivetest.Options{})
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		if status := api.do(http.MethodGet, path, "", nil, nil); status != 200 {
+			t.Fatalf("%s: status = %d, want 200", path, status)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"catalog/internal/config"
+	"catalog/internal/lib/blob"
+	"catalog/internal/lib/logger/sl"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/entities"
+)
+
+// blobCleanupBatch limits keys of blobs removed at once
+const blobCleanupBatch = 100
+
+// newBlobStorage returns blob storage of attachments chosen by config
+func newBlobStorage(cfg *config.Config) (blob.Storage, error) {
+	switch cfg.AttachmentsBackend {
+	case "local":
+		return blob.NewLocal(cfg.AttachmentsDir)
+	case "s3":
+		return blob.NewS3(blob.S3Options{
+			Endpoint:  cfg.AttachmentsS3Endpoint,
+			Region:    cfg.AttachmentsS3Region,
+			Bucket:    cfg.AttachmentsS3Bucket,
+			AccessKey: cfg.AttachmentsS3AccessKey,
+			SecretKey: cfg.AttachmentsS3SecretKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown attachments backend %q", cfg.AttachmentsBackend)
+	}
+}
+
+// runBlobCleanup removes blobs of deleted attachments every interval until ctx is done.
+// Removal is idempotent, so instances may clean up concurrently
+func runBlobCleanup(ctx context.Context, log *slog.Logger, storage *postgres.Storage, blobs blob.Storage, interval time.Duration) {
+	log = log.With(slog.String("op", "attachments.cleanup"))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := cleanupBlobs(ctx, storage, blobs)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			log.Error("failed to remove blobs", slog.Int("removed", removed), sl.Err(err))
+		case removed > 0:
+			log.Info("blobs removed", slog.Int("removed", removed))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cleanupBlobs removes queued blobs batch by batch. Batch with failed removal stays
+// queued and is retried on next run
+func cleanupBlobs(ctx context.Context, storage *postgres.Storage, blobs blob.Storage) (int, error) {
+	removed := 0
+	for {
+		keys, err := entities.GetBlobGarbage(ctx, storage, blobCleanupBatch)
+		if err != nil {
+			return removed, err
+		}
+
+		for _, key := range keys {
+			if err := blobs.Delete(ctx, key); err != nil {
+				return removed, err
+			}
+		}
+		if len(keys) > 0 {
+			if err := entities.ForgetBlobs(ctx, storage, keys); err != nil {
+				return removed, err
+			}
+		}
+		removed += len(keys)
+
+		if len(keys) < blobCleanupBatch {
+			return removed, nil
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/archive/archivetest"
+	"catalog/internal/storage/entities"
+)
+
+const testMaxAttachmentSize = 64 << 10
+
+// upload sends multipart form with file and fields, answer is decoded into out
+func (a *testAPI) upload(carID int, token, name string, data []byte, fields map[string]string, out any) int {
+	a.t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	fw, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+
+	req, err := http.NewRequest(http.MethodPost, a.url+"/cars/"+strconv.Itoa(carID)+"/attachments", &body)
+	if err != nil {
+		a.t.Fatalf("failed to make request: %v", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatalf("upload: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			a.t.Fatalf("upload: failed to decode answer: %v", err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// get returns status, content type and body of GET path
+func (a *testAPI) get(path, token string) (int, string, []byte) {
+	a.t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, a.url+path, nil)
+	if err != nil {
+		a.t.Fatalf("failed to make request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		a.t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		a.t.Fatalf("GET %s: %v", path, err)
+	}
+	return resp.StatusCode, resp.Header.Get("Content-Type"), data
+}
+
+// blobCount returns count of files in blob directory
+func (a *testAPI) blobCount() int {
+	a.t.Helper()
+
+	n := 0
+	err := filepath.WalkDir(a.blobDir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return err
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return n
+}
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestAttachments(t *testing.T) {
+	api := newTestAPI(t, archivetest.Options{})
+	ids := api.addCars("depot", testCars...)
+	carID := ids["A001AA77"]
+	base := "/cars/" + strconv.Itoa(carID) + "/attachments"
+	editor := api.token(auth.RoleEditor, "depot")
+	viewer := api.token(auth.RoleViewer, "depot")
+
+	var photo, doc entities.Attachment
+	status := api.upload(carID, editor, "C:\\photos\\front.png", testPNG(t, 600, 300), map[string]string{"kind": "photo"}, &photo)
+	if status != 201 || photo.ContentType != "image/png" || !photo.HasThumbnail || photo.FileName != "front.png" {
+		t.Fatalf("photo upload = %d %+v, want 201 PNG with thumbnail", status, photo)
+	}
+	pdf := []byte("%PDF-1.4\n1 0 obj\n<<>>\nendobj\ntrailer\n<<>>\n%%EOF\n")
+	status = api.upload(carID, editor, "inspection.pdf", pdf, map[string]string{"kind": "inspection"}, &doc)
+	if status != 201 || doc.ContentType != "application/pdf" || doc.HasThumbnail {
+		t.Fatalf("document upload = %d %+v, want 201 PDF without thumbnail", status, doc)
+	}
+
+	tests := []struct {
+		name       string
+		carID      int
+		token      string
+		data       []byte
+		kind       string
+		wantStatus int
+	}{
+		{name: "text", carID: carID, token: editor, data: []byte("plain text"), wantStatus: 415},
+		{name: "disguised text", carID: carID, token: editor, data: []byte("\xff\xd8 not JPEG"), wantStatus: 415},
+		{name: "broken image", carID: carID, token: editor, data: testPNG(t, 10, 10)[:60], wantStatus: 400},
+		{name: "unknown kind", carID: carID, token: editor, data: pdf, kind: "selfie", wantStatus: 400},
+		{name: "too large", carID: carID, token: editor, data: append(pdf, make([]byte, testMaxAttachmentSize)...),
+			wantStatus: 413},
+		{name: "viewer", carID: carID, token: viewer, data: pdf, wantStatus: 403},
+		{name: "car outside of scope", carID: carID, token: api.token(auth.RoleEditor, "depot", "tatarstan"),
+			data: pdf, wantStatus: 404},
+		{name: "foreign tenant", carID: carID, token: api.token(auth.RoleEditor, "other"), data: pdf, wantStatus: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := api.upload(tt.carID, tt.token, "file", tt.data, map[string]string{"kind": tt.kind}, nil); status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+	// Failed uploads leave no blobs
+	if n := api.blobCount(); n != 3 {
+		t.Fatalf("blob count = %d, want 3", n)
+	}
+
+	var list entities.Attachments
+	if status := api.do(http.MethodGet, base, viewer, nil, &list); status != 200 || len(list) != 2 {
+		t.Fatalf("list = %d %+v, want 2 attachments", status, list)
+	}
+	if status := api.do(http.MethodGet, base, api.token(auth.RoleViewer, "depot", "tatarstan"), nil, nil); status != 404 {
+		t.Fatalf("list of car outside of scope = %d, want 404", status)
+	}
+
+	status, contentType, data := api.get(base+"/"+strconv.Itoa(doc.AttachmentID), viewer)
+	if status != 200 || contentType != "application/pdf" || !bytes.Equal(data, pdf) {
+		t.Fatalf("download = %d %s %q, want PDF", status, contentType, data)
+	}
+	status, _, data = api.get(base+"/"+strconv.Itoa(photo.AttachmentID)+"/thumbnail", viewer)
+	if cfg, err := jpeg.DecodeConfig(bytes.NewReader(data)); status != 200 || err != nil || cfg.Width != 256 || cfg.Height != 128 {
+		t.Fatalf("thumbnail = %d %+v %v, want 256x128 JPEG", status, cfg, err)
+	}
+	if status, _, _ := api.get(base+"/"+strconv.Itoa(doc.AttachmentID)+"/thumbnail", viewer); status != 404 {
+		t.Fatalf("thumbnail of document = %d, want 404", status)
+	}
+
+	if status := api.do(http.MethodDelete, base+"/"+strconv.Itoa(doc.AttachmentID), editor, nil, nil); status != 200 {
+		t.Fatalf("delete = %d, want 200", status)
+	}
+	if status := api.do(http.MethodDelete, base+"/"+strconv.Itoa(doc.AttachmentID), editor, nil, nil); status != 404 {
+		t.Fatalf("second delete = %d, want 404", status)
+	}
+	// Blobs of the rest attachments go with car
+	if status := api.do(http.MethodPost, "/delete", editor, map[string]int{"carId": carID}, nil); status != 200 {
+		t.Fatalf("car delete = %d, want 200", status)
+	}
+
+	removed, err := cleanupBlobs(context.Background(), api.storage, api.svc.blobs)
+	if err != nil || removed != 3 {
+		t.Fatalf("cleanup = %d, %v, want 3 blobs removed", removed, err)
+	}
+	if n := api.blobCount(); n != 0 {
+		t.Fatalf("blob count after cleanup = %d, want 0", n)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"catalog/internal/grpc-handlers/catalogpb"
+	"catalog/internal/http-handlers/attributes"
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/archive/archivetest"
+	"catalog/internal/storage/entities"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestAttributes(t *testing.T) {
+	api := newTestAPI(t, archivetest.Options{Generate: true})
+	admin := api.token(auth.RoleAdmin, "depot")
+	editor := api.token(auth.RoleEditor, "depot")
+	viewer := api.token(auth.RoleViewer, "depot")
+	zero := 0.0
+
+	defs := map[string]attributes.Request{
+		"color":    {Type: entities.AttributeString, Enum: []string{"red", "blue"}},
+		"mileage":  {Type: entities.AttributeInteger, Min: &zero},
+		"electric": {Type: entities.AttributeBoolean},
+	}
+	for name, def := range defs {
+		if status := api.do(http.MethodPut, "/attributes/"+name, admin, def, nil); status != 200 {
+			t.Fatalf("put %s = %d, want 200", name, status)
+		}
+	}
+
+	ids := api.addCars("depot",
+		entities.Car{RegNum: "C003CC16", Mark: "Kia", Model: "Rio", Owner: ivan,
+			Attributes: entities.Attributes{"color": "blue", "mileage": 90000.0}},
+		entities.Car{RegNum: "B002BB77", Mark: "Lada", Model: "Granta", Owner: anna,
+			Attributes: entities.Attributes{"color": "red", "mileage": 120000.0, "electric": false}},
+		entities.Car{RegNum: "A001AA77", Mark: "Lada", Model: "Vesta", Owner: ivan},
+	)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{name: "editor defines", method: http.MethodPut, path: "/attributes/wheels", token: editor,
+			body: attributes.Request{Type: entities.AttributeInteger}, wantStatus: 403},
+		{name: "invalid name", method: http.MethodPut, path: "/attributes/Wheels", token: admin,
+			body: attributes.Request{Type: entities.AttributeInteger}, wantStatus: 400},
+		{name: "invalid type", method: http.MethodPut, path: "/attributes/wheels", token: admin,
+			body: attributes.Request{Type: "date"}, wantStatus: 400},
+		{name: "new with attributes", method: http.MethodPost, path: "/new", token: editor,
+			body: map[string]any{"regNum": "E555EE77", "region": "moscow",
+				"attributes": map[string]any{"color": "red", "mileage": 10}}, wantStatus: 200},
+		{name: "new with invalid value", method: http.MethodPost, path: "/new", token: editor,
+			body: map[string]any{"regNum": "E556EE77", "attributes": map[string]any{"color": "green"}}, wantStatus: 400},
+		{name: "new with undefined attribute", method: http.MethodPost, path: "/new", token: editor,
+			body: map[string]any{"regNum": "E557EE77", "attributes": map[string]any{"wheels": 4}}, wantStatus: 400},
+		{name: "edit attributes", method: http.MethodPost, path: "/edit", token: editor, wantStatus: 200,
+			body: map[string]any{"carId": ids["A001AA77"], "attributes": map[string]any{"mileage": 5000, "electric": true}}},
+		{name: "edit deletes attribute", method: http.MethodPost, path: "/edit", token: editor,
+			body: map[string]any{"carId": ids["C003CC16"], "attributes": map[string]any{"color": nil}}, wantStatus: 200},
+		{name: "edit with invalid value", method: http.MethodPost, path: "/edit", token: editor,
+			body: map[string]any{"carId": ids["C003CC16"], "attributes": map[string]any{"mileage": -1}}, wantStatus: 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := api.do(tt.method, tt.path, tt.token, tt.body, nil); status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+
+	var schema entities.AttributeSchema
+	if status := api.do(http.MethodGet, "/attributes", viewer, nil, &schema); status != 200 || len(schema) != 3 ||
+		schema[0].Name != "color" || !reflect.DeepEqual(schema[0].Enum, []string{"red", "blue"}) {
+		t.Fatalf("schema = %d %+v", status, schema)
+	}
+
+	var ans catalogAnswer
+	api.do(http.MethodGet, "/catalog?regNum=A001AA77", viewer, nil, &ans)
+	if want := (entities.Attributes{"mileage": 5000.0, "electric": true}); len(ans.Cars) != 1 ||
+		!reflect.DeepEqual(ans.Cars[0].Attributes, want) {
+		t.Fatalf("edited car = %+v, want attributes %v", ans.Cars, want)
+	}
+
+	queries := []struct {
+		query      string
+		wantStatus int
+		want       []string
+	}{
+		{"attr.color=red", 200, []string{"E555EE77", "B002BB77"}},
+		{"attr.electric=true", 200, []string{"A001AA77"}},
+		{"attr.mileage=90000", 200, []string{"C003CC16"}},
+		{"sort=-attr.mileage", 200, []string{"B002BB77", "C003CC16", "A001AA77", "E555EE77"}},
+		{"sort=attr.mileage", 200, []string{"E555EE77", "A001AA77", "C003CC16", "B002BB77"}},
+		// Cars without attribute go last, newer first
+		{"sort=attr.electric", 200, []string{"B002BB77", "A001AA77", "E555EE77", "C003CC16"}},
+		{"attr.mileage=many", 400, []string{}},
+		{"attr.wheels=4", 400, []string{}},
+		{"sort=mark", 400, []string{}},
+	}
+	for _, q := range queries {
+		if status, regNums, _ := api.catalog(viewer, q.query); status != q.wantStatus || !reflect.DeepEqual(regNums, q.want) {
+			t.Errorf("catalog %q = %d %v, want %d %v", q.query, status, regNums, q.wantStatus, q.want)
+		}
+	}
+
+	// Stream parses attributes by schema too, it can't be sorted
+	for _, query := range []string{"attr.wheels=4", "attr.mileage=many", "sort=attr.mileage"} {
+		if status := api.do(http.MethodGet, "/cars/stream?"+query, viewer, nil, nil); status != 400 {
+			t.Errorf("stream with %s = %d, want 400", query, status)
+		}
+	}
+
+	// gRPC and GraphQL take attributes typed by JSON
+	client := catalogpb.NewCatalogServiceClient(api.grpcClient())
+	red, _ := structpb.NewStruct(map[string]any{"color": "red"})
+	rpcList, err := client.ListCars(withToken(viewer), &catalogpb.ListCarsRequest{Filter: &catalogpb.CarFilter{Attributes: red}})
+	if err != nil || len(rpcList.Cars) != 2 || rpcList.Cars[1].RegNum != "B002BB77" ||
+		rpcList.Cars[1].Attributes.AsMap()["mileage"] != 120000.0 {
+		t.Errorf("ListCars by attributes = %v, %v", rpcList, err)
+	}
+	many, _ := structpb.NewStruct(map[string]any{"mileage": "many"})
+	_, err = client.ListCars(withToken(viewer), &catalogpb.ListCarsRequest{Filter: &catalogpb.CarFilter{Attributes: many}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListCars by invalid attributes: %v, want InvalidArgument", err)
+	}
+	green, _ := structpb.NewStruct(map[string]any{"color": "green"})
+	_, err = client.CreateCar(withToken(editor), &catalogpb.CreateCarRequest{RegNum: "E558EE77", Region: "moscow", Attributes: green})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CreateCar with invalid attributes: %v, want InvalidArgument", err)
+	}
+	gqlAns := api.graphql(viewer, `{ cars(filter: {attributes: {mileage: 90000}}) { nodes { regNum attributes } } }`, nil)
+	wantNodes := map[string]any{"nodes": []any{map[string]any{"regNum": "C003CC16", "attributes": map[string]any{"mileage": 90000.0}}}}
+	if len(gqlAns.Errors) > 0 || !reflect.DeepEqual(gqlAns.Data["cars"], wantNodes) {
+		t.Errorf("cars by attributes = %+v", gqlAns)
+	}
+
+	if status := api.do(http.MethodDelete, "/attributes/color", admin, nil, nil); status != 200 {
+		t.Fatalf("delete = %d, want 200", status)
+	}
+	if status := api.do(http.MethodDelete, "/attributes/color", admin, nil, nil); status != 404 {
+		t.Fatalf("repeated delete = %d, want 404", status)
+	}
+	// Values of deleted attribute are removed from cars
+	ans = catalogAnswer{}
+	api.do(http.MethodGet, "/catalog?regNum=B002BB77", viewer, nil, &ans)
+	if want := (entities.Attributes{"mileage": 120000.0, "electric": false}); len(ans.Cars) != 1 ||
+		!reflect.DeepEqual(ans.Cars[0].Attributes, want) {
+		t.Fatalf("car = %+v, want attributes %v", ans.Cars, want)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/archive"
+	"catalog/internal/lib/archive/archivetest"
+)
+
+type graphqlAnswer struct {
+	Data   map[string]any
+	Errors []struct {
+		Message    string
+		Extensions map[string]any
+	}
+}
+
+func (a *testAPI) graphql(token, query string, variables map[string]any) graphqlAnswer {
+	a.t.Helper()
+
+	var ans graphqlAnswer
+	body := map[string]any{"query": query, "variables": variables}
+	if status := a.do(http.MethodPost, "/graphql", token, body, &ans); status != 200 {
+		a.t.Fatalf("graphql status = %d, want 200", status)
+	}
+	return ans
+}
+
+func TestGraphQL(t *testing.T) {
+	fixture := archive.Car{RegNum: "X123XX16", Mark: "Kia", Model: "Rio", Year: 2015,
+		Owner: archive.Person{Name: "Oleg", Surname: "Orlov", Patronymic: "Olegovich"}}
+	api := newTestAPI(t, archivetest.Options{Cars: map[string]archive.Car{fixture.RegNum: fixture}})
+	ids := api.addCars("depot", testCars...)
+	viewer := api.token(auth.RoleViewer, "depot")
+	editor := api.token(auth.RoleEditor, "depot")
+
+	// Owner with cars of every car in one request
+	ans := api.graphql(viewer, `query($mark: String) {
+		cars(filter: {mark: $mark}) {
+			nodes { regNum year owner { name cars { regNum } } }
+			pagination { next previous currentPage totalPage }
+		}
+	}`, map[string]any{"mark": "Lada"})
+	if len(ans.Errors) > 0 {
+		t.Fatalf("errors: %+v", ans.Errors)
+	}
+	want := map[string]any{
+		"nodes": []any{
+			map[string]any{"regNum": "A001AA77", "year": 2020.0, "owner": map[string]any{"name": "Ivan",
+				"cars": []any{map[string]any{"regNum": "A001AA77"}, map[string]any{"regNum": "C003CC16"}}}},
+			map[string]any{"regNum": "B002BB77", "year": 2018.0, "owner": map[string]any{"name": "Anna",
+				"cars": []any{map[string]any{"regNum": "B002BB77"}}}},
+		},
+		"pagination": map[string]any{"next": nil, "previous": nil, "currentPage": 1.0, "totalPage": 1.0},
+	}
+	if !reflect.DeepEqual(ans.Data["cars"], want) {
+		t.Fatalf("cars = %+v, want %+v", ans.Data["cars"], want)
+	}
+
+	ans = api.graphql(viewer, `{ cars(filter: {status: "lost"}) { nodes { status } } }`, nil)
+	if len(ans.Errors) != 1 || ans.Errors[0].Extensions["code"] != "BAD_REQUEST" {
+		t.Fatalf("unknown status: %+v", ans)
+	}
+
+	ans = api.graphql(viewer, `{ owners(surname: "petrov") { surname cars { mark } } }`, nil)
+	wantOwners := []any{map[string]any{"surname": "Petrov",
+		"cars": []any{map[string]any{"mark": "Lada"}, map[string]any{"mark": "Kia"}}}}
+	if len(ans.Errors) > 0 || !reflect.DeepEqual(ans.Data["owners"], wantOwners) {
+		t.Fatalf("owners = %+v, errors = %+v", ans.Data["owners"], ans.Errors)
+	}
+
+	edit := `mutation($id: Int!) { editCar(input: {carId: $id, model: "Largus"}) { model } }`
+	ans = api.graphql(viewer, edit, map[string]any{"id": ids["A001AA77"]})
+	if len(ans.Errors) != 1 || ans.Errors[0].Extensions["code"] != "FORBIDDEN" {
+		t.Fatalf("viewer edited car: %+v", ans)
+	}
+	ans = api.graphql(editor, edit, map[string]any{"id": ids["A001AA77"]})
+	if len(ans.Errors) > 0 || !reflect.DeepEqual(ans.Data["editCar"], map[string]any{"model": "Largus"}) {
+		t.Fatalf("editCar = %+v", ans)
+	}
+
+	ans = api.graphql(editor, `mutation { createCar(regNum: "X123XX16", region: "tatarstan") { mark status owner { surname } } }`, nil)
+	wantCreated := map[string]any{"mark": "Kia", "status": "active", "owner": map[string]any{"surname": "Orlov"}}
+	if len(ans.Errors) > 0 || !reflect.DeepEqual(ans.Data["createCar"], wantCreated) {
+		t.Fatalf("createCar = %+v", ans)
+	}
+
+	ans = api.graphql(editor, `mutation($id: Int!) { deleteCar(carId: $id) }`, map[string]any{"id": ids["B002BB77"]})
+	if len(ans.Errors) > 0 || ans.Data["deleteCar"] != true {
+		t.Fatalf("deleteCar = %+v", ans)
+	}
+	ans = api.graphql(viewer, `query($id: Int!) { car(carId: $id) { regNum } }`, map[string]any{"id": ids["B002BB77"]})
+	if len(ans.Errors) > 0 || ans.Data["car"] != nil {
+		t.Fatalf("deleted car = %+v", ans)
+	}
+
+	if status := api.do(http.MethodPost, "/graphql", viewer, map[string]any{}, nil); status != 400 {
+		t.Fatalf("request without query: status = %d, want 400", status)
+	}
+}
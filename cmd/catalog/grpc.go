@@ -0,0 +1,50 @@
+package main
+
+import (
+	"log/slog"
+
+	"catalog/internal/config"
+	grpccatalog "catalog/internal/grpc-handlers/catalog"
+	"catalog/internal/grpc-handlers/catalogpb"
+	"catalog/internal/grpc-handlers/interceptors"
+	"catalog/internal/http-handlers/middleware/ratelimit"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// newGRPCServer returns gRPC server of CatalogService with health and reflection services.
+// Health status is set by caller
+func newGRPCServer(log *slog.Logger, cfg *config.Config, s services, healthSrv *grpchealth.Server) *grpc.Server {
+	ip := interceptors.RateLimit{Group: "ip",
+		Limit: ratelimit.Limit{Rate: cfg.RateLimitIPRPS, Burst: cfg.RateLimitIPBurst}}
+	read := interceptors.RateLimit{Group: "read",
+		Limit: ratelimit.Limit{Rate: cfg.RateLimitReadRPS, Burst: cfg.RateLimitReadBurst}}
+	write := interceptors.RateLimit{Group: "write",
+		Limit: ratelimit.Limit{Rate: cfg.RateLimitWriteRPS, Burst: cfg.RateLimitWriteBurst}}
+	create := interceptors.RateLimit{Group: "new",
+		Limit: ratelimit.Limit{Rate: cfg.RateLimitNewRPS, Burst: cfg.RateLimitNewBurst}}
+
+	srv := grpc.NewServer(interceptors.Chain(
+		interceptors.Tracing(),
+		interceptors.Logger(log),
+		interceptors.RateLimitByIP(log, s.limiter, ip),
+		interceptors.Auth(log, s.storage, s.verifier, grpccatalog.Permissions),
+		interceptors.RateLimits(log, s.limiter, map[string]interceptors.RateLimit{
+			catalogpb.CatalogService_ListCars_FullMethodName:  read,
+			catalogpb.CatalogService_GetCar_FullMethodName:    read,
+			catalogpb.CatalogService_WatchCars_FullMethodName: read,
+			catalogpb.CatalogService_CreateCar_FullMethodName: create,
+			catalogpb.CatalogService_UpdateCar_FullMethodName: write,
+			catalogpb.CatalogService_DeleteCar_FullMethodName: write,
+		}),
+	)...)
+
+	catalogpb.RegisterCatalogServiceServer(srv, grpccatalog.New(log, s.storage, s.archive, s.feed))
+	healthpb.RegisterHealthServer(srv, healthSrv)
+	reflection.Register(srv)
+
+	return srv
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"catalog/internal/grpc-handlers/catalogpb"
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/archive"
+	"catalog/internal/lib/archive/archivetest"
+	"catalog/internal/storage/entities"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// grpcClient starts gRPC server of api and returns connection to it
+func (a *testAPI) grpcClient() *grpc.ClientConn {
+	a.t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		a.t.Fatalf("failed to listen: %v", err)
+	}
+	srv := newGRPCServer(a.log, a.cfg, a.svc, grpchealth.NewServer())
+	go srv.Serve(lis)
+	a.t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		a.t.Fatalf("failed to dial: %v", err)
+	}
+	a.t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestGRPC(t *testing.T) {
+	fixture := archive.Car{RegNum: "X123XX16", Mark: "Kia", Model: "Rio", Year: 2015,
+		Owner: archive.Person{Name: "Oleg", Surname: "Orlov", Patronymic: "Olegovich"}}
+	api := newTestAPI(t, archivetest.Options{Cars: map[string]archive.Car{fixture.RegNum: fixture}})
+	lastChangeID, err := entities.LastCarChangeID(context.Background(), api.storage)
+	if err != nil {
+		t.Fatalf("failed to get last change: %v", err)
+	}
+	ids := api.addCars("depot", testCars...)
+
+	conn := api.grpcClient()
+	client := catalogpb.NewCatalogServiceClient(conn)
+	viewer := withToken(api.token(auth.RoleViewer, "depot"))
+	editor := withToken(api.token(auth.RoleEditor, "depot"))
+
+	// Health and reflection don't need credentials
+	hc, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil || hc.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("health = %v, %v", hc, err)
+	}
+	if _, err := client.ListCars(context.Background(), &catalogpb.ListCarsRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("anonymous list: %v, want Unauthenticated", err)
+	}
+
+	list, err := client.ListCars(viewer, &catalogpb.ListCarsRequest{Filter: &catalogpb.CarFilter{Mark: "Lada"}})
+	if err != nil {
+		t.Fatalf("ListCars: %v", err)
+	}
+	wantList := &catalogpb.ListCarsResponse{
+		Cars: []*catalogpb.Car{
+			{CarId: int32(ids["A001AA77"]), RegNum: "A001AA77", Mark: "Lada", Model: "Vesta", Year: 2020, Region: "moscow", Status: "active",
+				Owner: &catalogpb.Person{Name: "Ivan", Surname: "Petrov", Patronymic: "Sergeevich"}},
+			{CarId: int32(ids["B002BB77"]), RegNum: "B002BB77", Mark: "Lada", Model: "Granta", Year: 2018, Region: "moscow", Status: "active",
+				Owner: &catalogpb.Person{Name: "Anna", Surname: "Ivanova", Patronymic: "Petrovna"}},
+		},
+		Pagination: &catalogpb.Pagination{RecordPerPage: 2, CurrentPage: 1, TotalPage: 1},
+	}
+	// Person ids are generated
+	for _, c := range list.Cars {
+		c.Owner.PersonId = 0
+	}
+	if !proto.Equal(list, wantList) {
+		t.Fatalf("ListCars = %v, want %v", list, wantList)
+	}
+	if _, err := client.ListCars(viewer, &catalogpb.ListCarsRequest{Filter: &catalogpb.CarFilter{Status: "lost"}}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("unknown status: %v, want InvalidArgument", err)
+	}
+	if _, err := client.ListCars(viewer, &catalogpb.ListCarsRequest{Page: 3}); status.Code(err) != codes.OutOfRange {
+		t.Fatalf("page out of range: %v, want OutOfRange", err)
+	}
+
+	update := &catalogpb.UpdateCarRequest{CarId: int32(ids["A001AA77"]), Model: "Largus"}
+	if _, err := client.UpdateCar(viewer, update); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("viewer update: %v, want PermissionDenied", err)
+	}
+	updated, err := client.UpdateCar(editor, update)
+	if err != nil || updated.Model != "Largus" || updated.Mark != "Lada" {
+		t.Fatalf("UpdateCar = %v, %v", updated, err)
+	}
+	if _, err := client.UpdateCar(editor, &catalogpb.UpdateCarRequest{CarId: update.CarId}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("empty update: %v, want InvalidArgument", err)
+	}
+
+	created, err := client.CreateCar(editor, &catalogpb.CreateCarRequest{RegNum: "X123XX16", Region: "tatarstan"})
+	if err != nil || created.Mark != "Kia" || created.Owner.Surname != "Orlov" {
+		t.Fatalf("CreateCar = %v, %v", created, err)
+	}
+	if _, err := client.CreateCar(editor, &catalogpb.CreateCarRequest{RegNum: "Y000YY00"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("unknown car: %v, want NotFound", err)
+	}
+
+	if _, err := client.DeleteCar(editor, &catalogpb.DeleteCarRequest{CarId: int32(ids["B002BB77"])}); err != nil {
+		t.Fatalf("DeleteCar: %v", err)
+	}
+	if _, err := client.GetCar(viewer, &catalogpb.GetCarRequest{CarId: int32(ids["B002BB77"])}); status.Code(err) != codes.NotFound {
+		t.Fatalf("deleted car: %v, want NotFound", err)
+	}
+
+	// Changes of Lada cars are caught up from change log
+	ctx, cancel := context.WithCancel(viewer)
+	defer cancel()
+	stream, err := client.WatchCars(ctx, &catalogpb.WatchCarsRequest{
+		Filter:       &catalogpb.CarFilter{Mark: "Lada"},
+		LastChangeId: lastChangeID,
+	})
+	if err != nil {
+		t.Fatalf("WatchCars: %v", err)
+	}
+	wantChanges := []struct {
+		op     catalogpb.CarChange_Op
+		regNum string
+	}{
+		{catalogpb.CarChange_OP_INSERT, "B002BB77"},
+		{catalogpb.CarChange_OP_INSERT, "A001AA77"},
+		{catalogpb.CarChange_OP_UPDATE, "A001AA77"},
+		{catalogpb.CarChange_OP_DELETE, "B002BB77"},
+	}
+	for _, want := range wantChanges {
+		cc, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if cc.Op != want.op || cc.Car.RegNum != want.regNum {
+			t.Fatalf("change = %v %s, want %v %s", cc.Op, cc.Car.RegNum, want.op, want.regNum)
+		}
+	}
+}
@@ -1,96 +1,257 @@
-package main
-
-import (
-	"context"
-	"log"
-	"log/slog"
-	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
-	"time"
-
-	"catalog/internal/config"
-	"catalog/internal/http-handlers/catalog"
-	delete "catalog/internal/http-handlers/delete"
-	edit "catalog/internal/http-handlers/edit"
-	"catalog/internal/http-handlers/new"
-	"catalog/internal/lib/logger/sl"
-	postgres "catalog/internal/storage"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/joho/godotenv"
-)
-
-const (
-	cfgPath = "C:/Users/Leonid/Desktop/catalog/config/config.env"
-)
-
-func main() {
-	// Loads config values from .env into the system
-	if err := godotenv.Load(cfgPath); err != nil {
-		log.Fatal("No .env file found")
-	}
-	cfg := config.MustLoad()
-
-	// Init logger
-	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-
-	storage, err := postgres.New(cfg.SQLDriver, cfg.SQLConnectionInfo)
-	if err != nil {
-		log.Error("failed to init storage", sl.Err(err))
-		os.Exit(1)
-	}
-	defer storage.DB.Close()
-	log.Info("connected to PostgreSQL server")
-
-	if err := storage.UpMigration(cfg.SQLMigrationInfo); err != nil {
-		log.Debug("failed to migrate DB", sl.Err(err))
-	}
-
-	// Use chi and help middleware
-	router := chi.NewRouter()
-	router.Use(middleware.RequestID)
-	router.Use(middleware.Logger)
-	router.Use(middleware.Recoverer)
-	router.Use(middleware.URLFormat)
-
-	router.Get("/catalog", catalog.New(log, storage))
-	router.Post("/new", new.New(log, storage))
-	router.Post("/delete", delete.New(log, storage))
-	router.Post("/edit", edit.New(log, storage))
-
-	log.Info("starting server", slog.String("address", cfg.HTTPServerAddress))
-
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-
-	srv := &http.Server{
-		Addr:    cfg.HTTPServerAddress,
-		Handler: router,
-	}
-
-	go func() {
-		if err := srv.ListenAndServe(); err != nil {
-			log.Error("failed to start server")
-		}
-	}()
-
-	log.Info("server started")
-
-	<-done
-	log.Info("stopping server")
-
-	// Ending all contexts
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Error("failed to stop server")
-
-		return
-	}
-
-	log.Info("server stopped")
-}
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"catalog/internal/config"
+	"catalog/internal/grpc-handlers/catalogpb"
+	"catalog/internal/http-handlers/health"
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/http-handlers/middleware/ratelimit"
+	"catalog/internal/lib/archive"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/metrics"
+	"catalog/internal/lib/tracing"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/changefeed"
+	"catalog/internal/storage/entities"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func main() {
+	// Config file is given by --config flag or CONFIG_PATH
+	cfg := config.MustLoad()
+	if cfg.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+	if len(cfg.Args) > 0 {
+		if cfg.Args[0] != "migrate" {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n%s\n", cfg.Args[0], migrateUsage)
+			os.Exit(2)
+		}
+		if err := runMigrate(cfg, cfg.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Init logger
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter, cfg.TracingOTLPEndpoint, cfg.TracingSampleRatio)
+	if err != nil {
+		log.Error("failed to init tracing", sl.Err(err))
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error("failed to flush spans", sl.Err(err))
+		}
+	}()
+
+	storage, err := postgres.New(cfg.SQLDriver, cfg.SQLConnectionInfo)
+	if err != nil {
+		log.Error("failed to init storage", sl.Err(err))
+		os.Exit(1)
+	}
+	defer storage.DB.Close()
+
+	// sql.Open doesn't connect, wait until DB really answers
+	err = storage.Connect(context.Background(), cfg.SQLConnectTimeout, func(err error, delay time.Duration) {
+		log.Warn("PostgreSQL server is not available, retrying", slog.Duration("delay", delay), sl.Err(err))
+	})
+	if err != nil {
+		log.Error("failed to connect to PostgreSQL server", sl.Err(err))
+		os.Exit(1)
+	}
+	log.Info("connected to PostgreSQL server")
+
+	if cfg.SQLMigrateOnStart {
+		if err := storage.UpMigration(cfg.SQLMigrationInfo); err != nil {
+			log.Error("failed to migrate DB", sl.Err(err))
+			os.Exit(1)
+		}
+	}
+	// Handlers expect the latest schema
+	if err := storage.CheckSchema(context.Background()); err != nil {
+		if !errors.Is(err, postgres.ErrSchemaOutdated) || !cfg.SQLAllowOutdatedSchema {
+			log.Error("DB schema is not usable, run migrate command", sl.Err(err))
+			os.Exit(1)
+		}
+		log.Warn("starting on outdated DB schema", sl.Err(err))
+	}
+
+	if err := metrics.RegisterDB(storage.DB); err != nil {
+		log.Error("failed to register DB metrics", sl.Err(err))
+		os.Exit(1)
+	}
+	err = metrics.RegisterBusiness(
+		func() (map[string]int, error) { return entities.CountCarsByTenant(context.Background(), storage) },
+		func() (map[string]int, error) { return entities.CountOwnersByTenant(context.Background(), storage) },
+	)
+	if err != nil {
+		log.Error("failed to register catalog metrics", sl.Err(err))
+		os.Exit(1)
+	}
+
+	archiveClient := archive.New(cfg.ArchiveURL, cfg.ArchiveTimeout)
+
+	readyChecks := []health.Check{
+		{Name: "database", Fn: storage.DB.PingContext},
+		{Name: "migrations", Fn: storage.CheckSchema},
+	}
+	switch cfg.ArchiveReadiness {
+	case "required", "optional":
+		readyChecks = append(readyChecks, health.Check{
+			Name:     "archive",
+			Optional: cfg.ArchiveReadiness == "optional",
+			Fn:       archiveClient.Ping,
+		})
+	case "ignore":
+	default:
+		log.Error("unknown archive readiness policy", slog.String("policy", cfg.ArchiveReadiness))
+		os.Exit(1)
+	}
+
+	// Fan out car changes to streaming clients
+	feedCtx, stopFeed := context.WithCancel(context.Background())
+	defer stopFeed()
+	feed := changefeed.New(log, storage, cfg.SQLConnectionInfo)
+	go feed.Run(feedCtx)
+
+	if cfg.StatsRefreshInterval > 0 {
+		go runStatsRefresh(feedCtx, log, storage, cfg.StatsRefreshInterval)
+	}
+
+	blobs, err := newBlobStorage(cfg)
+	if err != nil {
+		log.Error("failed to init blob storage", sl.Err(err))
+		os.Exit(1)
+	}
+	// Blobs of deleted attachments and cars are removed in background
+	go runBlobCleanup(feedCtx, log, storage, blobs, cfg.AttachmentsCleanupInterval)
+
+	// JWT verifier is optional, API keys work without it
+	var verifier *auth.Verifier
+	if cfg.AuthJWTSecret != "" || cfg.AuthJWKSPath != "" {
+		verifier, err = auth.NewVerifier(cfg.AuthJWTSecret, cfg.AuthJWKSPath, cfg.AuthJWTIssuer, cfg.AuthJWTAudience)
+		if err != nil {
+			log.Error("failed to init JWT verifier", sl.Err(err))
+			os.Exit(1)
+		}
+	}
+
+	var limiter ratelimit.Limiter
+	switch cfg.RateLimitBackend {
+	case "memory":
+		limiter = ratelimit.NewMemory()
+	case "postgres":
+		limiter = ratelimit.NewPostgres(storage)
+	default:
+		log.Error("unknown rate limit backend", slog.String("backend", cfg.RateLimitBackend))
+		os.Exit(1)
+	}
+	svc := services{
+		storage:     storage,
+		archive:     archiveClient,
+		feed:        feed,
+		blobs:       blobs,
+		verifier:    verifier,
+		limiter:     limiter,
+		readyChecks: readyChecks,
+	}
+	router := newRouter(log, cfg, svc)
+
+	log.Info("starting server", slog.String("address", cfg.HTTPServerAddress))
+
+	done := make(chan os.Signal, 1)
+	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+
+	srv := &http.Server{
+		Addr:    cfg.HTTPServerAddress,
+		Handler: router,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil {
+			log.Error("failed to start server")
+		}
+	}()
+
+	log.Info("server started")
+
+	if cfg.MetricsAddress != "" {
+		metricsSrv := &http.Server{
+			Addr:    cfg.MetricsAddress,
+			Handler: metrics.Handler(),
+		}
+		go func() {
+			if err := metricsSrv.ListenAndServe(); err != nil {
+				log.Error("failed to start metrics server", sl.Err(err))
+			}
+		}()
+		log.Info("metrics server started", slog.String("address", cfg.MetricsAddress))
+	}
+
+	// gRPC API shares storage and feed with HTTP one
+	var grpcSrv *grpc.Server
+	healthSrv := grpchealth.NewServer()
+	if cfg.GRPCServerAddress != "" {
+		lis, err := net.Listen("tcp", cfg.GRPCServerAddress)
+		if err != nil {
+			log.Error("failed to listen gRPC address", sl.Err(err))
+			os.Exit(1)
+		}
+		grpcSrv = newGRPCServer(log, cfg, svc, healthSrv)
+		healthSrv.SetServingStatus(catalogpb.CatalogService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+		go func() {
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Error("failed to start gRPC server", sl.Err(err))
+			}
+		}()
+		log.Info("gRPC server started", slog.String("address", cfg.GRPCServerAddress))
+	}
+
+	<-done
+	log.Info("stopping server")
+
+	// Health checks report stopping server before it stops accepting requests
+	healthSrv.Shutdown()
+
+	// Close streams, otherwise Shutdown waits for them until timeout
+	stopFeed()
+
+	if grpcSrv != nil {
+		grpcSrv.GracefulStop()
+	}
+
+	// Ending all contexts
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Error("failed to stop server")
+
+		return
+	}
+
+	log.Info("server stopped")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"catalog/internal/config"
+	postgres "catalog/internal/storage"
+)
+
+const migrateUsage = `usage: catalog [flags] migrate <command>
+
+commands:
+  up           apply all migrations
+  down [N]     revert N last migrations, 1 by default
+  goto V       migrate up or down to version V
+  version      print applied version
+  force V      set version V without migrating, clears dirty flag`
+
+var errMigrateUsage = errors.New(migrateUsage)
+
+// runMigrate runs migrate subcommand with args after "migrate"
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	mg, err := postgres.NewMigrator(cfg.SQLMigrationInfo)
+	if err != nil {
+		return err
+	}
+	defer mg.Close()
+
+	switch cmd, arg := args[0], args[1:]; {
+	case cmd == "up" && len(arg) == 0:
+		err = mg.Up()
+	case cmd == "down" && len(arg) <= 1:
+		n := 1
+		if len(arg) == 1 {
+			if n, err = strconv.Atoi(arg[0]); err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations %q", arg[0])
+			}
+		}
+		err = mg.Down(n)
+	case cmd == "goto" && len(arg) == 1:
+		version, perr := strconv.ParseUint(arg[0], 10, 0)
+		if perr != nil {
+			return fmt.Errorf("invalid version %q", arg[0])
+		}
+		err = mg.Goto(uint(version))
+	case cmd == "force" && len(arg) == 1:
+		version, perr := strconv.Atoi(arg[0])
+		if perr != nil || version < -1 {
+			return fmt.Errorf("invalid version %q", arg[0])
+		}
+		err = mg.Force(version)
+	case cmd == "version" && len(arg) == 0:
+	default:
+		return errMigrateUsage
+	}
+	if err != nil {
+		return err
+	}
+
+	version, dirty, err := mg.Version()
+	if err != nil {
+		return err
+	}
+	latest, err := postgres.LatestMigration()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("version %d, latest %d", version, latest)
+	if dirty {
+		fmt.Print(", dirty")
+	}
+	fmt.Println()
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"catalog/internal/config"
+	"catalog/internal/http-handlers/apikeys"
+	"catalog/internal/http-handlers/attachments"
+	"catalog/internal/http-handlers/attributes"
+	"catalog/internal/http-handlers/cars"
+	"catalog/internal/http-handlers/catalog"
+	delete "catalog/internal/http-handlers/delete"
+	edit "catalog/internal/http-handlers/edit"
+	"catalog/internal/http-handlers/graphql"
+	"catalog/internal/http-handlers/health"
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/http-handlers/middleware/ratelimit"
+	"catalog/internal/http-handlers/new"
+	"catalog/internal/http-handlers/servicerecords"
+	"catalog/internal/http-handlers/stats"
+	"catalog/internal/http-handlers/stream"
+	"catalog/internal/http-handlers/tags"
+	"catalog/internal/lib/archive"
+	"catalog/internal/lib/blob"
+	"catalog/internal/lib/metrics"
+	"catalog/internal/lib/tracing"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/changefeed"
+	"catalog/internal/storage/entities"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// services are dependencies of API handlers
+type services struct {
+	storage *postgres.Storage
+	archive *archive.Client
+	feed    *changefeed.Feed
+	// blobs keeps files of attachments
+	blobs blob.Storage
+	// verifier is nil if JWT is not configured
+	verifier    *auth.Verifier
+	limiter     ratelimit.Limiter
+	readyChecks []health.Check
+}
+
+// newRouter returns handler of the whole API
+func newRouter(log *slog.Logger, cfg *config.Config, s services) http.Handler {
+	ipLimit := ratelimit.ByIP(log, s.limiter, "ip",
+		ratelimit.Limit{Rate: cfg.RateLimitIPRPS, Burst: cfg.RateLimitIPBurst})
+	readLimit := ratelimit.New(log, s.limiter, "read",
+		ratelimit.Limit{Rate: cfg.RateLimitReadRPS, Burst: cfg.RateLimitReadBurst})
+	writeLimit := ratelimit.New(log, s.limiter, "write",
+		ratelimit.Limit{Rate: cfg.RateLimitWriteRPS, Burst: cfg.RateLimitWriteBurst})
+	newLimit := ratelimit.New(log, s.limiter, "new",
+		ratelimit.Limit{Rate: cfg.RateLimitNewRPS, Burst: cfg.RateLimitNewBurst})
+
+	// Use chi and help middleware
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(middleware.Logger)
+	router.Use(middleware.Recoverer)
+	router.Use(metrics.Middleware)
+	router.Use(tracing.Middleware)
+
+	// Probes of orchestrator are not authenticated
+	router.Get("/healthz", health.Live())
+	router.Get("/readyz", health.Ready(log, s.readyChecks))
+
+	// All API requires authentication
+	router.Group(func(r chi.Router) {
+		r.Use(ipLimit)
+		r.Use(auth.New(log, s.storage, s.verifier))
+		r.Use(auth.ResolveTenant(log))
+
+		r.Group(func(r chi.Router) {
+			r.Use(auth.Require(log, auth.PermRead), readLimit)
+
+			r.Get("/catalog", catalog.New(log, s.storage, entities.ServiceIntervals{
+				Distance: cfg.ServiceIntervalDistance,
+				Time:     cfg.ServiceIntervalTime,
+			}))
+			r.Get("/cars/stream", stream.New(log, s.storage, s.feed))
+			r.Get("/cars/by-vin/{vin}", cars.ByVIN(log, s.storage))
+			r.Get("/cars/{id}/attachments", attachments.List(log, s.storage))
+			r.Get("/cars/{id}/attachments/{attachmentId}", attachments.Download(log, s.storage, s.blobs))
+			r.Get("/cars/{id}/attachments/{attachmentId}/thumbnail", attachments.Thumbnail(log, s.storage, s.blobs))
+			r.Get("/cars/{id}/services", servicerecords.List(log, s.storage))
+			r.Get("/cars/{id}/services/{recordId}", servicerecords.Get(log, s.storage))
+			r.Get("/cars/{id}/tags", tags.CarTags(log, s.storage))
+			r.Get("/tags", tags.List(log, s.storage))
+			r.Get("/attributes", attributes.List(log, s.storage))
+			// Mutations check write permission and take write or new limit in resolvers
+			r.Post("/graphql", graphql.New(log, s.storage, s.archive, graphql.RateLimits{
+				Limiter: s.limiter,
+				New:     ratelimit.Limit{Rate: cfg.RateLimitNewRPS, Burst: cfg.RateLimitNewBurst},
+				Write:   ratelimit.Limit{Rate: cfg.RateLimitWriteRPS, Burst: cfg.RateLimitWriteBurst},
+			}))
+
+			// Views are refreshed only if interval is set
+			snapshot := cfg.StatsRefreshInterval > 0
+			r.Get("/stats/counts", stats.Counts(log, s.storage, snapshot))
+			r.Get("/stats/ages", stats.Ages(log, s.storage, snapshot))
+			r.Get("/stats/owners", stats.Owners(log, s.storage, snapshot))
+			r.Get("/stats/growth", stats.Growth(log, s.storage, snapshot))
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(auth.Require(log, auth.PermWrite))
+
+			r.With(newLimit).Post("/new", new.New(log, s.storage, s.archive))
+			r.With(writeLimit).Post("/delete", delete.New(log, s.storage))
+			r.With(writeLimit).Post("/edit", edit.New(log, s.storage))
+			r.With(writeLimit).Post("/cars/{id}/attachments",
+				attachments.Upload(log, s.storage, s.blobs, int64(cfg.AttachmentsMaxSize)))
+			r.With(writeLimit).Delete("/cars/{id}/attachments/{attachmentId}", attachments.Delete(log, s.storage))
+			r.With(writeLimit).Post("/cars/{id}/status", cars.Status(log, s.storage))
+			// Tag is the whole last segment, so router must not cut format suffix like .json
+			r.With(writeLimit).Put("/cars/{id}/tags/{tag}", tags.Add(log, s.storage))
+			r.With(writeLimit).Delete("/cars/{id}/tags/{tag}", tags.Remove(log, s.storage))
+			r.With(writeLimit).Post("/tags/bulk", tags.Bulk(log, s.storage))
+			r.With(writeLimit).Post("/cars/{id}/services", servicerecords.New(log, s.storage))
+			r.With(writeLimit).Put("/cars/{id}/services/{recordId}", servicerecords.Edit(log, s.storage))
+			r.With(writeLimit).Delete("/cars/{id}/services/{recordId}", servicerecords.Delete(log, s.storage))
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(auth.Require(log, auth.PermAdmin), writeLimit)
+
+			r.Get("/api-keys", apikeys.List(log, s.storage))
+			r.Post("/api-keys", apikeys.New(log, s.storage))
+			r.Post("/api-keys/{id}/revoke", apikeys.Revoke(log, s.storage))
+			r.Post("/api-keys/{id}/rotate", apikeys.Rotate(log, s.storage))
+			r.Put("/attributes/{name}", attributes.Put(log, s.storage))
+			r.Delete("/attributes/{name}", attributes.Delete(log, s.storage))
+		})
+	})
+
+	return router
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/http-handlers/servicerecords"
+	"catalog/internal/lib/archive/archivetest"
+	"catalog/internal/storage/entities"
+)
+
+const (
+	testServiceDistance = 10000
+	testServiceTime     = 365 * 24 * time.Hour
+)
+
+// daysAgo returns date of service n days ago
+func daysAgo(n int) string {
+	return time.Now().AddDate(0, 0, -n).Format("2006-01-02")
+}
+
+func TestServiceRecords(t *testing.T) {
+	api := newTestAPI(t, archivetest.Options{})
+	ids := api.addCars("depot", append(testCars,
+		entities.Car{RegNum: "E005EE77", Mark: "Kia", Model: "Ceed", Year: 2021, Region: "moscow", Owner: anna})...)
+	editor := api.token(auth.RoleEditor, "depot")
+	viewer := api.token(auth.RoleViewer, "depot")
+	path := func(regNum string) string { return "/cars/" + strconv.Itoa(ids[regNum]) + "/services" }
+
+	tests := []struct {
+		name       string
+		regNum     string
+		token      string
+		req        servicerecords.Request
+		wantStatus int
+	}{
+		{name: "future date", regNum: "A001AA77", token: editor,
+			req: servicerecords.Request{Date: daysAgo(-2), WorkType: "oil change"}, wantStatus: 400},
+		{name: "invalid date", regNum: "A001AA77", token: editor,
+			req: servicerecords.Request{Date: "15.03.2024", WorkType: "oil change"}, wantStatus: 400},
+		{name: "negative odometer", regNum: "A001AA77", token: editor,
+			req: servicerecords.Request{Date: daysAgo(1), Odometer: -1, WorkType: "oil change"}, wantStatus: 400},
+		{name: "no work type", regNum: "A001AA77", token: editor,
+			req: servicerecords.Request{Date: daysAgo(1)}, wantStatus: 400},
+		{name: "viewer", regNum: "A001AA77", token: viewer,
+			req: servicerecords.Request{Date: daysAgo(1), WorkType: "oil change"}, wantStatus: 403},
+		{name: "car outside of scope", regNum: "A001AA77", token: api.token(auth.RoleEditor, "depot", "tatarstan"),
+			req: servicerecords.Request{Date: daysAgo(1), WorkType: "oil change"}, wantStatus: 404},
+		{name: "foreign tenant", regNum: "A001AA77", token: api.token(auth.RoleEditor, "other"),
+			req: servicerecords.Request{Date: daysAgo(1), WorkType: "oil change"}, wantStatus: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := api.do(http.MethodPost, path(tt.regNum), tt.token, tt.req, nil); status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+
+	// A001AA77 drives 100 km a day, so 20000 km passed since the last service.
+	// C003CC16 was serviced too long ago, B002BB77 recently and E005EE77 never
+	add := []struct {
+		regNum   string
+		days     int
+		odometer int
+	}{
+		{"A001AA77", 300, 0},
+		{"A001AA77", 200, 10000},
+		{"C003CC16", 400, 5000},
+		{"B002BB77", 30, 40000},
+	}
+	var last entities.ServiceRecord
+	for _, a := range add {
+		req := servicerecords.Request{Date: daysAgo(a.days), Odometer: a.odometer, WorkType: "maintenance", Cost: 99.5}
+		if status := api.do(http.MethodPost, path(a.regNum), editor, req, &last); status != 201 {
+			t.Fatalf("add record of %s = %d, want 201", a.regNum, status)
+		}
+	}
+	if last.CarID != ids["B002BB77"] || last.Date != daysAgo(30) || last.Cost != 99.5 || last.CreatedBy == "" {
+		t.Fatalf("added record = %+v", last)
+	}
+
+	var list entities.ServiceRecords
+	if status := api.do(http.MethodGet, path("A001AA77"), viewer, nil, &list); status != 200 ||
+		len(list) != 2 || list[0].Odometer != 10000 {
+		t.Fatalf("list = %d %+v, want 2 records, the latest first", status, list)
+	}
+
+	if _, regNums, _ := api.catalog(viewer, "serviceDue=true"); !reflect.DeepEqual(regNums, []string{"E005EE77", "A001AA77", "C003CC16"}) {
+		t.Fatalf("due cars = %v", regNums)
+	}
+	if _, regNums, _ := api.catalog(viewer, "serviceDue=false"); len(regNums) != 4 {
+		t.Fatalf("cars without serviceDue filter = %v, want all", regNums)
+	}
+	if status, _, _ := api.catalog(viewer, "serviceDue=soon"); status != 400 {
+		t.Fatalf("invalid serviceDue = %d, want 400", status)
+	}
+
+	record := path("B002BB77") + "/" + strconv.Itoa(last.RecordID)
+	var got entities.ServiceRecord
+	if status := api.do(http.MethodGet, record, viewer, nil, &got); status != 200 || got.RecordID != last.RecordID {
+		t.Fatalf("get = %d %+v, want record %d", status, got, last.RecordID)
+	}
+	if status := api.do(http.MethodGet, path("A001AA77")+"/"+strconv.Itoa(last.RecordID), viewer, nil, nil); status != 404 {
+		t.Fatalf("get record of other car = %d, want 404", status)
+	}
+
+	// Moving the only service of B002BB77 back in time makes it due
+	edited := servicerecords.Request{Date: daysAgo(500), Odometer: 40000, WorkType: "brakes", Notes: "pads"}
+	if status := api.do(http.MethodPut, record, editor, edited, &got); status != 200 || got.WorkType != "brakes" || got.Notes != "pads" {
+		t.Fatalf("edit = %d %+v", status, got)
+	}
+	if _, regNums, _ := api.catalog(viewer, "serviceDue=true"); len(regNums) != 4 {
+		t.Fatalf("due cars after edit = %v, want all", regNums)
+	}
+
+	if status := api.do(http.MethodDelete, record, editor, nil, nil); status != 200 {
+		t.Fatalf("delete = %d, want 200", status)
+	}
+	if status := api.do(http.MethodDelete, record, editor, nil, nil); status != 404 {
+		t.Fatalf("second delete = %d, want 404", status)
+	}
+}
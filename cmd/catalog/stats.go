@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"catalog/internal/lib/logger/sl"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/entities"
+)
+
+// runStatsRefresh refreshes materialized views of stats every interval until ctx is done.
+// Instances share views, so the one getting lock first refreshes them
+func runStatsRefresh(ctx context.Context, log *slog.Logger, storage *postgres.Storage, interval time.Duration) {
+	log = log.With(slog.String("op", "stats.refresh"))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		refreshed, err := entities.RefreshStats(ctx, storage)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			log.Error("failed to refresh stats", sl.Err(err))
+		case refreshed:
+			log.Info("stats refreshed", slog.Duration("duration", time.Since(start)))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
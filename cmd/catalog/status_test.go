@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"catalog/internal/http-handlers/cars"
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/archive/archivetest"
+	"catalog/internal/storage/entities"
+)
+
+func TestCarStatus(t *testing.T) {
+	api := newTestAPI(t, archivetest.Options{})
+	ids := api.addCars("depot", testCars...)
+	editor := api.token(auth.RoleEditor, "depot")
+	viewer := api.token(auth.RoleViewer, "depot")
+	path := func(regNum string) string { return "/cars/" + strconv.Itoa(ids[regNum]) + "/status" }
+
+	var sc entities.StatusChange
+	req := cars.StatusRequest{Status: entities.StatusSold, Reason: "sold to dealer"}
+	if status := api.do(http.MethodPost, path("B002BB77"), editor, req, &sc); status != 200 {
+		t.Fatalf("sell = %d, want 200", status)
+	}
+	if sc.From != entities.StatusActive || sc.To != entities.StatusSold || sc.Reason != "sold to dealer" || sc.ChangedBy == "" {
+		t.Fatalf("status change = %+v", sc)
+	}
+	req = cars.StatusRequest{Status: entities.StatusScrapped}
+	if status := api.do(http.MethodPost, path("C003CC16"), editor, req, nil); status != 200 {
+		t.Fatalf("scrap = %d, want 200", status)
+	}
+
+	tests := []struct {
+		name       string
+		regNum     string
+		token      string
+		status     string
+		wantStatus int
+	}{
+		{name: "scrapped is terminal", regNum: "C003CC16", token: editor, status: entities.StatusActive, wantStatus: 409},
+		{name: "sold car to repair", regNum: "B002BB77", token: editor, status: entities.StatusInRepair, wantStatus: 409},
+		{name: "same status", regNum: "A001AA77", token: editor, status: entities.StatusActive, wantStatus: 409},
+		{name: "unknown status", regNum: "A001AA77", token: editor, status: "stolen", wantStatus: 400},
+		{name: "any status", regNum: "A001AA77", token: editor, status: entities.StatusAny, wantStatus: 400},
+		{name: "viewer", regNum: "A001AA77", token: viewer, status: entities.StatusInRepair, wantStatus: 403},
+		{name: "car outside of scope", regNum: "A001AA77", token: api.token(auth.RoleEditor, "depot", "tatarstan"),
+			status: entities.StatusInRepair, wantStatus: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := cars.StatusRequest{Status: tt.status}
+			if status := api.do(http.MethodPost, path(tt.regNum), tt.token, req, nil); status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+
+	queries := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"A001AA77"}},
+		{"status=sold", []string{"B002BB77"}},
+		{"status=any&page=2", []string{"C003CC16"}},
+		{"status=any&mark=Lada", []string{"A001AA77", "B002BB77"}},
+	}
+	for _, q := range queries {
+		if status, regNums, _ := api.catalog(viewer, q.query); status != 200 || !reflect.DeepEqual(regNums, q.want) {
+			t.Errorf("catalog %q = %d %v, want %v", q.query, status, regNums, q.want)
+		}
+	}
+	if status, _, _ := api.catalog(viewer, "status=stolen"); status != 400 {
+		t.Errorf("catalog with unknown status = %d, want 400", status)
+	}
+
+	// Sold car comes back to catalog
+	req = cars.StatusRequest{Status: entities.StatusActive, Reason: "bought back"}
+	if status := api.do(http.MethodPost, path("B002BB77"), editor, req, nil); status != 200 {
+		t.Fatalf("buy back = %d, want 200", status)
+	}
+	if _, regNums, _ := api.catalog(viewer, ""); !reflect.DeepEqual(regNums, []string{"A001AA77", "B002BB77"}) {
+		t.Fatalf("catalog after buy back = %v", regNums)
+	}
+}
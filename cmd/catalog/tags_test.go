@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"catalog/internal/grpc-handlers/catalogpb"
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/http-handlers/tags"
+	"catalog/internal/lib/archive/archivetest"
+	"catalog/internal/storage/entities"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTags(t *testing.T) {
+	api := newTestAPI(t, archivetest.Options{})
+	ids := api.addCars("depot", testCars...)
+	api.addCars("other", entities.Car{RegNum: "A001AA77", Mark: "Lada", Model: "Vesta", Year: 2020, Owner: ivan})
+	editor := api.token(auth.RoleEditor, "depot")
+	viewer := api.token(auth.RoleViewer, "depot")
+	path := func(regNum string) string { return "/cars/" + strconv.Itoa(ids[regNum]) + "/tags" }
+
+	var resp tags.Response
+	bulk := tags.BulkRequest{
+		CarIDs: []int{ids["A001AA77"], ids["B002BB77"], ids["C003CC16"]},
+		Add:    []string{"winter tires", " pool-A "},
+	}
+	if status := api.do(http.MethodPost, "/tags/bulk", editor, bulk, &resp); status != 200 || resp.Added != 6 {
+		t.Fatalf("bulk add = %d %+v, want 6 added", status, resp)
+	}
+	if status := api.do(http.MethodPut, path("A001AA77")+"/needs-inspection", editor, nil, &resp); status != 200 || resp.Added != 1 {
+		t.Fatalf("add = %d %+v, want 1 added", status, resp)
+	}
+	// Repeated tag is not counted
+	if status := api.do(http.MethodPut, path("A001AA77")+"/needs-inspection", editor, nil, &resp); status != 200 || resp.Added != 0 {
+		t.Fatalf("repeated add = %d %+v, want 0 added", status, resp)
+	}
+	if status := api.do(http.MethodDelete, path("C003CC16")+"/winter%20tires", editor, nil, &resp); status != 200 || resp.Removed != 1 {
+		t.Fatalf("remove = %d %+v, want 1 removed", status, resp)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       any
+		wantStatus int
+	}{
+		{name: "viewer", method: http.MethodPut, path: path("A001AA77") + "/x", token: viewer, wantStatus: 403},
+		{name: "blank tag", method: http.MethodPut, path: path("A001AA77") + "/%20", token: editor, wantStatus: 400},
+		{name: "car outside of scope", method: http.MethodPut, path: path("A001AA77") + "/x",
+			token: api.token(auth.RoleEditor, "depot", "tatarstan"), wantStatus: 404},
+		{name: "bulk without tags", method: http.MethodPost, path: "/tags/bulk", token: editor,
+			body: tags.BulkRequest{CarIDs: []int{ids["A001AA77"]}}, wantStatus: 400},
+		{name: "bulk with foreign car", method: http.MethodPost, path: "/tags/bulk", token: api.token(auth.RoleEditor, "other"),
+			body: tags.BulkRequest{CarIDs: []int{ids["A001AA77"]}, Add: []string{"x"}}, wantStatus: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := api.do(tt.method, tt.path, tt.token, tt.body, nil); status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+
+	var carTags []string
+	if status := api.do(http.MethodGet, path("A001AA77"), viewer, nil, &carTags); status != 200 ||
+		!reflect.DeepEqual(carTags, []string{"needs-inspection", "pool-A", "winter tires"}) {
+		t.Fatalf("car tags = %d %v", status, carTags)
+	}
+
+	// Dots are part of tag, not format suffix of path
+	if status := api.do(http.MethodPut, path("A001AA77")+"/v1.2", editor, nil, &resp); status != 200 || resp.Added != 1 {
+		t.Fatalf("add dotted = %d %+v, want 1 added", status, resp)
+	}
+	if status := api.do(http.MethodGet, path("A001AA77"), viewer, nil, &carTags); status != 200 ||
+		!reflect.DeepEqual(carTags, []string{"needs-inspection", "pool-A", "v1.2", "winter tires"}) {
+		t.Fatalf("car tags with dotted = %d %v", status, carTags)
+	}
+	if status := api.do(http.MethodDelete, path("A001AA77")+"/v1.2", editor, nil, &resp); status != 200 || resp.Removed != 1 {
+		t.Fatalf("remove dotted = %d %+v, want 1 removed", status, resp)
+	}
+
+	var list entities.Tags
+	want := entities.Tags{{Name: "pool-A", Cars: 3}, {Name: "winter tires", Cars: 2}, {Name: "needs-inspection", Cars: 1}}
+	if status := api.do(http.MethodGet, "/tags", viewer, nil, &list); status != 200 || !reflect.DeepEqual(list, want) {
+		t.Fatalf("tags = %d %+v, want %+v", status, list, want)
+	}
+
+	queries := []struct {
+		query string
+		want  []string
+	}{
+		{"tag=winter%20tires", []string{"A001AA77", "B002BB77"}},
+		{"tag=needs-inspection&tag=winter%20tires", []string{"A001AA77", "B002BB77"}},
+		{"tag=needs-inspection&tag=winter%20tires&tagMatch=all", []string{"A001AA77"}},
+		{"tag=unknown", []string{}},
+	}
+	for _, q := range queries {
+		if status, regNums, _ := api.catalog(viewer, q.query); status != 200 || !reflect.DeepEqual(regNums, q.want) {
+			t.Errorf("catalog %q = %d %v, want %v", q.query, status, regNums, q.want)
+		}
+	}
+	if status, _, _ := api.catalog(viewer, "tag=a&tagMatch=some"); status != 400 {
+		t.Errorf("catalog with invalid tagMatch = %d, want 400", status)
+	}
+
+	// Changes don't carry tags, stream refuses filter instead of ignoring it
+	for _, query := range []string{"tag=pool-A", "serviceDue=true"} {
+		if status := api.do(http.MethodGet, "/cars/stream?"+query, viewer, nil, nil); status != 400 {
+			t.Errorf("stream with %s = %d, want 400", query, status)
+		}
+	}
+
+	// gRPC and GraphQL filter by tags the same way
+	client := catalogpb.NewCatalogServiceClient(api.grpcClient())
+	filter := &catalogpb.CarFilter{Tags: []string{"needs-inspection", "winter tires"}, AllTags: true}
+	rpcList, err := client.ListCars(withToken(viewer), &catalogpb.ListCarsRequest{Filter: filter})
+	if err != nil || len(rpcList.Cars) != 1 || rpcList.Cars[0].RegNum != "A001AA77" {
+		t.Errorf("ListCars by tags = %v, %v", rpcList, err)
+	}
+	filter = &catalogpb.CarFilter{Tags: []string{" "}}
+	if _, err := client.ListCars(withToken(viewer), &catalogpb.ListCarsRequest{Filter: filter}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListCars by blank tag: %v, want InvalidArgument", err)
+	}
+	ans := api.graphql(viewer, `{ cars(filter: {tags: ["winter tires"]}) { nodes { regNum } } }`, nil)
+	wantNodes := map[string]any{"nodes": []any{map[string]any{"regNum": "A001AA77"}, map[string]any{"regNum": "B002BB77"}}}
+	if len(ans.Errors) > 0 || !reflect.DeepEqual(ans.Data["cars"], wantNodes) {
+		t.Errorf("cars by tags = %+v", ans)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/url"
+	"reflect"
+
+	"catalog/internal/http-handlers/catalog"
+	"catalog/internal/lib/archive"
+	"catalog/internal/storage/entities"
+)
+
+// carFields are /catalog query parameters, they are flags of car commands
+var carFields = []string{"carId", "regNum", "mark", "model", "year", "region", "status", "name", "surname", "patronymic"}
+
+// carFlags registers car fields in fs. Returned function makes car of set flags
+func carFlags(fs *flag.FlagSet) func() (entities.Car, error) {
+	values := make(map[string]*string, len(carFields))
+	for _, f := range carFields {
+		values[f] = fs.String(f, "", "car "+f)
+	}
+	return func() (entities.Car, error) {
+		query := url.Values{}
+		for f, v := range values {
+			if *v != "" {
+				query.Set(f, *v)
+			}
+		}
+		return catalog.ParseFilter(query)
+	}
+}
+
+func carsList(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("cars list", flag.ContinueOnError)
+	filter := carFlags(fs)
+	page := fs.Int("page", 0, "page of catalog, 0 for all pages")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+
+	cars, err := findCars(ctx, a, &f, *page)
+	if err != nil {
+		return err
+	}
+	return a.out.cars(cars)
+}
+
+// findCars returns cars of catalog page or all cars if page is 0
+func findCars(ctx context.Context, a *app, f *entities.Car, page int) (entities.Cars, error) {
+	if page != 0 {
+		var cp entities.CatalogPage
+		if err := cp.GetCatalogPage(ctx, a.storage, f, page, a.scope); err != nil {
+			return nil, err
+		}
+		return cp.Cars, nil
+	}
+
+	var cars entities.Cars
+	err := entities.EachCar(ctx, a.storage, f, a.scope, func(c *entities.Car) error {
+		cars = append(cars, *c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cars, nil
+}
+
+func carsAdd(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("cars add", flag.ContinueOnError)
+	regNum := fs.String("regNum", "", "registration number to request from archive (required)")
+	region := fs.String("region", "", "region of car")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *regNum == "" {
+		return errors.New("--regNum is required")
+	}
+
+	cr, err := archive.New(a.cfg.ArchiveURL, a.cfg.ArchiveTimeout).GetCar(ctx, *regNum)
+	if err != nil {
+		return err
+	}
+
+	c := entities.Car{
+		RegNum: cr.RegNum,
+		Mark:   cr.Mark,
+		Model:  cr.Model,
+		Year:   cr.Year,
+		Region: *region,
+		Owner: entities.Person{
+			Name:       cr.Owner.Name,
+			Surname:    cr.Owner.Surname,
+			Patronymic: cr.Owner.Patronymic,
+		},
+	}
+	if err := c.New(ctx, a.storage, a.scope); err != nil {
+		return err
+	}
+
+	return a.out.message(c, "car %s %s %s added", c.RegNum, c.Mark, c.Model)
+}
+
+func carsEdit(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("cars edit", flag.ContinueOnError)
+	fields := carFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	c, err := fields()
+	if err != nil {
+		return err
+	}
+	if c.CarID == 0 {
+		return errors.New("--carId is required")
+	}
+	if reflect.DeepEqual(c, entities.Car{CarID: c.CarID}) {
+		return errors.New("nothing to change")
+	}
+
+	if err := c.Edit(ctx, a.storage, a.scope); err != nil {
+		return err
+	}
+
+	return a.out.message(c, "car %d changed", c.CarID)
+}
+
+func carsDelete(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("cars delete", flag.ContinueOnError)
+	carID := fs.Int("carId", 0, "car to delete (required)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *carID == 0 {
+		return errors.New("--carId is required")
+	}
+
+	var c entities.Car
+	if err := c.Delete(ctx, a.storage, *carID, a.scope); err != nil {
+		return err
+	}
+
+	return a.out.message(map[string]int{"carId": *carID}, "car %d deleted", *carID)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"catalog/internal/storage/entities"
+)
+
+// csvHeader is header of exported files, imported ones may have columns in any order
+// and without carId
+var csvHeader = []string{"carId", "regNum", "mark", "model", "year", "region", "name", "surname", "patronymic"}
+
+func importCars(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	path := fs.String("file", "", "CSV or JSON file with cars (required)")
+	format := fs.String("format", "", "csv or json, by file extension if empty")
+	skipFailed := fs.Bool("skip-failed", false, "report failed cars and continue")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return errors.New("--file is required")
+	}
+
+	f, err := os.Open(*path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var cars entities.Cars
+	switch formatOf(*path, *format) {
+	case "csv":
+		cars, err = readCSV(f)
+	case "json":
+		err = json.NewDecoder(f).Decode(&cars)
+	default:
+		return fmt.Errorf("unknown file format of %s", *path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", *path, err)
+	}
+
+	added, failed := 0, 0
+	for i, c := range cars {
+		if err := c.New(ctx, a.storage, a.scope); err != nil {
+			if !*skipFailed {
+				return fmt.Errorf("car %d (%s): %w, %d cars added before", i+1, c.RegNum, err, added)
+			}
+			fmt.Fprintf(os.Stderr, "car %d (%s): %v\n", i+1, c.RegNum, err)
+			failed++
+			continue
+		}
+		added++
+	}
+
+	result := map[string]int{"added": added, "failed": failed}
+	return a.out.message(result, "%d cars added, %d failed", added, failed)
+}
+
+func exportCars(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	filter := carFlags(fs)
+	path := fs.String("file", "-", "CSV or JSON file to write, - for standard output")
+	format := fs.String("format", "", "csv or json, by file extension if empty, csv for standard output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+
+	cars, err := findCars(ctx, a, &f, 0)
+	if err != nil {
+		return err
+	}
+	if cars == nil {
+		cars = entities.Cars{}
+	}
+
+	w := io.Writer(os.Stdout)
+	if *path != "-" {
+		file, err := os.Create(*path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	switch formatOf(*path, *format) {
+	case "csv":
+		err = writeCSV(w, cars)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(cars)
+	default:
+		return fmt.Errorf("unknown file format of %s", *path)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Keep standard output clean for exported data
+	if *path != "-" {
+		return a.out.message(map[string]int{"exported": len(cars)}, "%d cars exported", len(cars))
+	}
+	return nil
+}
+
+// formatOf returns format or format by path extension
+func formatOf(path, format string) string {
+	if format != "" {
+		return format
+	}
+	if path == "-" {
+		return "csv"
+	}
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+}
+
+func readCSV(r io.Reader) (entities.Cars, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[strings.TrimSpace(h)] = i
+	}
+	for _, required := range []string{"regNum", "mark", "model", "name", "surname"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("column %s is missing", required)
+		}
+	}
+
+	var cars entities.Cars
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return cars, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		get := func(column string) string {
+			if i, ok := columns[column]; ok {
+				return record[i]
+			}
+			return ""
+		}
+
+		c := entities.Car{
+			RegNum: get("regNum"),
+			Mark:   get("mark"),
+			Model:  get("model"),
+			Region: get("region"),
+			Owner: entities.Person{
+				Name:       get("name"),
+				Surname:    get("surname"),
+				Patronymic: get("patronymic"),
+			},
+		}
+		if year := get("year"); year != "" {
+			if c.Year, err = strconv.Atoi(year); err != nil {
+				line, _ := cr.FieldPos(0)
+				return nil, fmt.Errorf("line %d: invalid year %q", line, year)
+			}
+		}
+		cars = append(cars, c)
+	}
+}
+
+func writeCSV(w io.Writer, cars entities.Cars) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, c := range cars {
+		err := cw.Write([]string{
+			strconv.Itoa(c.CarID), c.RegNum, c.Mark, c.Model, strconv.Itoa(c.Year), c.Region,
+			c.Owner.Name, c.Owner.Surname, c.Owner.Patronymic,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"catalog/internal/storage/entities"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	cars := entities.Cars{
+		{CarID: 7, RegNum: "X123XX150", Mark: "Lada", Model: "Vesta", Year: 2002, Region: "msk",
+			Owner: entities.Person{Name: "Ivan", Surname: "Petrov", Patronymic: "Sergeevich"}},
+		{CarID: 8, RegNum: "A001AA77", Mark: "Mercedes, Benz", Model: "E", Owner: entities.Person{Name: "Anna", Surname: "Ivanova"}},
+	}
+
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, cars); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readCSV(&buf)
+	if err != nil {
+		t.Fatalf("failed to read exported CSV: %v", err)
+	}
+
+	if len(got) != len(cars) {
+		t.Fatalf("got %d cars, want %d", len(got), len(cars))
+	}
+	for i := range cars {
+		// Ids are assigned on import
+		want := cars[i]
+		want.CarID = 0
+		if !reflect.DeepEqual(got[i], want) {
+			t.Errorf("car %d = %+v, want %+v", i, got[i], want)
+		}
+	}
+}
+
+func TestReadCSVColumns(t *testing.T) {
+	in := "surname,name,regNum,mark,model\nPetrov,Ivan,X123XX150,Lada,Vesta\n"
+	cars, err := readCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cars) != 1 || cars[0].Owner.Surname != "Petrov" || cars[0].RegNum != "X123XX150" {
+		t.Fatalf("cars = %+v", cars)
+	}
+
+	if _, err := readCSV(strings.NewReader("regNum,mark\nX,Y\n")); err == nil {
+		t.Fatal("CSV without required columns was read")
+	}
+}
+
+func TestCommandOf(t *testing.T) {
+	name, args := commandOf([]string{"cars", "list", "--mark", "Lada"})
+	if name != "cars list" || len(args) != 2 {
+		t.Fatalf("commandOf = %q %v", name, args)
+	}
+	name, args = commandOf([]string{"export", "--file", "cars.csv"})
+	if name != "export" || len(args) != 2 {
+		t.Fatalf("commandOf = %q %v", name, args)
+	}
+}
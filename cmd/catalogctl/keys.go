@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os/user"
+	"strings"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/storage/entities"
+)
+
+// keysCreate makes API key of tenant directly in DB. /api-keys needs admin key itself,
+// so the first one is created here
+func keysCreate(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("keys create", flag.ContinueOnError)
+	name := fs.String("name", "", "name of key (required)")
+	role := fs.String("role", auth.RoleAdmin, "role of key: viewer, editor or admin")
+	regions := fs.String("regions", "", "comma separated regions visible by key, all regions if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("--name is required")
+	}
+	if !auth.ValidRole(*role) {
+		return fmt.Errorf("unknown role %q", *role)
+	}
+
+	k := entities.APIKey{
+		Name:      *name,
+		Role:      *role,
+		TenantID:  a.scope.TenantID,
+		CreatedBy: "catalogctl",
+	}
+	if u, err := user.Current(); err == nil {
+		k.CreatedBy += ":" + u.Username
+	}
+	if *regions != "" {
+		for _, region := range strings.Split(*regions, ",") {
+			k.Regions = append(k.Regions, strings.TrimSpace(region))
+		}
+	}
+
+	key, err := k.New(ctx, a.storage)
+	if err != nil {
+		return err
+	}
+
+	result := map[string]interface{}{"keyId": k.KeyID, "name": k.Name, "role": k.Role, "key": key}
+	return a.out.message(result, "API key %d created, it is shown only once:\n%s", k.KeyID, key)
+}
@@ -0,0 +1,133 @@
+// Command catalogctl is administration tool working with catalog DB through entities,
+// the same way as HTTP API does
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"catalog/internal/config"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/entities"
+)
+
+const usage = `usage: catalogctl [flags] <command> [command flags]
+
+commands:
+  cars list       search cars with /catalog filters
+  cars add        add car by registration number from archive
+  cars edit       change car fields
+  cars delete     delete car
+  owners list     search owners by name parts
+  owners merge    move cars of duplicate owners to one and delete duplicates
+  keys create     create API key, e.g. the first admin key for /api-keys
+  import          add cars from CSV or JSON file
+  export          write cars found by filters to CSV or JSON file
+  seed            generate synthetic persons and cars for development and load tests
+
+flags:`
+
+// app is state shared by commands
+type app struct {
+	cfg     *config.Config
+	storage *postgres.Storage
+	scope   entities.Scope
+	out     *printer
+}
+
+type command func(ctx context.Context, a *app, args []string) error
+
+var commands = map[string]command{
+	"cars list":    carsList,
+	"cars add":     carsAdd,
+	"cars edit":    carsEdit,
+	"cars delete":  carsDelete,
+	"owners list":  ownersList,
+	"owners merge": ownersMerge,
+	"keys create":  keysCreate,
+	"import":       importCars,
+	"export":       exportCars,
+	"seed":         seedCars,
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("catalogctl", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", "", "path to catalog config file, overrides "+config.ConfigPathEnv)
+	tenant := fs.String("tenant", "", "tenant to work in (required)")
+	output := fs.String("output", "table", "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	name, cmdArgs := commandOf(fs.Args())
+	cmd, ok := commands[name]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", name)
+	}
+	if *tenant == "" {
+		return errors.New("--tenant is required")
+	}
+	out, err := newPrinter(os.Stdout, *output)
+	if err != nil {
+		return err
+	}
+
+	// Connection settings are shared with catalog server
+	var cfgArgs []string
+	if *configPath != "" {
+		cfgArgs = []string{"--config", *configPath}
+	}
+	cfg, err := config.Load(cfgArgs)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	storage, err := postgres.New(cfg.SQLDriver, cfg.SQLConnectionInfo)
+	if err != nil {
+		return err
+	}
+	defer storage.DB.Close()
+
+	ctx := context.Background()
+	if err := storage.Connect(ctx, cfg.SQLConnectTimeout, nil); err != nil {
+		return err
+	}
+
+	a := &app{
+		cfg:     cfg,
+		storage: storage,
+		scope:   entities.Scope{TenantID: *tenant},
+		out:     out,
+	}
+	return cmd(ctx, a, cmdArgs)
+}
+
+// commandOf splits args into command name of one or two words and its arguments
+func commandOf(args []string) (string, []string) {
+	if len(args) == 0 {
+		return "", nil
+	}
+	if len(args) > 1 {
+		if name := args[0] + " " + args[1]; commands[name] != nil {
+			return name, args[2:]
+		}
+	}
+	return args[0], args[1:]
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"catalog/internal/storage/entities"
+)
+
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case "table":
+		return &printer{w: w}, nil
+	case "json":
+		return &printer{w: w, json: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// print writes v as JSON or rows as table with header
+func (p *printer) print(v interface{}, header []string, rows [][]string) error {
+	if p.json {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func (p *printer) cars(cars entities.Cars) error {
+	rows := make([][]string, 0, len(cars))
+	for _, c := range cars {
+		rows = append(rows, []string{
+			strconv.Itoa(c.CarID), c.RegNum, c.Mark, c.Model, strconv.Itoa(c.Year), c.Region,
+			strings.TrimSpace(c.Owner.Surname + " " + c.Owner.Name + " " + c.Owner.Patronymic),
+		})
+	}
+	if cars == nil {
+		cars = entities.Cars{}
+	}
+	return p.print(cars, []string{"ID", "REG NUM", "MARK", "MODEL", "YEAR", "REGION", "OWNER"}, rows)
+}
+
+func (p *printer) owners(owners entities.Owners) error {
+	rows := make([][]string, 0, len(owners))
+	for _, o := range owners {
+		rows = append(rows, []string{
+			strconv.Itoa(o.PersonID), o.Surname, o.Name, o.Patronymic, strconv.Itoa(o.Cars),
+		})
+	}
+	if owners == nil {
+		owners = entities.Owners{}
+	}
+	return p.print(owners, []string{"ID", "SURNAME", "NAME", "PATRONYMIC", "CARS"}, rows)
+}
+
+// message prints result of command changing data
+func (p *printer) message(v interface{}, format string, args ...interface{}) error {
+	if p.json {
+		return p.print(v, nil, nil)
+	}
+	_, err := fmt.Fprintf(p.w, format+"\n", args...)
+	return err
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"catalog/internal/storage/entities"
+)
+
+func ownersList(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("owners list", flag.ContinueOnError)
+	var p entities.Person
+	fs.StringVar(&p.Name, "name", "", "part of name")
+	fs.StringVar(&p.Surname, "surname", "", "part of surname")
+	fs.StringVar(&p.Patronymic, "patronymic", "", "part of patronymic")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var owners entities.Owners
+	if err := owners.Search(ctx, a.storage, &p, a.scope.TenantID); err != nil {
+		return err
+	}
+	return a.out.owners(owners)
+}
+
+// ownersMerge usage: owners merge --into ID DUPLICATE_ID...
+func ownersMerge(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("owners merge", flag.ContinueOnError)
+	intoID := fs.Int("into", 0, "person who gets cars of duplicates listed as arguments (required)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *intoID <= 0 {
+		return errors.New("--into is required")
+	}
+	if fs.NArg() == 0 {
+		return errors.New("ids of duplicates are required")
+	}
+	fromIDs, err := parseIDs(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	moved, err := entities.MergePersons(ctx, a.storage, *intoID, fromIDs, a.scope.TenantID)
+	if err != nil {
+		return err
+	}
+
+	result := map[string]interface{}{"into": *intoID, "merged": fromIDs, "movedCars": moved}
+	return a.out.message(result, "%d persons merged into %d, %d cars moved", len(fromIDs), *intoID, moved)
+}
+
+// parseIDs parses positive ids
+func parseIDs(args []string) ([]int, error) {
+	ids := make([]int, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid id %q", arg)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"catalog/internal/lib/seed"
+	"catalog/internal/storage/entities"
+)
+
+func seedCars(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	var opts seed.Options
+	fs.Int64Var(&opts.Seed, "seed", 1, "random seed, the same seed makes the same data")
+	fs.IntVar(&opts.Persons, "persons", 1000, "count of persons")
+	fs.IntVar(&opts.Cars, "cars", 1500, "count of cars")
+	fs.IntVar(&opts.MinYear, "min-year", 1980, "year of the oldest car")
+	fs.IntVar(&opts.MaxYear, "max-year", time.Now().Year(), "year of the newest car")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	g := seed.New(opts)
+	persons := g.Persons()
+	cars, owners := g.Cars()
+
+	start := time.Now()
+	inserted, err := entities.BulkInsert(ctx, a.storage, persons, cars, owners, a.scope.TenantID)
+	if err != nil {
+		return err
+	}
+
+	result := map[string]interface{}{"persons": len(persons), "cars": inserted, "duration": time.Since(start).String()}
+	return a.out.message(result, "%d persons and %d cars inserted in %s", len(persons), inserted, time.Since(start).Round(time.Millisecond))
+}
@@ -1,31 +1,439 @@
-package config
-
-import (
-	"log"
-	"os"
-)
-
-type Config struct {
-	SQLDriver         string
-	SQLConnectionInfo string
-	SQLMigrationInfo  string
-	HTTPServerAddress string
-}
-
-func MustLoad() *Config {
-	return &Config{
-		SQLDriver:         getEnv("SQL_DRIVER"),
-		SQLConnectionInfo: getEnv("SQL_CONNECTION_INFO"),
-		SQLMigrationInfo:  getEnv("SQL_MIGRATION_INFO"),
-		HTTPServerAddress: getEnv("HTTP_SERVER_ADDRESS"),
-	}
-}
-
-// / Simple helper function to read an environment or return a default value
-func getEnv(key string) string {
-	value, exists := os.LookupEnv(key)
-	if !exists {
-		log.Fatal("config parameter does not set in environment")
-	}
-	return value
-}
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is filled from sources in order of priority: command line flags, environment
+// variables, config file, defaults. Every field is described by tags:
+// env - variable name and key in config file, flag - command line flag,
+// default - value used if no source sets it, secret - value is hidden by Print
+type Config struct {
+	SQLDriver         string `env:"SQL_DRIVER" flag:"sql-driver" default:"postgres"`
+	SQLConnectionInfo string `env:"SQL_CONNECTION_INFO" flag:"sql-connection-info" secret:"true"`
+	SQLMigrationInfo  string `env:"SQL_MIGRATION_INFO" flag:"sql-migration-info" secret:"true"`
+	// SQLConnectTimeout limits waiting for DB on startup
+	SQLConnectTimeout time.Duration `env:"SQL_CONNECT_TIMEOUT" flag:"sql-connect-timeout" default:"30s"`
+	// SQLMigrateOnStart applies migrations before serving
+	SQLMigrateOnStart bool `env:"SQL_MIGRATE_ON_START" flag:"sql-migrate-on-start" default:"true"`
+	// SQLAllowOutdatedSchema lets server start on dirty or not latest schema
+	SQLAllowOutdatedSchema bool   `env:"SQL_ALLOW_OUTDATED_SCHEMA" flag:"sql-allow-outdated-schema" default:"false"`
+	HTTPServerAddress      string `env:"HTTP_SERVER_ADDRESS" flag:"http-server-address" default:"localhost:8000"`
+	// GRPCServerAddress serves gRPC API, empty disables it
+	GRPCServerAddress string `env:"GRPC_SERVER_ADDRESS" flag:"grpc-server-address" default:"localhost:9000"`
+	// JWT is accepted only if secret or JWKS file is set
+	AuthJWTSecret   string `env:"AUTH_JWT_SECRET" flag:"auth-jwt-secret" secret:"true"`
+	AuthJWKSPath    string `env:"AUTH_JWKS_PATH" flag:"auth-jwks-path"`
+	AuthJWTIssuer   string `env:"AUTH_JWT_ISSUER" flag:"auth-jwt-issuer"`
+	AuthJWTAudience string `env:"AUTH_JWT_AUDIENCE" flag:"auth-jwt-audience"`
+	// RateLimitBackend is memory for per instance limits or postgres for shared ones.
+	// Zero rate disables limit of route group
+	RateLimitBackend string `env:"RATE_LIMIT_BACKEND" flag:"rate-limit-backend" default:"memory"`
+	// Any request by client IP, taken before authentication so wrong credentials are
+	// limited too. It must be above other limits of one client
+	RateLimitIPRPS   float64 `env:"RATE_LIMIT_IP_RPS" flag:"rate-limit-ip-rps" default:"20"`
+	RateLimitIPBurst int     `env:"RATE_LIMIT_IP_BURST" flag:"rate-limit-ip-burst" default:"40"`
+	// Reads are cheap
+	RateLimitReadRPS   float64 `env:"RATE_LIMIT_READ_RPS" flag:"rate-limit-read-rps" default:"10"`
+	RateLimitReadBurst int     `env:"RATE_LIMIT_READ_BURST" flag:"rate-limit-read-burst" default:"20"`
+	// Edit, delete and administration
+	RateLimitWriteRPS   float64 `env:"RATE_LIMIT_WRITE_RPS" flag:"rate-limit-write-rps" default:"2"`
+	RateLimitWriteBurst int     `env:"RATE_LIMIT_WRITE_BURST" flag:"rate-limit-write-burst" default:"5"`
+	// Every new car is requested from archive
+	RateLimitNewRPS   float64       `env:"RATE_LIMIT_NEW_RPS" flag:"rate-limit-new-rps" default:"0.5"`
+	RateLimitNewBurst int           `env:"RATE_LIMIT_NEW_BURST" flag:"rate-limit-new-burst" default:"3"`
+	ArchiveURL        string        `env:"ARCHIVE_URL" flag:"archive-url" default:"http://localhost:8080"`
+	ArchiveTimeout    time.Duration `env:"ARCHIVE_TIMEOUT" flag:"archive-timeout" default:"5s"`
+	// ArchiveReadiness is required, optional or ignore: whether unreachable archive
+	// makes service not ready, only degraded or isn't checked
+	ArchiveReadiness string `env:"ARCHIVE_READINESS" flag:"archive-readiness" default:"optional"`
+	// StatsRefreshInterval is period of refreshing materialized views of /stats.
+	// Zero disables views, stats are computed from tables on every request
+	StatsRefreshInterval time.Duration `env:"STATS_REFRESH_INTERVAL" flag:"stats-refresh-interval" default:"0s"`
+	// AttachmentsBackend is local for files of AttachmentsDir or s3 for bucket of
+	// S3 compatible storage
+	AttachmentsBackend     string `env:"ATTACHMENTS_BACKEND" flag:"attachments-backend" default:"local"`
+	AttachmentsDir         string `env:"ATTACHMENTS_DIR" flag:"attachments-dir" default:"attachments"`
+	AttachmentsS3Endpoint  string `env:"ATTACHMENTS_S3_ENDPOINT" flag:"attachments-s3-endpoint"`
+	AttachmentsS3Region    string `env:"ATTACHMENTS_S3_REGION" flag:"attachments-s3-region" default:"us-east-1"`
+	AttachmentsS3Bucket    string `env:"ATTACHMENTS_S3_BUCKET" flag:"attachments-s3-bucket"`
+	AttachmentsS3AccessKey string `env:"ATTACHMENTS_S3_ACCESS_KEY" flag:"attachments-s3-access-key"`
+	AttachmentsS3SecretKey string `env:"ATTACHMENTS_S3_SECRET_KEY" flag:"attachments-s3-secret-key" secret:"true"`
+	// AttachmentsMaxSize limits uploaded file, bytes
+	AttachmentsMaxSize int `env:"ATTACHMENTS_MAX_SIZE" flag:"attachments-max-size" default:"10485760"`
+	// AttachmentsCleanupInterval is period of removing blobs of deleted attachments
+	AttachmentsCleanupInterval time.Duration `env:"ATTACHMENTS_CLEANUP_INTERVAL" flag:"attachments-cleanup-interval" default:"1m"`
+	// ServiceIntervalDistance and ServiceIntervalTime tell when car is due for service:
+	// km driven or time passed since the last service. Zero disables the criterion
+	ServiceIntervalDistance int           `env:"SERVICE_INTERVAL_DISTANCE" flag:"service-interval-distance" default:"15000"`
+	ServiceIntervalTime     time.Duration `env:"SERVICE_INTERVAL_TIME" flag:"service-interval-time" default:"8760h"`
+	// MetricsAddress serves /metrics on separate listener, it's not authenticated and must not
+	// be public. Empty disables metrics
+	MetricsAddress string `env:"METRICS_ADDRESS" flag:"metrics-address" default:"localhost:9100"`
+	// TracingExporter is none, stdout or otlp
+	TracingExporter string `env:"TRACING_EXPORTER" flag:"tracing-exporter" default:"none"`
+	// Empty endpoint means OTEL_EXPORTER_OTLP_* variables
+	TracingOTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT" flag:"tracing-otlp-endpoint"`
+	TracingSampleRatio  float64 `env:"TRACING_SAMPLE_RATIO" flag:"tracing-sample-ratio" default:"1"`
+
+	// PrintConfig asks to print config and exit, it is set by flag only
+	PrintConfig bool `env:"-"`
+	// Args are command line arguments after flags, e.g. subcommand
+	Args []string `env:"-"`
+}
+
+// ConfigPathEnv names config file when --config flag is not set
+const ConfigPathEnv = "CONFIG_PATH"
+
+// MustLoad loads config from command line arguments, environment and config file.
+// All problems are printed before exit
+func MustLoad() *Config {
+	cfg, err := Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config:\n%v\n", err)
+		os.Exit(2)
+	}
+	return cfg
+}
+
+// Load fills config from sources and validates it
+func Load(args []string) (*Config, error) {
+	var cfg Config
+	fields := fieldsOf(&cfg)
+
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to YAML or .env config file, overrides "+ConfigPathEnv)
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print resulting config with secrets redacted and exit")
+	flagValues := make(map[string]*string, len(fields))
+	for _, f := range fields {
+		flagValues[f.flag] = fs.String(f.flag, "", "overrides "+f.env)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	cfg.Args = fs.Args()
+
+	// Defaults, then file, environment and flags
+	values := make(map[string]string, len(fields))
+	for _, f := range fields {
+		values[f.env] = f.def
+	}
+
+	path := *configPath
+	if path == "" {
+		path = os.Getenv(ConfigPathEnv)
+	}
+	if path != "" {
+		fileValues, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			if v, ok := fileValues[f.env]; ok {
+				values[f.env] = v
+			}
+		}
+	}
+
+	for _, f := range fields {
+		if v, ok := os.LookupEnv(f.env); ok {
+			values[f.env] = v
+		}
+	}
+
+	fs.Visit(func(fl *flag.Flag) {
+		for _, f := range fields {
+			if f.flag == fl.Name {
+				values[f.env] = *flagValues[f.flag]
+			}
+		}
+	})
+
+	var errs []error
+	invalid := make(map[string]bool)
+	for _, f := range fields {
+		if err := f.set(values[f.env]); err != nil {
+			errs = append(errs, err)
+			invalid[f.env] = true
+		}
+	}
+	// Values which failed to parse are already reported
+	for _, p := range cfg.validate() {
+		if !invalid[p.key] {
+			errs = append(errs, p.err)
+		}
+	}
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return &cfg, nil
+}
+
+// problem is validation error of key
+type problem struct {
+	key string
+	err error
+}
+
+func (c *Config) validate() []problem {
+	var problems []problem
+	add := func(key, format string, args ...interface{}) {
+		problems = append(problems, problem{key: key, err: fmt.Errorf(key+" "+format, args...)})
+	}
+	required := func(key, value string) {
+		if value == "" {
+			add(key, "is required")
+		}
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		add(key, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+	positive := func(key string, value time.Duration) {
+		if value <= 0 {
+			add(key, "must be positive, got %s", value)
+		}
+	}
+	limit := func(prefix string, rps float64, burst int) {
+		if rps < 0 {
+			add(prefix+"_RPS", "must not be negative, got %v", rps)
+		}
+		if rps > 0 && burst < 1 {
+			add(prefix+"_BURST", "must be at least 1, got %d", burst)
+		}
+	}
+
+	required("SQL_DRIVER", c.SQLDriver)
+	required("SQL_CONNECTION_INFO", c.SQLConnectionInfo)
+	required("SQL_MIGRATION_INFO", c.SQLMigrationInfo)
+	required("HTTP_SERVER_ADDRESS", c.HTTPServerAddress)
+	positive("SQL_CONNECT_TIMEOUT", c.SQLConnectTimeout)
+	oneOf("RATE_LIMIT_BACKEND", c.RateLimitBackend, "memory", "postgres")
+	limit("RATE_LIMIT_IP", c.RateLimitIPRPS, c.RateLimitIPBurst)
+	limit("RATE_LIMIT_READ", c.RateLimitReadRPS, c.RateLimitReadBurst)
+	limit("RATE_LIMIT_WRITE", c.RateLimitWriteRPS, c.RateLimitWriteBurst)
+	limit("RATE_LIMIT_NEW", c.RateLimitNewRPS, c.RateLimitNewBurst)
+	if u, err := url.Parse(c.ArchiveURL); err != nil || u.Scheme == "" || u.Host == "" {
+		add("ARCHIVE_URL", "must be absolute URL, got %q", c.ArchiveURL)
+	}
+	positive("ARCHIVE_TIMEOUT", c.ArchiveTimeout)
+	oneOf("ARCHIVE_READINESS", c.ArchiveReadiness, "required", "optional", "ignore")
+	if c.StatsRefreshInterval < 0 {
+		add("STATS_REFRESH_INTERVAL", "must not be negative, got %s", c.StatsRefreshInterval)
+	}
+	oneOf("ATTACHMENTS_BACKEND", c.AttachmentsBackend, "local", "s3")
+	switch c.AttachmentsBackend {
+	case "local":
+		required("ATTACHMENTS_DIR", c.AttachmentsDir)
+	case "s3":
+		if u, err := url.Parse(c.AttachmentsS3Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			add("ATTACHMENTS_S3_ENDPOINT", "must be absolute URL, got %q", c.AttachmentsS3Endpoint)
+		}
+		required("ATTACHMENTS_S3_REGION", c.AttachmentsS3Region)
+		required("ATTACHMENTS_S3_BUCKET", c.AttachmentsS3Bucket)
+		required("ATTACHMENTS_S3_ACCESS_KEY", c.AttachmentsS3AccessKey)
+		required("ATTACHMENTS_S3_SECRET_KEY", c.AttachmentsS3SecretKey)
+	}
+	if c.AttachmentsMaxSize <= 0 {
+		add("ATTACHMENTS_MAX_SIZE", "must be positive, got %d", c.AttachmentsMaxSize)
+	}
+	positive("ATTACHMENTS_CLEANUP_INTERVAL", c.AttachmentsCleanupInterval)
+	if c.ServiceIntervalDistance < 0 {
+		add("SERVICE_INTERVAL_DISTANCE", "must not be negative, got %d", c.ServiceIntervalDistance)
+	}
+	if c.ServiceIntervalTime < 0 {
+		add("SERVICE_INTERVAL_TIME", "must not be negative, got %s", c.ServiceIntervalTime)
+	}
+	if c.ServiceIntervalDistance == 0 && c.ServiceIntervalTime == 0 {
+		add("SERVICE_INTERVAL_TIME", "must be positive when SERVICE_INTERVAL_DISTANCE is 0")
+	}
+	oneOf("TRACING_EXPORTER", c.TracingExporter, "none", "stdout", "otlp")
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		add("TRACING_SAMPLE_RATIO", "must be in [0, 1], got %v", c.TracingSampleRatio)
+	}
+
+	return problems
+}
+
+// Print writes config in .env format, secrets are redacted
+func (c *Config) Print(w io.Writer) error {
+	for _, f := range fieldsOf(c) {
+		value := f.get()
+		if f.secret {
+			value = redact(value)
+		}
+		if _, err := fmt.Fprintf(w, "%s=%q\n", f.env, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	dsnPassword = regexp.MustCompile(`(password=)('[^']*'|\S+)`)
+	urlPassword = regexp.MustCompile(`^([^:/@]+:)[^@]*(@)`)
+)
+
+// redact hides passwords of connection strings and other secrets completely
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	if dsnPassword.MatchString(value) {
+		return dsnPassword.ReplaceAllString(value, "${1}***")
+	}
+	if urlPassword.MatchString(value) {
+		return urlPassword.ReplaceAllString(value, "${1}***${2}")
+	}
+	return "***"
+}
+
+// readFile reads YAML file with flat mapping of keys or .env file.
+// Keys are environment variable names in any case
+func readFile(path string) (map[string]string, error) {
+	var values map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("config file: %w", err)
+		}
+		var raw map[string]interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+		values = make(map[string]string, len(raw))
+		for k, v := range raw {
+			switch v := v.(type) {
+			case map[string]interface{}, []interface{}:
+				return nil, fmt.Errorf("config file %s: %s must be scalar", path, k)
+			case nil:
+				values[k] = ""
+			default:
+				values[k] = fmt.Sprint(v)
+			}
+		}
+	default:
+		var err error
+		values, err = godotenv.Read(path)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+
+	upper := make(map[string]string, len(values))
+	for k, v := range values {
+		upper[strings.ToUpper(k)] = v
+	}
+	return upper, nil
+}
+
+type field struct {
+	env    string
+	flag   string
+	def    string
+	secret bool
+	value  reflect.Value
+}
+
+// fieldsOf describes tagged fields of c
+func fieldsOf(c *Config) []field {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	fields := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		env := sf.Tag.Get("env")
+		if env == "" || env == "-" {
+			continue
+		}
+		fields = append(fields, field{
+			env:    env,
+			flag:   sf.Tag.Get("flag"),
+			def:    sf.Tag.Get("default"),
+			secret: sf.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		})
+	}
+	return fields
+}
+
+// set parses s into field by its type, empty s is zero value
+func (f *field) set(s string) error {
+	switch f.value.Interface().(type) {
+	case string:
+		f.value.SetString(s)
+	case time.Duration:
+		var d time.Duration
+		if s != "" {
+			var err error
+			if d, err = time.ParseDuration(s); err != nil {
+				return fmt.Errorf("%s is not duration: %q", f.env, s)
+			}
+		}
+		f.value.SetInt(int64(d))
+	case int:
+		var i int
+		if s != "" {
+			var err error
+			if i, err = strconv.Atoi(s); err != nil {
+				return fmt.Errorf("%s is not integer: %q", f.env, s)
+			}
+		}
+		f.value.SetInt(int64(i))
+	case float64:
+		var n float64
+		if s != "" {
+			var err error
+			if n, err = strconv.ParseFloat(s, 64); err != nil {
+				return fmt.Errorf("%s is not number: %q", f.env, s)
+			}
+		}
+		f.value.SetFloat(n)
+	case bool:
+		var b bool
+		if s != "" {
+			var err error
+			if b, err = strconv.ParseBool(s); err != nil {
+				return fmt.Errorf("%s is not boolean: %q", f.env, s)
+			}
+		}
+		f.value.SetBool(b)
+	default:
+		panic("config: unsupported type of " + f.env)
+	}
+	return nil
+}
+
+func (f *field) get() string {
+	switch v := f.value.Interface().(type) {
+	case time.Duration:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPriority(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	file := `
+sql_connection_info: host=db password=secret
+SQL_MIGRATION_INFO: user:secret@db:5432/catalog
+rate_limit_read_rps: 4
+rate_limit_read_burst: 8
+archive_timeout: 2s
+sql_migrate_on_start: false
+`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(ConfigPathEnv, path)
+	t.Setenv("RATE_LIMIT_READ_RPS", "5")
+
+	cfg, err := Load([]string{"--rate-limit-read-burst", "9"})
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if cfg.SQLDriver != "postgres" {
+		t.Errorf("SQLDriver = %q, want default", cfg.SQLDriver)
+	}
+	if cfg.ArchiveTimeout != 2*time.Second || cfg.SQLMigrateOnStart {
+		t.Errorf("file values are not applied: %+v", cfg)
+	}
+	if cfg.RateLimitReadRPS != 5 {
+		t.Errorf("RateLimitReadRPS = %v, want 5 from environment", cfg.RateLimitReadRPS)
+	}
+	if cfg.RateLimitReadBurst != 9 {
+		t.Errorf("RateLimitReadBurst = %v, want 9 from flag", cfg.RateLimitReadBurst)
+	}
+}
+
+func TestLoadReportsAllProblems(t *testing.T) {
+	t.Setenv(ConfigPathEnv, "")
+	_, err := Load([]string{
+		"--archive-timeout", "soon",
+		"--tracing-exporter", "jaeger",
+		"--rate-limit-new-rps", "-1",
+	})
+	if err == nil {
+		t.Fatal("invalid config was loaded")
+	}
+
+	for _, want := range []string{
+		"ARCHIVE_TIMEOUT is not duration",
+		"SQL_CONNECTION_INFO is required",
+		"TRACING_EXPORTER must be one of",
+		"RATE_LIMIT_NEW_RPS must not be negative",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't report %q", err, want)
+		}
+	}
+	// Unparsed value is not validated once more
+	if strings.Contains(err.Error(), "ARCHIVE_TIMEOUT must be positive") {
+		t.Errorf("error %q reports ARCHIVE_TIMEOUT twice", err)
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg := Config{
+		SQLConnectionInfo: "host=db user=app password=secret dbname=catalog",
+		SQLMigrationInfo:  "app:secret@db:5432/catalog",
+		AuthJWTSecret:     "secret",
+	}
+	var buf bytes.Buffer
+	if err := cfg.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "secret") {
+		t.Fatalf("secret is printed:\n%s", out)
+	}
+	if !strings.Contains(out, `SQL_CONNECTION_INFO="host=db user=app password=*** dbname=catalog"`) {
+		t.Fatalf("connection info is not partially redacted:\n%s", out)
+	}
+}
@@ -0,0 +1,505 @@
+// Package catalog implements gRPC CatalogService over the same storage as HTTP handlers
+package catalog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"catalog/internal/grpc-handlers/catalogpb"
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/archive"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/tracing"
+	"catalog/internal/lib/vin"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/changefeed"
+	"catalog/internal/storage/entities"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Permissions are required by methods of CatalogService, the same as by HTTP routes
+var Permissions = map[string]auth.Permission{
+	catalogpb.CatalogService_ListCars_FullMethodName:  auth.PermRead,
+	catalogpb.CatalogService_GetCar_FullMethodName:    auth.PermRead,
+	catalogpb.CatalogService_WatchCars_FullMethodName: auth.PermRead,
+	catalogpb.CatalogService_CreateCar_FullMethodName: auth.PermWrite,
+	catalogpb.CatalogService_UpdateCar_FullMethodName: auth.PermWrite,
+	catalogpb.CatalogService_DeleteCar_FullMethodName: auth.PermWrite,
+}
+
+var (
+	errInternal        = status.Error(codes.Internal, "internal error")
+	errNotFound        = status.Error(codes.NotFound, "car not found")
+	errOutOfScope      = status.Error(codes.PermissionDenied, "region is out of scope")
+	errPageOutOfRange  = status.Error(codes.OutOfRange, "selected page in out of range")
+	errNothingToEdit   = status.Error(codes.InvalidArgument, "nothing to edit")
+	errArchiveNotFound = status.Error(codes.NotFound, "car not found in archive")
+	errArchive         = status.Error(codes.Unavailable, "archive is not available")
+	errVINExists       = status.Error(codes.AlreadyExists, "VIN already exists")
+	errStreamTags      = status.Error(codes.InvalidArgument, "tags filter is not supported by stream")
+)
+
+type Server struct {
+	catalogpb.UnimplementedCatalogServiceServer
+
+	log     *slog.Logger
+	storage *postgres.Storage
+	archive *archive.Client
+	feed    *changefeed.Feed
+}
+
+func New(log *slog.Logger, storage *postgres.Storage, archiveClient *archive.Client, feed *changefeed.Feed) *Server {
+	return &Server{log: log, storage: storage, archive: archiveClient, feed: feed}
+}
+
+// logger returns logger of RPC op
+func (s *Server) logger(ctx context.Context, op string) *slog.Logger {
+	return s.log.With(
+		slog.String("op", op),
+		slog.String("trace_id", tracing.TraceID(ctx)),
+		slog.String("principal", auth.GetPrincipalID(ctx)),
+	)
+}
+
+func (s *Server) ListCars(ctx context.Context, req *catalogpb.ListCarsRequest) (*catalogpb.ListCarsResponse, error) {
+	const op = "grpc.catalog.ListCars"
+
+	log := s.logger(ctx, op)
+
+	if req.Page < 0 {
+		log.Debug("negative page", slog.Int("page", int(req.Page)))
+		return nil, errPageOutOfRange
+	}
+
+	filter, err := carFilter(req.Filter)
+	if err != nil {
+		log.Debug("invalid filter", sl.Err(err))
+		return nil, err
+	}
+	if err := s.checkAttributes(ctx, log, &filter); err != nil {
+		return nil, err
+	}
+	var cp entities.CatalogPage
+	err = cp.GetCatalogPage(ctx, s.storage, &filter, int(req.Page), auth.GetScopeFromContext(ctx))
+	// Case with page in out of range
+	if errors.Is(err, entities.ErrPageOutOfRange) {
+		log.Debug("page is out of range", sl.Err(err))
+		return nil, errPageOutOfRange
+	}
+	if err != nil {
+		log.Error("failed to get catalog page", sl.Err(err))
+		return nil, errInternal
+	}
+
+	resp := &catalogpb.ListCarsResponse{
+		Cars: make([]*catalogpb.Car, 0, len(cp.Cars)),
+		Pagination: &catalogpb.Pagination{
+			Next:          int32(cp.Pagination.Next),
+			Previous:      int32(cp.Pagination.Previous),
+			RecordPerPage: int32(cp.Pagination.RecordPerPage),
+			CurrentPage:   int32(cp.Pagination.CurrentPage),
+			TotalPage:     int32(cp.Pagination.TotalPage),
+		},
+	}
+	for i := range cp.Cars {
+		resp.Cars = append(resp.Cars, protoCar(&cp.Cars[i]))
+	}
+	return resp, nil
+}
+
+func (s *Server) GetCar(ctx context.Context, req *catalogpb.GetCarRequest) (*catalogpb.Car, error) {
+	const op = "grpc.catalog.GetCar"
+
+	log := s.logger(ctx, op)
+
+	if req.CarId <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "car_id is required")
+	}
+
+	c, err := s.getCar(ctx, &entities.Car{CarID: int(req.CarId), Status: entities.StatusAny})
+	// Case with missing car or car outside of caller scope
+	if errors.Is(err, entities.ErrNotFound) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		log.Error("failed to get car", sl.Err(err))
+		return nil, errInternal
+	}
+	return c, nil
+}
+
+// getCar returns the newest car matching filter and visible for caller or ErrNotFound
+func (s *Server) getCar(ctx context.Context, filter *entities.Car) (*catalogpb.Car, error) {
+	var cp entities.CatalogPage
+	if err := cp.GetCatalogPage(ctx, s.storage, filter, 1, auth.GetScopeFromContext(ctx)); err != nil {
+		return nil, err
+	}
+	if len(cp.Cars) == 0 {
+		return nil, entities.ErrNotFound
+	}
+	return protoCar(&cp.Cars[0]), nil
+}
+
+func (s *Server) CreateCar(ctx context.Context, req *catalogpb.CreateCarRequest) (*catalogpb.Car, error) {
+	const op = "grpc.catalog.CreateCar"
+
+	log := s.logger(ctx, op)
+
+	if req.RegNum == "" {
+		return nil, status.Error(codes.InvalidArgument, "reg_num is required")
+	}
+	// Case with invalid VIN
+	carVIN, err := normalizeVIN(req.Vin)
+	if err != nil {
+		log.Debug("invalid VIN", sl.Err(err))
+		return nil, err
+	}
+
+	cr, err := s.archive.GetCar(ctx, req.RegNum)
+	// Case with unknown regNum
+	if errors.Is(err, archive.ErrNotFound) {
+		log.Info("car not found in archive", slog.String("regNum", req.RegNum))
+		return nil, errArchiveNotFound
+	}
+	// Case with unavailable archive or invalid archive response
+	if err != nil {
+		log.Error("failed to get car from archive", sl.Err(err))
+		return nil, errArchive
+	}
+
+	c := entities.Car{
+		RegNum:     cr.RegNum,
+		Mark:       cr.Mark,
+		Model:      cr.Model,
+		Year:       cr.Year,
+		Region:     req.Region,
+		VIN:        carVIN,
+		Attributes: attributesOf(req.Attributes),
+		Owner: entities.Person{
+			Name:       cr.Owner.Name,
+			Surname:    cr.Owner.Surname,
+			Patronymic: cr.Owner.Patronymic,
+		},
+	}
+	scope := auth.GetScopeFromContext(ctx)
+	if c.Region == "" && len(scope.Regions) == 1 {
+		c.Region = scope.Regions[0]
+	}
+
+	err = c.New(ctx, s.storage, scope)
+	// Case with region outside of caller scope
+	if errors.Is(err, entities.ErrOutOfScope) {
+		log.Debug("region is out of scope", sl.Err(err))
+		return nil, errOutOfScope
+	}
+	// Case with VIN of other car
+	if errors.Is(err, entities.ErrVINExists) {
+		log.Debug("VIN already exists", sl.Err(err))
+		return nil, errVINExists
+	}
+	// Case with attributes not matching schema of tenant
+	if errors.Is(err, entities.ErrInvalidAttributes) {
+		log.Debug("invalid attributes", sl.Err(err))
+		return nil, status.Error(codes.InvalidArgument, errors.Unwrap(err).Error())
+	}
+	if err != nil {
+		log.Error("failed to add new car in catalog", sl.Err(err))
+		return nil, errInternal
+	}
+
+	// The newest car with regNum is the added one
+	added, err := s.getCar(ctx, &entities.Car{RegNum: c.RegNum})
+	if err != nil {
+		log.Error("failed to get added car", sl.Err(err))
+		return nil, errInternal
+	}
+	return added, nil
+}
+
+func (s *Server) UpdateCar(ctx context.Context, req *catalogpb.UpdateCarRequest) (*catalogpb.Car, error) {
+	const op = "grpc.catalog.UpdateCar"
+
+	log := s.logger(ctx, op)
+
+	if req.CarId <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "car_id is required")
+	}
+	// Case with invalid VIN
+	carVIN, err := normalizeVIN(req.Vin)
+	if err != nil {
+		log.Debug("invalid VIN", sl.Err(err))
+		return nil, err
+	}
+
+	c := entities.Car{
+		CarID:      int(req.CarId),
+		RegNum:     req.RegNum,
+		Mark:       req.Mark,
+		Model:      req.Model,
+		Year:       int(req.Year),
+		Region:     req.Region,
+		VIN:        carVIN,
+		Attributes: attributesOf(req.Attributes),
+	}
+	if o := req.Owner; o != nil {
+		if o.Name == "" || o.Surname == "" {
+			return nil, status.Error(codes.InvalidArgument, "owner name and surname are required")
+		}
+		c.Owner = entities.Person{Name: o.Name, Surname: o.Surname, Patronymic: o.Patronymic}
+	}
+
+	err = c.Edit(ctx, s.storage, auth.GetScopeFromContext(ctx))
+	// Case with missing car or car outside of caller scope
+	if errors.Is(err, entities.ErrNotFound) {
+		log.Debug("car not found", sl.Err(err))
+		return nil, errNotFound
+	}
+	// Case with request changing nothing
+	if errors.Is(err, entities.ErrNothingToEdit) {
+		return nil, errNothingToEdit
+	}
+	// Case with moving car to region outside of caller scope
+	if errors.Is(err, entities.ErrOutOfScope) {
+		log.Debug("region is out of scope", sl.Err(err))
+		return nil, errOutOfScope
+	}
+	// Case with VIN of other car
+	if errors.Is(err, entities.ErrVINExists) {
+		log.Debug("VIN already exists", sl.Err(err))
+		return nil, errVINExists
+	}
+	// Case with attributes not matching schema of tenant
+	if errors.Is(err, entities.ErrInvalidAttributes) {
+		log.Debug("invalid attributes", sl.Err(err))
+		return nil, status.Error(codes.InvalidArgument, errors.Unwrap(err).Error())
+	}
+	if err != nil {
+		log.Error("failed to edit car", sl.Err(err))
+		return nil, errInternal
+	}
+
+	edited, err := s.getCar(ctx, &entities.Car{CarID: c.CarID, Status: entities.StatusAny})
+	if err != nil {
+		log.Error("failed to get edited car", sl.Err(err))
+		return nil, errInternal
+	}
+	return edited, nil
+}
+
+func (s *Server) DeleteCar(ctx context.Context, req *catalogpb.DeleteCarRequest) (*catalogpb.DeleteCarResponse, error) {
+	const op = "grpc.catalog.DeleteCar"
+
+	log := s.logger(ctx, op)
+
+	if req.CarId <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "car_id is required")
+	}
+
+	var c *entities.Car
+	err := c.Delete(ctx, s.storage, int(req.CarId), auth.GetScopeFromContext(ctx))
+	// Case with missing car or car outside of caller scope
+	if errors.Is(err, entities.ErrNotFound) {
+		log.Debug("car not found", sl.Err(err))
+		return nil, errNotFound
+	}
+	if err != nil {
+		log.Error("failed to delete car", sl.Err(err))
+		return nil, errInternal
+	}
+	return &catalogpb.DeleteCarResponse{}, nil
+}
+
+func (s *Server) WatchCars(req *catalogpb.WatchCarsRequest, stream catalogpb.CatalogService_WatchCarsServer) error {
+	const op = "grpc.catalog.WatchCars"
+
+	ctx := stream.Context()
+	log := s.logger(ctx, op)
+
+	filter, err := carFilter(req.Filter)
+	if err != nil {
+		log.Debug("invalid filter", sl.Err(err))
+		return err
+	}
+	// Changes don't carry tags of cars
+	if filter.Tags != nil {
+		return errStreamTags
+	}
+	if err := s.checkAttributes(ctx, log, &filter); err != nil {
+		return err
+	}
+	scope := auth.GetScopeFromContext(ctx)
+	lastID := req.LastChangeId
+
+	// Subscribe before catch up, so changes made meanwhile are not lost
+	changes, unsubscribe := s.feed.Subscribe()
+	defer unsubscribe()
+
+	log.Info("stream opened", slog.Int64("last_change_id", lastID))
+
+	// Send changes missed since last_change_id
+	if lastID > 0 {
+		var sendErr error
+		lastID, err = changefeed.CatchUp(ctx, s.storage, lastID, scope, func(ccs entities.CarChanges) error {
+			for i := range ccs {
+				if sendErr = sendChange(stream, &ccs[i], &filter, &scope); sendErr != nil {
+					return sendErr
+				}
+			}
+			return nil
+		})
+		if sendErr != nil {
+			log.Debug("failed to send change", sl.Err(sendErr))
+			return sendErr
+		}
+		if err != nil {
+			log.Error("failed to read change log", sl.Err(err))
+			return errInternal
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("stream closed")
+			return nil
+		case cc, ok := <-changes:
+			if !ok {
+				log.Info("stream closed by feed")
+				return status.Error(codes.Unavailable, "stream is closed, resume from the last change")
+			}
+			// Skip changes already sent on catch up
+			if cc.ChangeID <= lastID {
+				continue
+			}
+			if err := sendChange(stream, &cc, &filter, &scope); err != nil {
+				log.Debug("failed to send change", sl.Err(err))
+				return err
+			}
+			lastID = cc.ChangeID
+		}
+	}
+}
+
+// sendChange sends change if car fits filter and scope
+func sendChange(stream catalogpb.CatalogService_WatchCarsServer, cc *entities.CarChange, filter *entities.Car, scope *entities.Scope) error {
+	if cc.TenantID != scope.TenantID || !cc.Car.Matches(filter) || !scope.Allows(cc.Car.Region) {
+		return nil
+	}
+
+	return stream.Send(&catalogpb.CarChange{
+		ChangeId:  cc.ChangeID,
+		Op:        protoOps[cc.Op],
+		Car:       protoCar(&cc.Car),
+		ChangedAt: timestamppb.New(cc.ChangedAt),
+	})
+}
+
+var protoOps = map[string]catalogpb.CarChange_Op{
+	entities.OpInsert: catalogpb.CarChange_OP_INSERT,
+	entities.OpUpdate: catalogpb.CarChange_OP_UPDATE,
+	entities.OpDelete: catalogpb.CarChange_OP_DELETE,
+}
+
+// carFilter makes catalog filter, missing filter matches everything.
+// Invalid filter gives InvalidArgument error
+func carFilter(f *catalogpb.CarFilter) (entities.Car, error) {
+	var c entities.Car
+	if f == nil {
+		return c, nil
+	}
+	c = entities.Car{
+		CarID:  int(f.CarId),
+		RegNum: f.RegNum,
+		Mark:   f.Mark,
+		Model:  f.Model,
+		Year:   int(f.Year),
+		Region: f.Region,
+		VIN:    vin.Normalize(f.Vin),
+		Status: f.Status,
+	}
+	if c.Status != "" && c.Status != entities.StatusAny && !entities.IsCarStatus(c.Status) {
+		return c, status.Errorf(codes.InvalidArgument, "unknown status %q", c.Status)
+	}
+	tags, err := entities.NewTagFilter(f.Tags, f.AllTags)
+	if err != nil {
+		return c, status.Error(codes.InvalidArgument, err.Error())
+	}
+	c.Tags = tags
+	c.Attributes = attributesOf(f.Attributes)
+	if o := f.Owner; o != nil {
+		c.Owner = entities.Person{
+			PersonID:   int(o.PersonId),
+			Name:       o.Name,
+			Surname:    o.Surname,
+			Patronymic: o.Patronymic,
+		}
+	}
+	return c, nil
+}
+
+// checkAttributes validates attributes of filter against schema of caller tenant
+func (s *Server) checkAttributes(ctx context.Context, log *slog.Logger, filter *entities.Car) error {
+	if filter.Attributes == nil {
+		return nil
+	}
+	var schema entities.AttributeSchema
+	if err := schema.Get(ctx, s.storage, auth.GetScopeFromContext(ctx).TenantID); err != nil {
+		log.Error("failed to get attribute schema", sl.Err(err))
+		return errInternal
+	}
+	// Case with unknown attribute or value of wrong type
+	if err := schema.ValidateFilter(filter.Attributes); err != nil {
+		log.Debug("invalid attributes filter", sl.Err(err))
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+// attributesOf converts s to attributes, empty s gives nil
+func attributesOf(s *structpb.Struct) entities.Attributes {
+	if len(s.GetFields()) == 0 {
+		return nil
+	}
+	return s.AsMap()
+}
+
+// normalizeVIN returns normalized v or InvalidArgument error, empty v is kept
+func normalizeVIN(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	v = vin.Normalize(v)
+	if err := vin.Validate(v); err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	return v, nil
+}
+
+func protoCar(c *entities.Car) *catalogpb.Car {
+	pc := &catalogpb.Car{
+		CarId:  int32(c.CarID),
+		RegNum: c.RegNum,
+		Mark:   c.Mark,
+		Model:  c.Model,
+		Year:   int32(c.Year),
+		Region: c.Region,
+		Vin:    c.VIN,
+		Status: c.Status,
+		Owner: &catalogpb.Person{
+			PersonId:   int32(c.Owner.PersonID),
+			Name:       c.Owner.Name,
+			Surname:    c.Owner.Surname,
+			Patronymic: c.Owner.Patronymic,
+		},
+	}
+	if len(c.Attributes) > 0 {
+		// Attributes are decoded from JSON, so every value converts
+		pc.Attributes, _ = structpb.NewStruct(c.Attributes)
+	}
+	return pc
+}
@@ -0,0 +1,4 @@
+// Package catalogpb is generated code of catalog.proto, regenerate it after changing the proto
+package catalogpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative catalog.proto
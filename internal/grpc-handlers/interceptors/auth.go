@@ -0,0 +1,84 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/tracing"
+	postgres "catalog/internal/storage"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Auth authenticates RPC by the same credentials as HTTP API, resolves its tenant and
+// checks permission of method. Methods missing in methods, e.g. health and reflection,
+// are not authenticated, the same as probes
+func Auth(log *slog.Logger, storage *postgres.Storage, verifier *auth.Verifier, methods map[string]auth.Permission) Interceptor {
+	return around(func(ctx context.Context, method string, call func(ctx context.Context) error) error {
+		const op = "interceptors.Auth"
+
+		perm, ok := methods[method]
+		if !ok {
+			return call(ctx)
+		}
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("method", method),
+			slog.String("trace_id", tracing.TraceID(ctx)),
+		)
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		token := first(md, "x-api-key")
+		if token == "" {
+			token = auth.BearerToken(first(md, "authorization"))
+		}
+
+		p, err := auth.Authenticate(ctx, storage, verifier, token)
+		// Case with missing or wrong credentials
+		if errors.Is(err, auth.ErrNoCredentials) || errors.Is(err, auth.ErrInvalidCredentials) {
+			log.Info("unauthenticated request", sl.Err(err))
+			return status.Error(codes.Unauthenticated, err.Error())
+		}
+		// Case with storage error
+		if err != nil {
+			log.Error("failed to authenticate request", sl.Err(err))
+			return status.Error(codes.Internal, "internal error")
+		}
+		log = log.With(slog.String("principal", p.ID))
+
+		requested := first(md, strings.ToLower(auth.TenantHeader))
+		tenantID, err := auth.TenantOf(p, requested)
+		// Case with principal of another tenant
+		if errors.Is(err, auth.ErrTenantMismatch) {
+			log.Info("tenant mismatch", slog.String("tenant", requested))
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+		// Case with platform principal without selected tenant
+		if err != nil {
+			log.Info("tenant is not set")
+			return status.Error(codes.InvalidArgument, strings.ToLower(auth.TenantHeader)+" metadata is required")
+		}
+
+		if !p.Can(perm) {
+			log.Info("permission denied", slog.String("permission", string(perm)))
+			return status.Error(codes.PermissionDenied, "permission denied")
+		}
+
+		return call(auth.WithTenantID(auth.WithPrincipal(ctx, p), tenantID))
+	})
+}
+
+// first returns the first value of metadata key or empty string
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+// Package interceptors is gRPC counterpart of HTTP middleware: logging, tracing,
+// authentication and rate limits work the same way for both APIs
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"catalog/internal/lib/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Interceptor handles one concern for both unary and streaming RPCs
+type Interceptor struct {
+	Unary  grpc.UnaryServerInterceptor
+	Stream grpc.StreamServerInterceptor
+}
+
+// Chain returns server options applying interceptors in order, the first is the outermost
+func Chain(interceptors ...Interceptor) []grpc.ServerOption {
+	unary := make([]grpc.UnaryServerInterceptor, 0, len(interceptors))
+	stream := make([]grpc.StreamServerInterceptor, 0, len(interceptors))
+	for _, i := range interceptors {
+		unary = append(unary, i.Unary)
+		stream = append(stream, i.Stream)
+	}
+	return []grpc.ServerOption{grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...)}
+}
+
+// serverStream is stream with context changed by interceptor
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// around makes Interceptor of fn wrapping handler call with context of RPC
+func around(fn func(ctx context.Context, method string, call func(ctx context.Context) error) error) Interceptor {
+	return Interceptor{
+		Unary: func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			var resp any
+			err := fn(ctx, info.FullMethod, func(ctx context.Context) error {
+				var err error
+				resp, err = handler(ctx, req)
+				return err
+			})
+			return resp, err
+		},
+		Stream: func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return fn(ss.Context(), info.FullMethod, func(ctx context.Context) error {
+				return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+			})
+		},
+	}
+}
+
+// Logger logs every RPC with its code and duration and turns panics into Internal errors
+func Logger(log *slog.Logger) Interceptor {
+	return around(func(ctx context.Context, method string, call func(ctx context.Context) error) (err error) {
+		start := time.Now()
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Error("panic in RPC", slog.String("method", method), slog.Any("panic", rec),
+					slog.String("stack", string(debug.Stack())))
+				err = status.Error(codes.Internal, "internal error")
+			}
+			log.Info("RPC completed",
+				slog.String("method", method),
+				slog.String("trace_id", tracing.TraceID(ctx)),
+				slog.String("code", status.Code(err).String()),
+				slog.Duration("duration", time.Since(start)),
+			)
+		}()
+		return call(ctx)
+	})
+}
+
+// Tracing starts server span of every RPC continuing trace of incoming traceparent metadata
+func Tracing() Interceptor {
+	return around(func(ctx context.Context, method string, call func(ctx context.Context) error) error {
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+		ctx, span := tracing.Start(ctx, method, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.String("rpc.system", "grpc"), attribute.String("rpc.method", method)))
+		err := call(ctx)
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", status.Code(err).String()))
+		tracing.End(span, err)
+		return err
+	})
+}
+
+// metadataCarrier adapts gRPC metadata to propagation.TextMapCarrier, keys are lower case
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,88 @@
+package interceptors
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/http-handlers/middleware/ratelimit"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func call(i Interceptor, ctx context.Context, method string) (context.Context, error) {
+	var got context.Context
+	_, err := i.Unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+		got = ctx
+		return nil, nil
+	})
+	return got, err
+}
+
+func TestAuth(t *testing.T) {
+	verifier, err := auth.NewVerifier("secret", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := Auth(discard, nil, verifier, map[string]auth.Permission{"/svc/Read": auth.PermRead})
+
+	tests := []struct {
+		name     string
+		method   string
+		metadata []string
+		want     codes.Code
+	}{
+		{name: "public method", method: "/grpc.health.v1.Health/Check", want: codes.OK},
+		{name: "no credentials", method: "/svc/Read", want: codes.Unauthenticated},
+		{name: "wrong scheme", method: "/svc/Read", metadata: []string{"authorization", "Basic abc"}, want: codes.Unauthenticated},
+		{name: "invalid token", method: "/svc/Read", metadata: []string{"authorization", "Bearer abc"}, want: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tt.metadata...))
+			if _, err := call(i, ctx, tt.method); status.Code(err) != tt.want {
+				t.Fatalf("code = %v, want %v", status.Code(err), tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	i := RateLimits(discard, ratelimit.NewMemory(), map[string]RateLimit{
+		"/svc/Write": {Group: "write", Limit: ratelimit.Limit{Rate: 0.001, Burst: 2}},
+	})
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{ID: "api_key:1"})
+
+	for n := 1; n <= 3; n++ {
+		_, err := call(i, ctx, "/svc/Write")
+		want := codes.OK
+		if n == 3 {
+			want = codes.ResourceExhausted
+		}
+		if status.Code(err) != want {
+			t.Fatalf("call %d: code = %v, want %v", n, status.Code(err), want)
+		}
+	}
+	// Methods without limit are not counted
+	if _, err := call(i, ctx, "/svc/Read"); err != nil {
+		t.Fatalf("unlimited method: %v", err)
+	}
+}
+
+func TestMetadataCarrier(t *testing.T) {
+	c := metadataCarrier(metadata.Pairs("traceparent", "00-abc-def-01"))
+	if got := c.Get("traceparent"); got != "00-abc-def-01" {
+		t.Fatalf("Get = %q", got)
+	}
+	c.Set("Tracestate", "k=v")
+	if got := c.Get("tracestate"); got != "k=v" {
+		t.Fatalf("Get after Set = %q", got)
+	}
+}
@@ -0,0 +1,90 @@
+package interceptors
+
+import (
+	"context"
+	"log/slog"
+	"net"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/http-handlers/middleware/ratelimit"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/tracing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RateLimit is limit of route group method belongs to. Buckets are shared with HTTP
+// routes of the same group, so switching API doesn't give more requests
+type RateLimit struct {
+	Group string
+	Limit ratelimit.Limit
+}
+
+// RateLimits limits RPCs of methods by principal, the same as ratelimit.New.
+// Limiter errors let requests through
+func RateLimits(log *slog.Logger, limiter ratelimit.Limiter, methods map[string]RateLimit) Interceptor {
+	return around(func(ctx context.Context, method string, call func(ctx context.Context) error) error {
+		rl, ok := methods[method]
+		if !ok {
+			return call(ctx)
+		}
+
+		key := auth.GetPrincipalID(ctx)
+		if key == "" {
+			key = "ip:" + peerIP(ctx)
+		}
+		return take(ctx, log, limiter, rl, key, call)
+	})
+}
+
+// RateLimitByIP limits all RPCs by peer IP, the same as ratelimit.ByIP. It goes before Auth,
+// so calls with wrong credentials are limited too
+func RateLimitByIP(log *slog.Logger, limiter ratelimit.Limiter, rl RateLimit) Interceptor {
+	return around(func(ctx context.Context, method string, call func(ctx context.Context) error) error {
+		return take(ctx, log, limiter, rl, "ip:"+peerIP(ctx), call)
+	})
+}
+
+func take(ctx context.Context, log *slog.Logger, limiter ratelimit.Limiter, rl RateLimit, key string, call func(ctx context.Context) error) error {
+	const op = "interceptors.RateLimits"
+
+	if rl.Limit.Disabled() {
+		return call(ctx)
+	}
+
+	res, err := limiter.Take(ctx, rl.Group+":"+key, rl.Limit)
+	if err != nil {
+		log.Error("failed to take rate limit token",
+			slog.String("op", op),
+			slog.String("trace_id", tracing.TraceID(ctx)),
+			sl.Err(err),
+		)
+		return call(ctx)
+	}
+
+	if !res.Allowed {
+		log.Info("rate limit exceeded",
+			slog.String("op", op),
+			slog.String("trace_id", tracing.TraceID(ctx)),
+			slog.String("group", rl.Group),
+			slog.String("key", key),
+		)
+		return status.Error(codes.ResourceExhausted, "too many requests")
+	}
+
+	return call(ctx)
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,222 @@
+package apikeys
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/tracing"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/entities"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+type Request struct {
+	Name string `json:"name" validate:"required"`
+	Role string `json:"role" validate:"required,oneof=viewer editor admin"`
+	// Regions limit cars visible by key. Null means all regions
+	Regions []string `json:"regions"`
+}
+
+// Response carries plaintext key. It is shown only once on create or rotate
+type Response struct {
+	entities.APIKey
+	Key string `json:"key"`
+}
+
+// New creates API key in request tenant
+func New(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikeys.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		var req Request
+
+		// Decode request JSON
+		err := render.DecodeJSON(r.Body, &req)
+		// Case with empty request
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			w.WriteHeader(400)
+			return
+		}
+		// Case with common errors
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		// Validate request JSON
+		if err := validator.New().Struct(req); err != nil {
+			w.WriteHeader(400)
+			log.Error("invalid request", sl.Err(err))
+			return
+		}
+
+		// Case with key seeing regions out of caller scope
+		scope := auth.GetScope(r)
+		if !scope.Covers(req.Regions) {
+			log.Info("regions of key are out of caller scope", slog.Any("regions", req.Regions))
+			w.WriteHeader(403)
+			render.JSON(w, r, "Error: regions of key are out of caller scope")
+			return
+		}
+
+		k := entities.APIKey{
+			Name:      req.Name,
+			Role:      req.Role,
+			Regions:   req.Regions,
+			TenantID:  auth.GetTenantID(r.Context()),
+			CreatedBy: auth.GetPrincipalID(r.Context()),
+		}
+		key, err := k.New(r.Context(), storage)
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to create API key", sl.Err(err))
+			return
+		}
+
+		log.Info("API key created", slog.Int("key_id", k.KeyID))
+
+		render.Status(r, 201)
+		render.JSON(w, r, Response{APIKey: k, Key: key})
+	}
+}
+
+// List returns API keys of request tenant without plaintext
+func List(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikeys.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		ks := entities.APIKeys{}
+		if err := ks.Get(r.Context(), storage, auth.GetTenantID(r.Context())); err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to get API keys", sl.Err(err))
+			return
+		}
+
+		render.JSON(w, r, ks)
+	}
+}
+
+// Revoke disables API key {id}
+func Revoke(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikeys.Revoke"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		keyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to make int key id", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		var k entities.APIKey
+		err = k.Revoke(r.Context(), storage, keyID, auth.GetTenantID(r.Context()))
+		// Case with unknown or already revoked key
+		if errors.Is(err, entities.ErrNotFound) {
+			log.Info("API key not found", slog.Int("key_id", keyID))
+			w.WriteHeader(404)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to revoke API key", sl.Err(err))
+			return
+		}
+
+		log.Info("API key revoked", slog.Int("key_id", keyID))
+	}
+}
+
+// Rotate revokes API key {id} and returns its replacement
+func Rotate(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikeys.Rotate"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		keyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to make int key id", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		// Replacement has the same regions, so they must be in caller scope too
+		var old entities.APIKey
+		err = old.Get(r.Context(), storage, keyID)
+		// Case with unknown key or key of another tenant
+		if errors.Is(err, entities.ErrNotFound) || err == nil && old.TenantID != auth.GetTenantID(r.Context()) {
+			log.Info("API key not found", slog.Int("key_id", keyID))
+			w.WriteHeader(404)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to get API key", sl.Err(err))
+			return
+		}
+		// Case with key seeing regions out of caller scope
+		scope := auth.GetScope(r)
+		if !scope.Covers(old.Regions) {
+			log.Info("regions of key are out of caller scope", slog.Int("key_id", keyID))
+			w.WriteHeader(403)
+			render.JSON(w, r, "Error: regions of key are out of caller scope")
+			return
+		}
+
+		var k entities.APIKey
+		key, err := k.Rotate(r.Context(), storage, keyID, auth.GetTenantID(r.Context()), auth.GetPrincipalID(r.Context()))
+		// Case with unknown or already revoked key
+		if errors.Is(err, entities.ErrNotFound) {
+			log.Info("API key not found", slog.Int("key_id", keyID))
+			w.WriteHeader(404)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to rotate API key", sl.Err(err))
+			return
+		}
+
+		log.Info("API key rotated", slog.Int("old_key_id", keyID), slog.Int("key_id", k.KeyID))
+
+		render.Status(r, 201)
+		render.JSON(w, r, Response{APIKey: k, Key: key})
+	}
+}
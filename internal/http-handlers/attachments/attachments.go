@@ -0,0 +1,410 @@
+package attachments
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/blob"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/thumbnail"
+	"catalog/internal/lib/tracing"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/entities"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// AllowedTypes are content types of accepted files. Type is sniffed from content,
+// type declared by client is ignored
+var AllowedTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp", "image/heic", "application/pdf"}
+
+const (
+	// ThumbnailSize is maximum width and height of thumbnails
+	ThumbnailSize = 256
+	// formOverhead is allowed size of multipart form besides file
+	formOverhead = 64 << 10
+	maxFileName  = 255
+)
+
+// Upload adds file of multipart form field "file" to car {id}. Optional field "kind"
+// is one of entities.AttachmentKinds, other by default. Files larger than maxSize
+// are rejected
+func Upload(log *slog.Logger, storage *postgres.Storage, blobs blob.Storage, maxSize int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.attachments.Upload"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		carID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to make int car id", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize+formOverhead)
+		err = r.ParseMultipartForm(maxSize)
+		// Case with too large request
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			log.Info("request body is too large", sl.Err(err))
+			w.WriteHeader(413)
+			return
+		}
+		if err != nil {
+			log.Error("failed to parse multipart form", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		file, header, err := r.FormFile("file")
+		// Case with form without file
+		if err != nil {
+			log.Error("failed to get file of form", sl.Err(err))
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: file is required")
+			return
+		}
+		defer file.Close()
+		// Case with too large file in small enough form
+		if header.Size > maxSize {
+			log.Info("file is too large", slog.Int64("size", header.Size))
+			w.WriteHeader(413)
+			return
+		}
+
+		kind := r.FormValue("kind")
+		if kind == "" {
+			kind = entities.AttachmentOther
+		}
+		if !isKind(kind) {
+			log.Error("unknown attachment kind", slog.String("kind", kind))
+			w.WriteHeader(400)
+			render.JSON(w, r, fmt.Sprintf("Error: kind must be one of %s", strings.Join(entities.AttachmentKinds, ", ")))
+			return
+		}
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			log.Error("failed to read file", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		contentType := sniff(data)
+		// Case with not allowed file type
+		if contentType == "" {
+			log.Info("file type is not allowed", slog.String("type", mimetype.Detect(data).String()))
+			w.WriteHeader(415)
+			render.JSON(w, r, "Error: file type must be one of "+strings.Join(AllowedTypes, ", "))
+			return
+		}
+
+		var thumb []byte
+		if strings.HasPrefix(contentType, "image/") {
+			thumb, err = thumbnail.Make(data, ThumbnailSize)
+			switch {
+			// Case with image format without decoder, e.g. WebP
+			case errors.Is(err, thumbnail.ErrUnsupported):
+				log.Debug("thumbnail is not made", sl.Err(err))
+			// Case with broken image
+			case err != nil:
+				log.Info("failed to make thumbnail", sl.Err(err))
+				w.WriteHeader(400)
+				render.JSON(w, r, "Error: invalid image")
+				return
+			}
+		}
+
+		a := entities.Attachment{
+			CarID:       carID,
+			Kind:        kind,
+			FileName:    fileName(header.Filename),
+			ContentType: contentType,
+			Size:        int64(len(data)),
+			BlobKey:     blobKey(carID),
+			CreatedBy:   auth.GetPrincipalID(r.Context()),
+		}
+		if thumb != nil {
+			a.ThumbnailKey = a.BlobKey + "-thumb"
+		}
+
+		err = put(r.Context(), log, blobs, &a, data, thumb)
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to put attachment into blob storage", sl.Err(err))
+			return
+		}
+
+		err = a.New(r.Context(), storage, auth.GetScope(r))
+		if err != nil {
+			// Blobs without attachment would never be deleted
+			removeBlobs(log, blobs, &a)
+		}
+		// Case with missing car or car outside of caller scope
+		if errors.Is(err, entities.ErrNotFound) {
+			w.WriteHeader(404)
+			log.Debug("car not found", sl.Err(err))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to add attachment", sl.Err(err))
+			return
+		}
+
+		log.Info("attachment added", slog.Int("attachment_id", a.AttachmentID), slog.Int64("size", a.Size))
+
+		render.Status(r, 201)
+		render.JSON(w, r, a)
+	}
+}
+
+// List returns attachments of car {id}
+func List(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.attachments.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		carID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to make int car id", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		as := entities.Attachments{}
+		err = as.Get(r.Context(), storage, carID, auth.GetScope(r))
+		// Case with missing car or car outside of caller scope
+		if errors.Is(err, entities.ErrNotFound) {
+			w.WriteHeader(404)
+			log.Debug("car not found", sl.Err(err))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to get attachments", sl.Err(err))
+			return
+		}
+
+		render.JSON(w, r, as)
+	}
+}
+
+// Download returns content of attachment {attachmentId} of car {id}
+func Download(log *slog.Logger, storage *postgres.Storage, blobs blob.Storage) http.HandlerFunc {
+	return serve(log, "handlers.attachments.Download", storage, blobs, false)
+}
+
+// Thumbnail returns JPEG thumbnail of image attachment {attachmentId} of car {id}
+func Thumbnail(log *slog.Logger, storage *postgres.Storage, blobs blob.Storage) http.HandlerFunc {
+	return serve(log, "handlers.attachments.Thumbnail", storage, blobs, true)
+}
+
+// serve writes attachment or its thumbnail
+func serve(log *slog.Logger, op string, storage *postgres.Storage, blobs blob.Storage, thumb bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		carID, attachmentID, err := ids(r)
+		if err != nil {
+			log.Error("failed to make int ids", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		var a entities.Attachment
+		err = a.Get(r.Context(), storage, carID, attachmentID, auth.GetScope(r))
+		// Case with missing attachment or car outside of caller scope
+		if errors.Is(err, entities.ErrNotFound) {
+			w.WriteHeader(404)
+			log.Debug("attachment not found", sl.Err(err))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to get attachment", sl.Err(err))
+			return
+		}
+
+		key, contentType := a.BlobKey, a.ContentType
+		if thumb {
+			// Case with document or image without decoder
+			if a.ThumbnailKey == "" {
+				w.WriteHeader(404)
+				log.Debug("attachment has no thumbnail")
+				return
+			}
+			key, contentType = a.ThumbnailKey, thumbnail.ContentType
+		}
+
+		rc, err := blobs.Get(r.Context(), key)
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to get blob", slog.String("key", key), sl.Err(err))
+			return
+		}
+		defer rc.Close()
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if !thumb {
+			w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.FileName}))
+		}
+		if _, err := io.Copy(w, rc); err != nil {
+			log.Error("failed to write blob", slog.String("key", key), sl.Err(err))
+		}
+	}
+}
+
+// Delete deletes attachment {attachmentId} of car {id}. Blobs are removed from blob
+// storage later by cleanup
+func Delete(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.attachments.Delete"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		carID, attachmentID, err := ids(r)
+		if err != nil {
+			log.Error("failed to make int ids", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		var a entities.Attachment
+		err = a.Delete(r.Context(), storage, carID, attachmentID, auth.GetScope(r))
+		// Case with missing attachment or car outside of caller scope
+		if errors.Is(err, entities.ErrNotFound) {
+			w.WriteHeader(404)
+			log.Debug("attachment not found", sl.Err(err))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to delete attachment", sl.Err(err))
+			return
+		}
+
+		log.Info("attachment deleted", slog.Int("attachment_id", attachmentID))
+	}
+}
+
+func ids(r *http.Request) (int, int, error) {
+	carID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return 0, 0, err
+	}
+	attachmentID, err := strconv.Atoi(chi.URLParam(r, "attachmentId"))
+	if err != nil {
+		return 0, 0, err
+	}
+	return carID, attachmentID, nil
+}
+
+func isKind(kind string) bool {
+	for _, k := range entities.AttachmentKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// sniff returns allowed content type of data or empty string
+func sniff(data []byte) string {
+	mt := mimetype.Detect(data)
+	for _, t := range AllowedTypes {
+		if mt.Is(t) {
+			return t
+		}
+	}
+	return ""
+}
+
+// fileName drops directories of client path, they are sent by some browsers
+func fileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		name = "file"
+	}
+	if len(name) > maxFileName {
+		name = name[:maxFileName]
+	}
+	return name
+}
+
+// blobKey returns new random key of blob of car
+func blobKey(carID int) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("attachments: failed to read random bytes: " + err.Error())
+	}
+	return fmt.Sprintf("cars/%d/%s", carID, hex.EncodeToString(b))
+}
+
+// put stores blobs of attachment, nothing is left if one of them fails
+func put(ctx context.Context, log *slog.Logger, blobs blob.Storage, a *entities.Attachment, data, thumb []byte) error {
+	if err := blobs.Put(ctx, a.BlobKey, bytes.NewReader(data), int64(len(data)), a.ContentType); err != nil {
+		return err
+	}
+	if thumb == nil {
+		return nil
+	}
+	err := blobs.Put(ctx, a.ThumbnailKey, bytes.NewReader(thumb), int64(len(thumb)), thumbnail.ContentType)
+	if err != nil {
+		removeBlobs(log, blobs, &entities.Attachment{BlobKey: a.BlobKey})
+	}
+	return err
+}
+
+// removeBlobs deletes blobs of attachment which isn't added. Request can be canceled
+// already, so its context isn't used
+func removeBlobs(log *slog.Logger, blobs blob.Storage, a *entities.Attachment) {
+	for _, key := range []string{a.BlobKey, a.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := blobs.Delete(context.Background(), key); err != nil {
+			log.Error("failed to delete blob", slog.String("key", key), sl.Err(err))
+		}
+	}
+}
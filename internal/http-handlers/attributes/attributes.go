@@ -0,0 +1,142 @@
+package attributes
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/tracing"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/entities"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Request is definition of attribute named by path, see entities.AttributeDef
+type Request struct {
+	Type     string   `json:"type"`
+	Required bool     `json:"required,omitempty"`
+	Enum     []string `json:"enum,omitempty"`
+	Pattern  string   `json:"pattern,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+}
+
+// List returns attribute schema of request tenant
+func List(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.attributes.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		var schema entities.AttributeSchema
+		if err := schema.Get(r.Context(), storage, auth.GetTenantID(r.Context())); err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to get attribute schema", sl.Err(err))
+			return
+		}
+
+		render.JSON(w, r, schema)
+	}
+}
+
+// Put creates or replaces definition of attribute {name}. Values of existing cars are
+// checked only when they are edited
+func Put(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.attributes.Put"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		var req Request
+
+		// Decode request JSON
+		err := render.DecodeJSON(r.Body, &req)
+		// Case with empty request
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			w.WriteHeader(400)
+			return
+		}
+		// Case with common errors
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+
+		d := entities.AttributeDef{
+			Name:     chi.URLParam(r, "name"),
+			Type:     req.Type,
+			Required: req.Required,
+			Enum:     req.Enum,
+			Pattern:  req.Pattern,
+			Min:      req.Min,
+			Max:      req.Max,
+		}
+		err = d.Save(r.Context(), storage, auth.GetTenantID(r.Context()), auth.GetPrincipalID(r.Context()))
+		// Case with invalid definition
+		if errors.Is(err, entities.ErrInvalidAttributeDef) {
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: "+errors.Unwrap(err).Error())
+			log.Debug("invalid attribute definition", sl.Err(err))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to save attribute definition", sl.Err(err))
+			return
+		}
+
+		log.Info("attribute definition saved", slog.String("name", d.Name))
+
+		render.JSON(w, r, d)
+	}
+}
+
+// Delete deletes definition of attribute {name} and its values of all cars
+func Delete(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.attributes.Delete"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		name := chi.URLParam(r, "name")
+		err := entities.DeleteAttributeDef(r.Context(), storage, auth.GetTenantID(r.Context()), name)
+		// Case with unknown attribute
+		if errors.Is(err, entities.ErrNotFound) {
+			w.WriteHeader(404)
+			log.Debug("attribute not found", sl.Err(err))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to delete attribute definition", sl.Err(err))
+			return
+		}
+
+		log.Info("attribute definition deleted", slog.String("name", name))
+	}
+}
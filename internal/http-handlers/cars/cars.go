@@ -0,0 +1,157 @@
+package cars
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/tracing"
+	"catalog/internal/lib/vin"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/entities"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+// VINResponse is car with data decoded from its VIN. Mismatches are fields of car
+// contradicting decoded data, see vin.Info.Mismatches
+type VINResponse struct {
+	Car        entities.Car `json:"car"`
+	Decoded    vin.Info     `json:"decoded"`
+	Mismatches []string     `json:"mismatches"`
+}
+
+// ByVIN returns car with VIN {vin}
+func ByVIN(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.cars.ByVIN"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		v := vin.Normalize(chi.URLParam(r, "vin"))
+		// Case with invalid VIN
+		if err := vin.Validate(v); err != nil {
+			log.Debug("invalid VIN", sl.Err(err))
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: "+err.Error())
+			return
+		}
+
+		var c entities.Car
+		err := c.GetByVIN(r.Context(), storage, v, auth.GetScope(r))
+		// Case with unknown VIN or car outside of caller scope
+		if errors.Is(err, entities.ErrNotFound) {
+			log.Info("car not found", slog.String("vin", v))
+			w.WriteHeader(404)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to get car by VIN", sl.Err(err))
+			return
+		}
+
+		info := vin.Decode(v)
+		render.JSON(w, r, VINResponse{
+			Car:        c,
+			Decoded:    info,
+			Mismatches: info.Mismatches(c.Mark, c.Year),
+		})
+	}
+}
+
+// StatusRequest moves car to status with optional reason
+type StatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=active in_repair sold scrapped"`
+	Reason string `json:"reason,omitempty" validate:"max=1000"`
+}
+
+// Status moves car {id} to another status. Transitions not allowed from current status
+// are rejected with 409
+func Status(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.cars.Status"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		carID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to make int car id", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		var req StatusRequest
+
+		// Decode request JSON
+		err = render.DecodeJSON(r.Body, &req)
+		// Case with empty request
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			w.WriteHeader(400)
+			return
+		}
+		// Case with common errors
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		// Validate request JSON
+		if err := validator.New().Struct(req); err != nil {
+			w.WriteHeader(400)
+			log.Error("invalid request", sl.Err(err))
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+
+		sc := entities.StatusChange{
+			CarID:     carID,
+			To:        req.Status,
+			Reason:    req.Reason,
+			ChangedBy: auth.GetPrincipalID(r.Context()),
+		}
+		err = sc.New(r.Context(), storage, auth.GetScope(r))
+		// Case with missing car or car outside of caller scope
+		if errors.Is(err, entities.ErrNotFound) {
+			w.WriteHeader(404)
+			log.Debug("car not found", sl.Err(err))
+			return
+		}
+		// Case with transition not allowed from current status
+		if errors.Is(err, entities.ErrIllegalTransition) {
+			w.WriteHeader(409)
+			render.JSON(w, r, "Error: cannot move car from "+sc.From+" to "+sc.To)
+			log.Debug("illegal status transition", sl.Err(err))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to change car status", sl.Err(err))
+			return
+		}
+
+		log.Info("car status changed", slog.Int("car_id", carID), slog.String("from", sc.From), slog.String("to", sc.To))
+
+		render.JSON(w, r, sc)
+	}
+}
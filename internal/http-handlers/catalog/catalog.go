@@ -1,104 +1,260 @@
-package catalog
-
-import (
-	"catalog/internal/storage/entities"
-	"encoding/json"
-	"errors"
-	"log/slog"
-	"net/http"
-	"strconv"
-
-	postgres "catalog/internal/storage"
-
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/render"
-)
-
-var (
-	errPageInOutOfRange = errors.New("page in out of range")
-)
-
-type Request struct {
-	entities.Car
-	Page int `json:"page,omitempty"`
-}
-
-type Response struct {
-	entities.CatalogPage
-}
-
-func New(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		const op = "handlers.catalog.New"
-
-		log := log.With(
-			slog.String("op", op),
-			slog.String("request_id", middleware.GetReqID(r.Context())),
-		)
-
-		var req Request
-		var err error
-
-		if rawCarID := r.URL.Query().Get("carId"); rawCarID != "" {
-			req.CarID, err = strconv.Atoi(rawCarID)
-			if err != nil {
-				log.Debug("failed to make int carId", err)
-				w.WriteHeader(500)
-				return
-			}
-		}
-		req.Mark = r.URL.Query().Get("mark")
-		req.Model = r.URL.Query().Get("model")
-		if rawYear := r.URL.Query().Get("year"); rawYear != "" {
-			req.Year, err = strconv.Atoi(rawYear)
-			if err != nil {
-				log.Debug("failed to make int year", err)
-				w.WriteHeader(500)
-				return
-			}
-		}
-		req.Owner.Name = r.URL.Query().Get("name")
-		req.Owner.Surname = r.URL.Query().Get("surname")
-		req.Owner.Patronymic = r.URL.Query().Get("patronymic")
-		if rawPage := r.URL.Query().Get("page"); rawPage != "" {
-			req.Page, err = strconv.Atoi(rawPage)
-			if err != nil {
-				log.Debug("failed to make int page", err)
-				w.WriteHeader(500)
-				return
-			}
-		}
-
-		// Get catalog on needed page with filter by c
-		c := req.Car
-		var cp entities.CatalogPage
-		err = cp.GetCatalogPage(storage, &c, req.Page)
-		// Case with page in out of range
-		if errors.As(err, &errPageInOutOfRange) {
-			log.Debug("failed to get catalog", err)
-			w.WriteHeader(400)
-			render.JSON(w, r, "Error: selected page in out of range")
-			return
-		}
-		// Case with common error
-		if err != nil {
-			log.Debug("failed to get catalog", err)
-			w.WriteHeader(500)
-			return
-		}
-
-		log.Debug("catalog was successfully gotten on page " + strconv.Itoa(cp.Pagination.CurrentPage))
-
-		rawResponse := Response{CatalogPage: cp}
-
-		response, err := json.Marshal(rawResponse)
-		if err != nil {
-			log.Error("failed to code JSON response", err)
-			w.WriteHeader(500)
-			return
-		}
-		render.Data(w, r, response)
-
-		log.Info("catalog response sent")
-	}
-}
+package catalog
+
+import (
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/tracing"
+	"catalog/internal/lib/vin"
+	"catalog/internal/storage/entities"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	postgres "catalog/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Request struct {
+	entities.Car
+	Page int `json:"page,omitempty"`
+	// Facets are comma separated fields to count values of, e.g. facets=mark,model,year
+	Facets []string `json:"facets,omitempty"`
+}
+
+type Response struct {
+	entities.CatalogPage
+}
+
+// ParseFilter reads car filter from catalog query parameters
+func ParseFilter(query url.Values) (entities.Car, error) {
+	var c entities.Car
+	var err error
+
+	if rawCarID := query.Get("carId"); rawCarID != "" {
+		c.CarID, err = strconv.Atoi(rawCarID)
+		if err != nil {
+			return c, fmt.Errorf("failed to make int carId: %w", err)
+		}
+	}
+	c.RegNum = query.Get("regNum")
+	c.Mark = query.Get("mark")
+	c.Model = query.Get("model")
+	c.Region = query.Get("region")
+	c.VIN = vin.Normalize(query.Get("vin"))
+	c.Status = query.Get("status")
+	if c.Status != "" && c.Status != entities.StatusAny && !entities.IsCarStatus(c.Status) {
+		return c, fmt.Errorf("unknown status %q", c.Status)
+	}
+	if rawYear := query.Get("year"); rawYear != "" {
+		c.Year, err = strconv.Atoi(rawYear)
+		if err != nil {
+			return c, fmt.Errorf("failed to make int year: %w", err)
+		}
+	}
+	c.Owner.Name = query.Get("name")
+	c.Owner.Surname = query.Get("surname")
+	c.Owner.Patronymic = query.Get("patronymic")
+
+	return c, nil
+}
+
+// ParseFacets reads list of facets, repeated ones are dropped
+func ParseFacets(query url.Values) ([]string, error) {
+	var facets []string
+	seen := make(map[string]bool)
+	for _, raw := range query["facets"] {
+		for _, f := range strings.Split(raw, ",") {
+			f = strings.TrimSpace(f)
+			if f == "" || seen[f] {
+				continue
+			}
+			seen[f] = true
+			facets = append(facets, f)
+		}
+	}
+	if err := entities.ValidateFacets(facets); err != nil {
+		return nil, err
+	}
+	return facets, nil
+}
+
+// ParseTags reads tag filter, e.g. tag=a&tag=b&tagMatch=all. Cars with any of tags
+// match unless tagMatch is all. Filter is nil without tags
+func ParseTags(query url.Values) (*entities.TagFilter, error) {
+	var all bool
+	switch match := query.Get("tagMatch"); match {
+	case "", "any":
+	case "all":
+		all = true
+	default:
+		return nil, fmt.Errorf("tagMatch must be any or all, got %q", match)
+	}
+	return entities.NewTagFilter(query["tag"], all)
+}
+
+// attributePrefix starts parameters filtering attributes, e.g. attr.color=red
+const attributePrefix = "attr."
+
+// HasAttributes tells if query filters or sorts by attributes, they are parsed by schema
+func HasAttributes(query url.Values) bool {
+	if query.Get("sort") != "" {
+		return true
+	}
+	for name := range query {
+		if strings.HasPrefix(name, attributePrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAttributes reads attribute filter of attr.<name> parameters typed by schema and
+// sort=attr.<name> or sort=-attr.<name> for descending order
+func ParseAttributes(query url.Values, schema entities.AttributeSchema) (entities.Attributes, *entities.AttributeOrder, error) {
+	var attrs entities.Attributes
+	for param, values := range query {
+		name, ok := strings.CutPrefix(param, attributePrefix)
+		if !ok {
+			continue
+		}
+		if len(values) > 1 {
+			return nil, nil, fmt.Errorf("%s is repeated", param)
+		}
+		v, err := schema.ParseValue(name, values[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		if attrs == nil {
+			attrs = entities.Attributes{}
+		}
+		attrs[name] = v
+	}
+
+	var order *entities.AttributeOrder
+	if rawSort := query.Get("sort"); rawSort != "" {
+		desc := strings.HasPrefix(rawSort, "-")
+		name, ok := strings.CutPrefix(strings.TrimPrefix(rawSort, "-"), attributePrefix)
+		if !ok {
+			return nil, nil, fmt.Errorf("sort must be %s<name> or -%s<name>, got %q", attributePrefix, attributePrefix, rawSort)
+		}
+		if schema.Def(name) == nil {
+			return nil, nil, fmt.Errorf("%w: %s is not defined", entities.ErrInvalidAttributes, name)
+		}
+		order = &entities.AttributeOrder{Name: name, Desc: desc}
+	}
+	return attrs, order, nil
+}
+
+// New serves catalog page. Cars due for service by intervals are chosen with serviceDue=true
+func New(log *slog.Logger, storage *postgres.Storage, intervals entities.ServiceIntervals) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.catalog.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		var req Request
+		var err error
+
+		req.Car, err = ParseFilter(r.URL.Query())
+		if err != nil {
+			log.Debug("failed to parse filter", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+		if rawPage := r.URL.Query().Get("page"); rawPage != "" {
+			req.Page, err = strconv.Atoi(rawPage)
+			if err != nil {
+				log.Debug("failed to make int page", sl.Err(err))
+				w.WriteHeader(400)
+				return
+			}
+		}
+		if rawServiceDue := r.URL.Query().Get("serviceDue"); rawServiceDue != "" {
+			serviceDue, err := strconv.ParseBool(rawServiceDue)
+			if err != nil {
+				log.Debug("failed to make bool serviceDue", sl.Err(err))
+				w.WriteHeader(400)
+				return
+			}
+			if serviceDue {
+				req.Car.ServiceDue = &intervals
+			}
+		}
+		req.Car.Tags, err = ParseTags(r.URL.Query())
+		// Case with invalid tag
+		if err != nil {
+			log.Debug("failed to parse tags", sl.Err(err))
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: "+err.Error())
+			return
+		}
+		if HasAttributes(r.URL.Query()) {
+			var schema entities.AttributeSchema
+			if err := schema.Get(r.Context(), storage, auth.GetScope(r).TenantID); err != nil {
+				log.Error("failed to get attribute schema", sl.Err(err))
+				w.WriteHeader(500)
+				return
+			}
+			req.Car.Attributes, req.Car.Order, err = ParseAttributes(r.URL.Query(), schema)
+			// Case with unknown attribute or value of wrong type
+			if err != nil {
+				log.Debug("failed to parse attributes", sl.Err(err))
+				w.WriteHeader(400)
+				render.JSON(w, r, "Error: "+err.Error())
+				return
+			}
+		}
+		req.Facets, err = ParseFacets(r.URL.Query())
+		// Case with unknown facet
+		if err != nil {
+			log.Debug("failed to parse facets", sl.Err(err))
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: "+err.Error())
+			return
+		}
+
+		// Get catalog on needed page with filter by c
+		c := req.Car
+		var cp entities.CatalogPage
+		err = cp.GetCatalogPage(r.Context(), storage, &c, req.Page, auth.GetScope(r), req.Facets...)
+		// Case with page in out of range
+		if errors.Is(err, entities.ErrPageOutOfRange) {
+			log.Debug("failed to get catalog", sl.Err(err))
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: selected page in out of range")
+			return
+		}
+		// Case with common error
+		if err != nil {
+			log.Debug("failed to get catalog", sl.Err(err))
+			w.WriteHeader(500)
+			return
+		}
+
+		log.Debug("catalog was successfully gotten on page " + strconv.Itoa(cp.Pagination.CurrentPage))
+
+		rawResponse := Response{CatalogPage: cp}
+
+		response, err := json.Marshal(rawResponse)
+		if err != nil {
+			log.Error("failed to code JSON response", sl.Err(err))
+			w.WriteHeader(500)
+			return
+		}
+		render.Data(w, r, response)
+
+		log.Info("catalog response sent")
+	}
+}
@@ -1,78 +1,133 @@
-package edit
-
-import (
-	"catalog/internal/lib/logger/sl"
-	"catalog/internal/storage/entities"
-	"errors"
-	"io"
-	"log/slog"
-	"net/http"
-
-	postgres "catalog/internal/storage"
-
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/render"
-	"github.com/go-playground/validator/v10"
-)
-
-type Request struct {
-	CarID  int             `json:"carId",omitempty validate:"required""`
-	RegNum string          `json:"regNum",omitempty`
-	Mark   string          `json:"mark",omitempty`
-	Model  string          `json:"model",omitempty`
-	Year   int             `json:"year",omitempty`
-	Owner  entities.Person `json:"owner",omitempty`
-}
-
-func New(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		const op = "handlers.edit.New"
-
-		log := log.With(
-			slog.String("op", op),
-			slog.String("request_id", middleware.GetReqID(r.Context())),
-		)
-
-		var req Request
-
-		// Decode request JSON
-		err := render.DecodeJSON(r.Body, &req)
-		// Case with empty request
-		if errors.Is(err, io.EOF) {
-			log.Error("request body is empty")
-			w.WriteHeader(400)
-			return
-		}
-		// Case with common errors
-		if err != nil {
-			log.Error("failed to decode request body", sl.Err(err))
-			w.WriteHeader(400)
-			return
-		}
-
-		// Validate request JSON
-		if err := validator.New().Struct(req); err != nil {
-			w.WriteHeader(400)
-			log.Error("invalid request", sl.Err(err))
-			return
-		}
-
-		log.Info("request body decoded", slog.Any("request", req))
-
-		c := entities.Car{
-			CarID:  req.CarID,
-			RegNum: req.RegNum,
-			Mark:   req.Mark,
-			Model:  req.Model,
-			Year:   req.Year,
-			Owner:  req.Owner,
-		}
-		if err := c.Edit(storage); err != nil {
-			w.WriteHeader(500)
-			log.Debug("failed to edit car", sl.Err(err))
-			return
-		}
-
-		log.Debug("car was successfully edited")
-	}
-}
+package edit
+
+import (
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/tracing"
+	"catalog/internal/lib/vin"
+	"catalog/internal/storage/entities"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	postgres "catalog/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+type Request struct {
+	CarID  int             `json:"carId" validate:"required"`
+	RegNum string          `json:"regNum,omitempty"`
+	Mark   string          `json:"mark,omitempty"`
+	Model  string          `json:"model,omitempty"`
+	Year   int             `json:"year,omitempty"`
+	Region string          `json:"region,omitempty"`
+	VIN    string          `json:"vin,omitempty"`
+	Owner  entities.Person `json:"owner,omitempty"`
+	// Attributes are merged into attributes of car, null value removes attribute
+	Attributes entities.Attributes `json:"attributes,omitempty"`
+}
+
+func New(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.edit.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		var req Request
+
+		// Decode request JSON
+		err := render.DecodeJSON(r.Body, &req)
+		// Case with empty request
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			w.WriteHeader(400)
+			return
+		}
+		// Case with common errors
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		// Validate request JSON
+		if err := validator.New().Struct(req); err != nil {
+			w.WriteHeader(400)
+			log.Error("invalid request", sl.Err(err))
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+
+		// Case with invalid VIN
+		if req.VIN != "" {
+			req.VIN = vin.Normalize(req.VIN)
+			if err := vin.Validate(req.VIN); err != nil {
+				w.WriteHeader(400)
+				render.JSON(w, r, "Error: "+err.Error())
+				log.Debug("invalid VIN", sl.Err(err))
+				return
+			}
+		}
+
+		c := entities.Car{
+			CarID:      req.CarID,
+			RegNum:     req.RegNum,
+			Mark:       req.Mark,
+			Model:      req.Model,
+			Year:       req.Year,
+			Region:     req.Region,
+			VIN:        req.VIN,
+			Owner:      req.Owner,
+			Attributes: req.Attributes,
+		}
+		err = c.Edit(r.Context(), storage, auth.GetScope(r))
+		// Case with missing car or car outside of caller scope
+		if errors.Is(err, entities.ErrNotFound) {
+			w.WriteHeader(404)
+			log.Debug("car not found", sl.Err(err))
+			return
+		}
+		// Case with request changing nothing
+		if errors.Is(err, entities.ErrNothingToEdit) {
+			w.WriteHeader(400)
+			log.Debug("no fields to edit", sl.Err(err))
+			return
+		}
+		// Case with moving car to region outside of caller scope
+		if errors.Is(err, entities.ErrOutOfScope) {
+			w.WriteHeader(403)
+			log.Debug("region is out of scope", sl.Err(err))
+			return
+		}
+		// Case with VIN of other car
+		if errors.Is(err, entities.ErrVINExists) {
+			w.WriteHeader(409)
+			log.Debug("VIN already exists", sl.Err(err))
+			return
+		}
+		// Case with attributes not matching schema of tenant
+		if errors.Is(err, entities.ErrInvalidAttributes) {
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: "+errors.Unwrap(err).Error())
+			log.Debug("invalid attributes", sl.Err(err))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Debug("failed to edit car", sl.Err(err))
+			return
+		}
+
+		log.Debug("car was successfully edited")
+	}
+}
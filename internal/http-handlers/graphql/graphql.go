@@ -0,0 +1,87 @@
+package graphql
+
+import (
+	_ "embed"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/archive"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/tracing"
+	postgres "catalog/internal/storage"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/trace/otel"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// maxDepth limits nesting of car.owner.cars.owner... queries
+const maxDepth = 8
+
+type Request struct {
+	Query         string                 `json:"query" validate:"required"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// New returns GraphQL endpoint over cars and owners. Queries need read permission,
+// mutations check write permission and take limits of their groups themselves
+func New(log *slog.Logger, storage *postgres.Storage, archiveClient *archive.Client, limits RateLimits) http.HandlerFunc {
+	s := graphql.MustParseSchema(schema, &Resolver{log: log, storage: storage, archive: archiveClient, limits: limits},
+		graphql.MaxDepth(maxDepth),
+		graphql.Tracer(otel.DefaultTracer()),
+	)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.graphql.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		var req Request
+
+		// Decode request JSON
+		err := render.DecodeJSON(r.Body, &req)
+		// Case with empty request
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			w.WriteHeader(400)
+			return
+		}
+		// Case with common errors
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		// Validate request JSON
+		if err := validator.New().Struct(req); err != nil {
+			w.WriteHeader(400)
+			log.Error("invalid request", sl.Err(err))
+			return
+		}
+
+		log.Debug("request body decoded", slog.String("operation", req.OperationName))
+
+		// Errors of resolvers are part of response, status is 200 anyway
+		response := s.Exec(r.Context(), req.Query, req.OperationName, req.Variables)
+		if len(response.Errors) > 0 {
+			log.Debug("query has errors", slog.Any("errors", response.Errors))
+		}
+
+		render.JSON(w, r, response)
+	}
+}
@@ -0,0 +1,93 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/http-handlers/middleware/ratelimit"
+	"catalog/internal/storage/entities"
+)
+
+func TestSchemaMatchesResolvers(t *testing.T) {
+	// Schema is checked against resolver methods on parse and panics on mismatch
+	New(slog.New(slog.NewTextHandler(io.Discard, nil)), nil, nil, RateLimits{})
+}
+
+func TestMutationLimit(t *testing.T) {
+	r := &Resolver{
+		log:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		limits: RateLimits{Limiter: ratelimit.NewMemory(), Write: ratelimit.Limit{Rate: 0.01, Burst: 1}},
+	}
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{ID: "api_key:1", Role: auth.RoleEditor})
+
+	// The only token is taken by other write of principal
+	if err := r.limit(ctx, "write", r.limits.Write); err != nil {
+		t.Fatalf("first write: %v", err)
+	}
+	if _, err := r.DeleteCar(ctx, struct{ CarID int32 }{CarID: 1}); err != errTooManyRequests {
+		t.Fatalf("err = %v, want %v", err, errTooManyRequests)
+	}
+}
+
+func TestAttributesScalar(t *testing.T) {
+	var a attributes
+	// Literal numbers are int32, attributes keep float64 as JSON does
+	if err := a.UnmarshalGraphQL(map[string]interface{}{"mileage": int32(100), "color": nil}); err != nil {
+		t.Fatalf("UnmarshalGraphQL: %v", err)
+	}
+	want := entities.Attributes{"mileage": 100.0, "color": nil}
+	if !reflect.DeepEqual(a.get(), want) {
+		t.Fatalf("attributes = %v, want %v", a.get(), want)
+	}
+	if err := a.UnmarshalGraphQL("red"); err == nil {
+		t.Fatal("string is unmarshaled as attributes")
+	}
+}
+
+func TestLoaderBatches(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]int
+	l := newLoader(func(ctx context.Context, keys []int) (map[int]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, keys)
+
+		values := make(map[int]string)
+		for _, k := range keys {
+			if k != 3 {
+				values[k] = "v" + string(rune('0'+k))
+			}
+		}
+		return values, nil
+	}, 1, 2, 3)
+
+	var wg sync.WaitGroup
+	for _, k := range []int{3, 2, 1, 2} {
+		wg.Add(1)
+		go func(k int) {
+			defer wg.Done()
+			v, err := l.Load(context.Background(), k)
+			if err != nil || k != 3 && v != "v"+string(rune('0'+k)) || k == 3 && v != "" {
+				t.Errorf("Load(%d) = %q, %v", k, v, err)
+			}
+		}(k)
+	}
+	wg.Wait()
+
+	if len(batches) != 1 {
+		t.Fatalf("fetched %d times, want once: %v", len(batches), batches)
+	}
+
+	// Unknown key is fetched alone
+	if _, err := l.Load(context.Background(), 4); err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 2 || !reflect.DeepEqual(batches[1], []int{4}) {
+		t.Fatalf("batches = %v", batches)
+	}
+}
@@ -0,0 +1,45 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+)
+
+// loader batches loading of values by keys. Keys of all sibling objects are added
+// when their parent list is resolved, so the first Load fetches them in one query
+// and the rest get cached values
+type loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	pending []K
+	values  map[K]V
+}
+
+func newLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error), keys ...K) *loader[K, V] {
+	return &loader[K, V]{fetch: fetch, pending: keys, values: make(map[K]V)}
+}
+
+// Load returns value of key, zero value if fetch found nothing for it
+func (l *loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if v, ok := l.values[key]; ok {
+		return v, nil
+	}
+
+	keys := append(l.pending, key)
+	values, err := l.fetch(ctx, keys)
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	// Missing keys are cached too, so they aren't fetched again
+	for _, k := range keys {
+		l.values[k] = values[k]
+	}
+	l.pending = nil
+
+	return l.values[key], nil
+}
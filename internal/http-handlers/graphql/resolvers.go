@@ -0,0 +1,638 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/http-handlers/middleware/ratelimit"
+	"catalog/internal/lib/archive"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/vin"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/entities"
+)
+
+// Error is GraphQL error with code in extensions
+type Error struct {
+	Message string
+	Code    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+var (
+	errForbidden       = &Error{Message: "permission denied", Code: "FORBIDDEN"}
+	errNotFound        = &Error{Message: "car not found", Code: "NOT_FOUND"}
+	errOutOfScope      = &Error{Message: "region is out of scope", Code: "FORBIDDEN"}
+	errPageOutOfRange  = &Error{Message: "selected page in out of range", Code: "BAD_REQUEST"}
+	errNothingToEdit   = &Error{Message: "nothing to edit", Code: "BAD_REQUEST"}
+	errArchiveNotFound = &Error{Message: "car not found in archive", Code: "NOT_FOUND"}
+	errArchive         = &Error{Message: "archive is not available", Code: "BAD_GATEWAY"}
+	errVINExists       = &Error{Message: "VIN already exists", Code: "CONFLICT"}
+	errTooManyRequests = &Error{Message: "too many requests", Code: "TOO_MANY_REQUESTS"}
+	errInternal        = &Error{Message: "internal error", Code: "INTERNAL"}
+)
+
+// RateLimits are limits of mutations. Buckets are shared with HTTP routes and RPCs of
+// the same group, the whole request is limited as read by route
+type RateLimits struct {
+	Limiter ratelimit.Limiter
+	// New limits createCar
+	New ratelimit.Limit
+	// Write limits editCar and deleteCar
+	Write ratelimit.Limit
+}
+
+// Resolver is root of queries and mutations
+type Resolver struct {
+	log     *slog.Logger
+	storage *postgres.Storage
+	archive *archive.Client
+	limits  RateLimits
+}
+
+// internal logs err and hides its details from caller
+func (r *Resolver) internal(op string, err error) error {
+	r.log.Error("failed to resolve", slog.String("op", op), sl.Err(err))
+	return errInternal
+}
+
+// limit takes token of group bucket of principal. Limiter errors let mutation through
+func (r *Resolver) limit(ctx context.Context, group string, l ratelimit.Limit) error {
+	const op = "handlers.graphql.limit"
+
+	if l.Disabled() {
+		return nil
+	}
+
+	res, err := r.limits.Limiter.Take(ctx, group+":"+auth.GetPrincipalID(ctx), l)
+	if err != nil {
+		r.log.Error("failed to take rate limit token", slog.String("op", op), sl.Err(err))
+		return nil
+	}
+	if !res.Allowed {
+		r.log.Info("rate limit exceeded", slog.String("op", op),
+			slog.String("group", group), slog.String("key", auth.GetPrincipalID(ctx)))
+		return errTooManyRequests
+	}
+	return nil
+}
+
+type personFilter struct {
+	PersonID   *int32
+	Name       *string
+	Surname    *string
+	Patronymic *string
+}
+
+type carFilter struct {
+	CarID      *int32
+	RegNum     *string
+	Mark       *string
+	Model      *string
+	Year       *int32
+	Region     *string
+	VIN        *string
+	Status     *string
+	Tags       *[]string
+	AllTags    *bool
+	Attributes *attributes
+	Owner      *personFilter
+}
+
+// car makes catalog filter, missing fields match everything
+func (f *carFilter) car() (entities.Car, error) {
+	var c entities.Car
+	if f == nil {
+		return c, nil
+	}
+	c.CarID = intOf(f.CarID)
+	c.RegNum = stringOf(f.RegNum)
+	c.Mark = stringOf(f.Mark)
+	c.Model = stringOf(f.Model)
+	c.Year = intOf(f.Year)
+	c.Region = stringOf(f.Region)
+	c.VIN = vin.Normalize(stringOf(f.VIN))
+	c.Status = stringOf(f.Status)
+	if c.Status != "" && c.Status != entities.StatusAny && !entities.IsCarStatus(c.Status) {
+		return c, &Error{Message: fmt.Sprintf("unknown status %q", c.Status), Code: "BAD_REQUEST"}
+	}
+	if f.Tags != nil {
+		tags, err := entities.NewTagFilter(*f.Tags, f.AllTags != nil && *f.AllTags)
+		if err != nil {
+			return c, &Error{Message: err.Error(), Code: "BAD_REQUEST"}
+		}
+		c.Tags = tags
+	}
+	c.Attributes = f.Attributes.get()
+	if o := f.Owner; o != nil {
+		c.Owner = entities.Person{
+			PersonID:   intOf(o.PersonID),
+			Name:       stringOf(o.Name),
+			Surname:    stringOf(o.Surname),
+			Patronymic: stringOf(o.Patronymic),
+		}
+	}
+	return c, nil
+}
+
+func (r *Resolver) Cars(ctx context.Context, args struct {
+	Filter *carFilter
+	Page   *int32
+}) (*carConnectionResolver, error) {
+	const op = "handlers.graphql.Cars"
+
+	c, err := args.Filter.car()
+	if err != nil {
+		return nil, err
+	}
+	if c.Attributes != nil {
+		var schema entities.AttributeSchema
+		if err := schema.Get(ctx, r.storage, auth.GetScopeFromContext(ctx).TenantID); err != nil {
+			return nil, r.internal(op, err)
+		}
+		// Case with unknown attribute or value of wrong type
+		if err := schema.ValidateFilter(c.Attributes); err != nil {
+			return nil, &Error{Message: err.Error(), Code: "BAD_REQUEST"}
+		}
+	}
+	var cp entities.CatalogPage
+	err = cp.GetCatalogPage(ctx, r.storage, &c, intOf(args.Page), auth.GetScopeFromContext(ctx))
+	// Case with page in out of range
+	if errors.Is(err, entities.ErrPageOutOfRange) {
+		return nil, errPageOutOfRange
+	}
+	if err != nil {
+		return nil, r.internal(op, err)
+	}
+
+	return &carConnectionResolver{nodes: r.carResolvers(cp.Cars), pagination: cp.Pagination}, nil
+}
+
+func (r *Resolver) Car(ctx context.Context, args struct{ CarID int32 }) (*carResolver, error) {
+	const op = "handlers.graphql.Car"
+
+	c, err := r.getCar(ctx, int(args.CarID))
+	if errors.Is(err, entities.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, r.internal(op, err)
+	}
+	return c, nil
+}
+
+// getCar returns car visible for caller or ErrNotFound
+func (r *Resolver) getCar(ctx context.Context, carID int) (*carResolver, error) {
+	var cp entities.CatalogPage
+	err := cp.GetCatalogPage(ctx, r.storage, &entities.Car{CarID: carID, Status: entities.StatusAny}, 1,
+		auth.GetScopeFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if len(cp.Cars) == 0 {
+		return nil, entities.ErrNotFound
+	}
+	return r.carResolvers(cp.Cars)[0], nil
+}
+
+func (r *Resolver) Owners(ctx context.Context, args struct {
+	Name       *string
+	Surname    *string
+	Patronymic *string
+}) ([]*personResolver, error) {
+	const op = "handlers.graphql.Owners"
+
+	p := entities.Person{Name: stringOf(args.Name), Surname: stringOf(args.Surname), Patronymic: stringOf(args.Patronymic)}
+	var ows entities.Owners
+	if err := ows.Search(ctx, r.storage, &p, auth.GetScopeFromContext(ctx).TenantID); err != nil {
+		return nil, r.internal(op, err)
+	}
+
+	persons := make([]entities.Person, 0, len(ows))
+	for _, o := range ows {
+		persons = append(persons, o.Person)
+	}
+	return r.personResolvers(persons), nil
+}
+
+func (r *Resolver) CreateCar(ctx context.Context, args struct {
+	RegNum     string
+	Region     *string
+	VIN        *string
+	Attributes *attributes
+}) (*carResolver, error) {
+	const op = "handlers.graphql.CreateCar"
+
+	if !auth.GetPrincipal(ctx).Can(auth.PermWrite) {
+		return nil, errForbidden
+	}
+	if err := r.limit(ctx, "new", r.limits.New); err != nil {
+		return nil, err
+	}
+	carVIN, err := normalizeVIN(args.VIN)
+	if err != nil {
+		return nil, err
+	}
+
+	cr, err := r.archive.GetCar(ctx, args.RegNum)
+	// Case with unknown regNum
+	if errors.Is(err, archive.ErrNotFound) {
+		return nil, errArchiveNotFound
+	}
+	// Case with unavailable archive or invalid archive response
+	if err != nil {
+		r.log.Error("failed to get car from archive", slog.String("op", op), sl.Err(err))
+		return nil, errArchive
+	}
+
+	c := entities.Car{
+		RegNum:     cr.RegNum,
+		Mark:       cr.Mark,
+		Model:      cr.Model,
+		Year:       cr.Year,
+		Region:     stringOf(args.Region),
+		VIN:        carVIN,
+		Attributes: args.Attributes.get(),
+		Owner: entities.Person{
+			Name:       cr.Owner.Name,
+			Surname:    cr.Owner.Surname,
+			Patronymic: cr.Owner.Patronymic,
+		},
+	}
+	scope := auth.GetScopeFromContext(ctx)
+	if c.Region == "" && len(scope.Regions) == 1 {
+		c.Region = scope.Regions[0]
+	}
+
+	err = c.New(ctx, r.storage, scope)
+	// Case with region outside of caller scope
+	if errors.Is(err, entities.ErrOutOfScope) {
+		return nil, errOutOfScope
+	}
+	// Case with VIN of other car
+	if errors.Is(err, entities.ErrVINExists) {
+		return nil, errVINExists
+	}
+	// Case with attributes not matching schema of tenant
+	if errors.Is(err, entities.ErrInvalidAttributes) {
+		return nil, &Error{Message: errors.Unwrap(err).Error(), Code: "BAD_REQUEST"}
+	}
+	if err != nil {
+		return nil, r.internal(op, err)
+	}
+
+	// The newest car with regNum is the added one
+	var cp entities.CatalogPage
+	if err := cp.GetCatalogPage(ctx, r.storage, &entities.Car{RegNum: c.RegNum}, 1, scope); err != nil {
+		return nil, r.internal(op, err)
+	}
+	if len(cp.Cars) == 0 {
+		return nil, r.internal(op, errors.New("added car is not found"))
+	}
+	return r.carResolvers(cp.Cars)[0], nil
+}
+
+type personInput struct {
+	Name       string
+	Surname    string
+	Patronymic *string
+}
+
+type editCarInput struct {
+	CarID      int32
+	RegNum     *string
+	Mark       *string
+	Model      *string
+	Year       *int32
+	Region     *string
+	VIN        *string
+	Attributes *attributes
+	Owner      *personInput
+}
+
+func (r *Resolver) EditCar(ctx context.Context, args struct{ Input editCarInput }) (*carResolver, error) {
+	const op = "handlers.graphql.EditCar"
+
+	if !auth.GetPrincipal(ctx).Can(auth.PermWrite) {
+		return nil, errForbidden
+	}
+	if err := r.limit(ctx, "write", r.limits.Write); err != nil {
+		return nil, err
+	}
+
+	in := args.Input
+	carVIN, err := normalizeVIN(in.VIN)
+	if err != nil {
+		return nil, err
+	}
+	c := entities.Car{
+		CarID:      int(in.CarID),
+		RegNum:     stringOf(in.RegNum),
+		Mark:       stringOf(in.Mark),
+		Model:      stringOf(in.Model),
+		Year:       intOf(in.Year),
+		Region:     stringOf(in.Region),
+		VIN:        carVIN,
+		Attributes: in.Attributes.get(),
+	}
+	if in.Owner != nil {
+		c.Owner = entities.Person{Name: in.Owner.Name, Surname: in.Owner.Surname, Patronymic: stringOf(in.Owner.Patronymic)}
+	}
+
+	err = c.Edit(ctx, r.storage, auth.GetScopeFromContext(ctx))
+	// Case with missing car or car outside of caller scope
+	if errors.Is(err, entities.ErrNotFound) {
+		return nil, errNotFound
+	}
+	if errors.Is(err, entities.ErrNothingToEdit) {
+		return nil, errNothingToEdit
+	}
+	// Case with moving car to region outside of caller scope
+	if errors.Is(err, entities.ErrOutOfScope) {
+		return nil, errOutOfScope
+	}
+	// Case with VIN of other car
+	if errors.Is(err, entities.ErrVINExists) {
+		return nil, errVINExists
+	}
+	// Case with attributes not matching schema of tenant
+	if errors.Is(err, entities.ErrInvalidAttributes) {
+		return nil, &Error{Message: errors.Unwrap(err).Error(), Code: "BAD_REQUEST"}
+	}
+	if err != nil {
+		return nil, r.internal(op, err)
+	}
+
+	edited, err := r.getCar(ctx, c.CarID)
+	if err != nil {
+		return nil, r.internal(op, err)
+	}
+	return edited, nil
+}
+
+func (r *Resolver) DeleteCar(ctx context.Context, args struct{ CarID int32 }) (bool, error) {
+	const op = "handlers.graphql.DeleteCar"
+
+	if !auth.GetPrincipal(ctx).Can(auth.PermWrite) {
+		return false, errForbidden
+	}
+	if err := r.limit(ctx, "write", r.limits.Write); err != nil {
+		return false, err
+	}
+
+	var c *entities.Car
+	err := c.Delete(ctx, r.storage, int(args.CarID), auth.GetScopeFromContext(ctx))
+	// Case with missing car or car outside of caller scope
+	if errors.Is(err, entities.ErrNotFound) {
+		return false, errNotFound
+	}
+	if err != nil {
+		return false, r.internal(op, err)
+	}
+	return true, nil
+}
+
+// carResolvers makes resolvers of sibling cars. Their owners share loader of owned cars
+func (r *Resolver) carResolvers(cars entities.Cars) []*carResolver {
+	owners := make([]entities.Person, 0, len(cars))
+	for _, c := range cars {
+		owners = append(owners, c.Owner)
+	}
+	persons := r.personResolvers(owners)
+
+	resolvers := make([]*carResolver, 0, len(cars))
+	for i, c := range cars {
+		resolvers = append(resolvers, &carResolver{car: c, owner: persons[i]})
+	}
+	return resolvers
+}
+
+// personResolvers makes resolvers of sibling persons, cars of all of them are loaded at once
+func (r *Resolver) personResolvers(persons []entities.Person) []*personResolver {
+	ids := make([]int, 0, len(persons))
+	for _, p := range persons {
+		ids = append(ids, p.PersonID)
+	}
+	cars := newLoader(r.carsByOwners, ids...)
+
+	resolvers := make([]*personResolver, 0, len(persons))
+	for _, p := range persons {
+		resolvers = append(resolvers, &personResolver{r: r, person: p, cars: cars})
+	}
+	return resolvers
+}
+
+func (r *Resolver) carsByOwners(ctx context.Context, personIDs []int) (map[int]entities.Cars, error) {
+	var cs entities.Cars
+	if err := cs.GetByOwners(ctx, r.storage, personIDs, auth.GetScopeFromContext(ctx)); err != nil {
+		return nil, err
+	}
+
+	byOwner := make(map[int]entities.Cars)
+	for _, c := range cs {
+		byOwner[c.Owner.PersonID] = append(byOwner[c.Owner.PersonID], c)
+	}
+	return byOwner, nil
+}
+
+type carResolver struct {
+	car   entities.Car
+	owner *personResolver
+}
+
+func (c *carResolver) CarID() int32 {
+	return int32(c.car.CarID)
+}
+
+func (c *carResolver) RegNum() string {
+	return c.car.RegNum
+}
+
+func (c *carResolver) Mark() string {
+	return c.car.Mark
+}
+
+func (c *carResolver) Model() string {
+	return c.car.Model
+}
+
+// Year is null if archive doesn't know it
+func (c *carResolver) Year() *int32 {
+	return nullInt(c.car.Year)
+}
+
+func (c *carResolver) Region() string {
+	return c.car.Region
+}
+
+// VIN is null if unknown
+func (c *carResolver) VIN() *string {
+	if c.car.VIN == "" {
+		return nil
+	}
+	return &c.car.VIN
+}
+
+func (c *carResolver) Status() string {
+	return c.car.Status
+}
+
+// Attributes are null without attributes
+func (c *carResolver) Attributes() *attributes {
+	if len(c.car.Attributes) == 0 {
+		return nil
+	}
+	a := attributes(c.car.Attributes)
+	return &a
+}
+
+func (c *carResolver) Owner() *personResolver {
+	return c.owner
+}
+
+type personResolver struct {
+	r      *Resolver
+	person entities.Person
+	cars   *loader[int, entities.Cars]
+}
+
+func (p *personResolver) PersonID() int32 {
+	return int32(p.person.PersonID)
+}
+
+func (p *personResolver) Name() string {
+	return p.person.Name
+}
+
+func (p *personResolver) Surname() string {
+	return p.person.Surname
+}
+
+func (p *personResolver) Patronymic() string {
+	return p.person.Patronymic
+}
+
+func (p *personResolver) Cars(ctx context.Context) ([]*carResolver, error) {
+	const op = "handlers.graphql.Person.Cars"
+
+	cars, err := p.cars.Load(ctx, p.person.PersonID)
+	if err != nil {
+		return nil, p.r.internal(op, err)
+	}
+	return p.r.carResolvers(cars), nil
+}
+
+type carConnectionResolver struct {
+	nodes      []*carResolver
+	pagination entities.Pagination
+}
+
+func (cc *carConnectionResolver) Nodes() []*carResolver {
+	return cc.nodes
+}
+
+func (cc *carConnectionResolver) Pagination() *paginationResolver {
+	return &paginationResolver{p: cc.pagination}
+}
+
+type paginationResolver struct {
+	p entities.Pagination
+}
+
+// Next is null on the last page
+func (pr *paginationResolver) Next() *int32 {
+	return nullInt(pr.p.Next)
+}
+
+// Previous is null on the first page
+func (pr *paginationResolver) Previous() *int32 {
+	return nullInt(pr.p.Previous)
+}
+
+func (pr *paginationResolver) RecordPerPage() int32 {
+	return int32(pr.p.RecordPerPage)
+}
+
+func (pr *paginationResolver) CurrentPage() int32 {
+	return int32(pr.p.CurrentPage)
+}
+
+func (pr *paginationResolver) TotalPage() int32 {
+	return int32(pr.p.TotalPage)
+}
+
+// attributes is Attributes scalar, the same object as attributes of HTTP API
+type attributes entities.Attributes
+
+func (attributes) ImplementsGraphQLType(name string) bool {
+	return name == "Attributes"
+}
+
+// UnmarshalGraphQL takes object of variables or literal, numbers of literals are int32
+func (a *attributes) UnmarshalGraphQL(input interface{}) error {
+	m, ok := input.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("attributes must be object, got %T", input)
+	}
+	*a = make(attributes, len(m))
+	for name, v := range m {
+		if n, ok := v.(int32); ok {
+			v = float64(n)
+		}
+		(*a)[name] = v
+	}
+	return nil
+}
+
+// get returns attributes of a, missing a gives nil
+func (a *attributes) get() entities.Attributes {
+	if a == nil || len(*a) == 0 {
+		return nil
+	}
+	return entities.Attributes(*a)
+}
+
+// normalizeVIN returns normalized v, missing v is empty
+func normalizeVIN(v *string) (string, error) {
+	if v == nil || *v == "" {
+		return "", nil
+	}
+	n := vin.Normalize(*v)
+	if err := vin.Validate(n); err != nil {
+		return "", &Error{Message: err.Error(), Code: "BAD_REQUEST"}
+	}
+	return n, nil
+}
+
+func intOf(v *int32) int {
+	if v == nil {
+		return 0
+	}
+	return int(*v)
+}
+
+func stringOf(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+// nullInt returns nil for 0, which means missing value in entities
+func nullInt(v int) *int32 {
+	if v == 0 {
+		return nil
+	}
+	n := int32(v)
+	return &n
+}
@@ -0,0 +1,98 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"catalog/internal/lib/logger/sl"
+
+	"github.com/go-chi/render"
+)
+
+const checkTimeout = 2 * time.Second
+
+// Check statuses
+const (
+	StatusOK       = "ok"
+	StatusFail     = "fail"
+	StatusDegraded = "degraded"
+)
+
+// Check of service dependency
+type Check struct {
+	Name string
+	// Optional check failure doesn't make service not ready, it is reported as degraded
+	Optional bool
+	Fn       func(ctx context.Context) error
+}
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type Response struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Live reports that process is up and serves HTTP
+func Live() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, Response{Status: StatusOK})
+	}
+}
+
+// Ready runs all checks concurrently. Service is ready with 200 if no required check failed,
+// otherwise 503 is returned. Every check result is in response body
+func Ready(log *slog.Logger, checks []Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.health.Ready"
+
+		log := log.With(slog.String("op", op))
+
+		resp := Response{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, c := range checks {
+			wg.Add(1)
+			go func(c Check) {
+				defer wg.Done()
+
+				ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+				defer cancel()
+
+				start := time.Now()
+				err := c.Fn(ctx)
+				res := CheckResult{Status: StatusOK, Optional: c.Optional, Duration: time.Since(start).String()}
+				if err != nil {
+					res.Status = StatusFail
+					res.Error = err.Error()
+					log.Warn("readiness check failed", slog.String("check", c.Name), sl.Err(err))
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				resp.Checks[c.Name] = res
+				switch {
+				case err == nil:
+				case !c.Optional:
+					resp.Status = StatusFail
+				case resp.Status == StatusOK:
+					resp.Status = StatusDegraded
+				}
+			}(c)
+		}
+		wg.Wait()
+
+		if resp.Status == StatusFail {
+			render.Status(r, 503)
+		}
+		render.JSON(w, r, resp)
+	}
+}
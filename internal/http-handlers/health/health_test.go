@@ -0,0 +1,54 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReady(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ok := func(context.Context) error { return nil }
+	fail := func(context.Context) error { return errors.New("down") }
+
+	tests := []struct {
+		name       string
+		checks     []Check
+		wantCode   int
+		wantStatus string
+	}{
+		{"all ok", []Check{{Name: "db", Fn: ok}, {Name: "archive", Fn: ok}}, 200, StatusOK},
+		{"optional failed", []Check{{Name: "db", Fn: ok}, {Name: "archive", Optional: true, Fn: fail}}, 200, StatusDegraded},
+		{"required failed", []Check{{Name: "db", Fn: fail}, {Name: "archive", Optional: true, Fn: fail}}, 503, StatusFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			Ready(log, tt.checks)(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantCode)
+			}
+			var resp Response
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Fatalf("status = %q, want %q", resp.Status, tt.wantStatus)
+			}
+			if len(resp.Checks) != len(tt.checks) {
+				t.Fatalf("got %d checks, want %d", len(resp.Checks), len(tt.checks))
+			}
+			for _, c := range tt.checks {
+				if res := resp.Checks[c.Name]; res.Status == StatusFail && res.Error == "" {
+					t.Fatalf("failed check %s has no error", c.Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/tracing"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/entities"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Kinds of credentials principal was authenticated with
+const (
+	KindAPIKey = "api_key"
+	KindJWT    = "jwt"
+)
+
+// Principal is authenticated caller of API
+type Principal struct {
+	// ID is "api_key:<key id>" or "jwt:<subject>"
+	ID   string
+	Kind string
+	Name string
+	Role string
+	// Regions of cars principal has access to. Nil means all regions
+	Regions []string
+	// TenantID is empty for platform principals choosing tenant by X-Tenant-ID header
+	TenantID string
+	Platform bool
+}
+
+type ctxKeyPrincipal struct{}
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// WithPrincipal returns ctx carrying p
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKeyPrincipal{}, p)
+}
+
+// GetPrincipal returns principal of authenticated request or nil
+func GetPrincipal(ctx context.Context) *Principal {
+	p, _ := ctx.Value(ctxKeyPrincipal{}).(*Principal)
+	return p
+}
+
+// GetPrincipalID returns id of request principal or empty string, same as middleware.GetReqID
+func GetPrincipalID(ctx context.Context) string {
+	if p := GetPrincipal(ctx); p != nil {
+		return p.ID
+	}
+	return ""
+}
+
+// New returns middleware accepting API keys (Authorization: Bearer ck_... or X-API-Key header)
+// and JWT bearer tokens checked by verifier. verifier can be nil if JWT is not configured
+func New(log *slog.Logger, storage *postgres.Storage, verifier *Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			const op = "middleware.auth.New"
+
+			log := log.With(
+				slog.String("op", op),
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+				slog.String("trace_id", tracing.TraceID(r.Context())),
+			)
+
+			p, err := Authenticate(r.Context(), storage, verifier, requestToken(r))
+			// Case with storage error
+			if err != nil && !errors.Is(err, ErrNoCredentials) && !errors.Is(err, ErrInvalidCredentials) {
+				log.Error("failed to authenticate request", sl.Err(err))
+				w.WriteHeader(500)
+				return
+			}
+			// Case with missing or wrong credentials
+			if err != nil {
+				log.Info("unauthenticated request", sl.Err(err))
+				w.Header().Set("WWW-Authenticate", `Bearer realm="catalog"`)
+				w.WriteHeader(401)
+				render.JSON(w, r, "Error: "+err.Error())
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// requestToken returns API key or bearer token of request, empty string if there is none
+func requestToken(r *http.Request) string {
+	if token := r.Header.Get("X-API-Key"); token != "" {
+		return token
+	}
+	return BearerToken(r.Header.Get("Authorization"))
+}
+
+// BearerToken returns credentials of "Bearer <token>" authorization value
+func BearerToken(authorization string) string {
+	scheme, credentials, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(credentials)
+}
+
+// Authenticate returns principal of API key or JWT token. It is shared by HTTP
+// middleware and gRPC interceptors, so it doesn't depend on transport
+func Authenticate(ctx context.Context, storage *postgres.Storage, verifier *Verifier, token string) (*Principal, error) {
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+
+	if strings.HasPrefix(token, entities.APIKeyPrefix) {
+		var k entities.APIKey
+		err := k.GetByKey(ctx, storage, token)
+		if errors.Is(err, entities.ErrNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &Principal{
+			ID:       KindAPIKey + ":" + strconv.Itoa(k.KeyID),
+			Kind:     KindAPIKey,
+			Name:     k.Name,
+			Role:     k.Role,
+			Regions:  k.Regions,
+			TenantID: k.TenantID,
+			// Keys without tenant are created only directly in DB
+			Platform: k.TenantID == "",
+		}, nil
+	}
+
+	if verifier == nil {
+		return nil, ErrInvalidCredentials
+	}
+	claims, err := verifier.Verify(token)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	name := claims.Name
+	if name == "" {
+		name = claims.Subject
+	}
+	role := claims.Role
+	if role == "" {
+		role = RoleViewer
+	}
+	if !ValidRole(role) {
+		return nil, ErrInvalidCredentials
+	}
+	// Tokens issued without tenant are not platform ones, the platform claim is explicit
+	if claims.Tenant == "" && !claims.Platform || claims.Tenant != "" && claims.Platform {
+		return nil, ErrInvalidCredentials
+	}
+	return &Principal{
+		ID:       KindJWT + ":" + claims.Subject,
+		Kind:     KindJWT,
+		Name:     name,
+		Role:     role,
+		Regions:  claims.Regions,
+		TenantID: claims.Tenant,
+		Platform: claims.Platform,
+	}, nil
+}
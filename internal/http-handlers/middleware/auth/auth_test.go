@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestAuthenticateJWT(t *testing.T) {
+	const secret = "secret"
+	verifier, err := NewVerifier(secret, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := func(tenant string, platform bool) string {
+		claims := Claims{
+			Role:     RoleAdmin,
+			Tenant:   tenant,
+			Platform: platform,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "ops",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name         string
+		token        string
+		wantErr      error
+		wantPlatform bool
+	}{
+		{name: "tenant", token: token("depot", false)},
+		{name: "platform", token: token("", true), wantPlatform: true},
+		// Tokens issued before tenants existed have no access
+		{name: "without tenant", token: token("", false), wantErr: ErrInvalidCredentials},
+		{name: "tenant and platform", token: token("depot", true), wantErr: ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Authenticate(context.Background(), nil, verifier, tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && p.Platform != tt.wantPlatform {
+				t.Fatalf("platform = %v, want %v", p.Platform, tt.wantPlatform)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	errUnknownKey = errors.New("unknown signing key")
+)
+
+// Claims of accepted JWT. Subject is required, missing role means viewer and missing
+// regions mean access to all regions. Tenant is required unless platform is set: platform
+// principal has no tenant and selects it by X-Tenant-ID header
+type Claims struct {
+	Name     string   `json:"name,omitempty"`
+	Role     string   `json:"role,omitempty"`
+	Regions  []string `json:"regions,omitempty"`
+	Tenant   string   `json:"tenant,omitempty"`
+	Platform bool     `json:"platform,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Verifier checks JWT signed with HMAC secret or with keys from local JWKS file
+type Verifier struct {
+	hmacSecret []byte
+	// keys from JWKS by kid. Public keys for RS/PS/ES and []byte for HS algorithms
+	keys   map[string]any
+	parser *jwt.Parser
+}
+
+// NewVerifier makes verifier from HMAC secret and/or JWKS file path. Issuer and audience
+// are checked only when set
+func NewVerifier(hmacSecret, jwksPath, issuer, audience string) (*Verifier, error) {
+	const op = "middleware.auth.NewVerifier"
+
+	v := &Verifier{keys: make(map[string]any)}
+	methods := []string{}
+
+	if hmacSecret != "" {
+		v.hmacSecret = []byte(hmacSecret)
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if jwksPath != "" {
+		keys, err := loadJWKS(jwksPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		v.keys = keys
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512",
+			"ES256", "ES384", "ES512", "HS256", "HS384", "HS512")
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("%s: neither HMAC secret nor JWKS is set", op)
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+	v.parser = jwt.NewParser(opts...)
+
+	return v, nil
+}
+
+// Verify checks token signature and claims
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	const op = "middleware.auth.Verify"
+
+	var claims Claims
+	if _, err := v.parser.ParseWithClaims(token, &claims, v.keyFunc); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%s: token has no subject", op)
+	}
+
+	return &claims, nil
+}
+
+func (v *Verifier) keyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid != "" {
+		if key, ok := v.keys[kid]; ok {
+			return key, nil
+		}
+		return nil, errUnknownKey
+	}
+
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok && v.hmacSecret != nil {
+		return v.hmacSecret, nil
+	}
+	// Token without kid is accepted when JWKS has the only key
+	if len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, nil
+		}
+	}
+
+	return nil, errUnknownKey
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func loadJWKS(path string) (map[string]any, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for i, k := range set.Keys {
+		// Encryption keys are not for signatures
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.key()
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %d: %w", i, err)
+		}
+		kid := k.Kid
+		if kid == "" {
+			kid = fmt.Sprintf("#%d", i)
+		}
+		keys[kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS has no signing keys")
+	}
+
+	return keys, nil
+}
+
+func (k *jwk) key() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid e: %w", err)
+		}
+		if !e.IsInt64() {
+			return nil, errors.New("invalid e")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, fmt.Errorf("invalid k: %w", err)
+		}
+		return secret, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"catalog/internal/lib/tracing"
+	"catalog/internal/storage/entities"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Roles of principals. Each next role has all permissions of previous one
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+type Permission string
+
+const (
+	// PermRead allows to see cars
+	PermRead Permission = "read"
+	// PermWrite allows to create, edit and delete cars
+	PermWrite Permission = "write"
+	// PermAdmin allows to manage API keys
+	PermAdmin Permission = "admin"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleViewer: {PermRead},
+	RoleEditor: {PermRead, PermWrite},
+	RoleAdmin:  {PermRead, PermWrite, PermAdmin},
+}
+
+// ValidRole reports whether role is known
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Can reports whether principal role grants perm
+func (p *Principal) Can(perm Permission) bool {
+	if p == nil {
+		return false
+	}
+	for _, granted := range rolePermissions[p.Role] {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// GetScope returns cars visible for request principal in request tenant
+func GetScope(r *http.Request) entities.Scope {
+	return GetScopeFromContext(r.Context())
+}
+
+// GetScopeFromContext is GetScope for code getting request context only, e.g. GraphQL resolvers
+func GetScopeFromContext(ctx context.Context) entities.Scope {
+	p := GetPrincipal(ctx)
+	if p == nil {
+		// Nothing is visible for anonymous caller
+		return entities.Scope{Regions: []string{}}
+	}
+	return entities.Scope{
+		TenantID: GetTenantID(ctx),
+		Regions:  p.Regions,
+	}
+}
+
+// Require returns middleware rejecting principals without perm with 403
+func Require(log *slog.Logger, perm Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			const op = "middleware.auth.Require"
+
+			p := GetPrincipal(r.Context())
+			if !p.Can(perm) {
+				log.Info("permission denied",
+					slog.String("op", op),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.String("trace_id", tracing.TraceID(r.Context())),
+					slog.String("principal", GetPrincipalID(r.Context())),
+					slog.String("permission", string(perm)),
+				)
+				w.WriteHeader(403)
+				render.JSON(w, r, "Error: permission denied")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
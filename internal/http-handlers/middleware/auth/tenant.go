@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"catalog/internal/lib/tracing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// TenantHeader selects tenant for principals not bound to one
+const TenantHeader = "X-Tenant-ID"
+
+type ctxKeyTenant struct{}
+
+var (
+	ErrTenantMismatch = errors.New("tenant is not accessible")
+	ErrTenantRequired = errors.New("tenant is not set")
+)
+
+// WithTenantID returns ctx carrying tenantID
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, ctxKeyTenant{}, tenantID)
+}
+
+// TenantOf returns tenant of principal p requesting tenant requested, which can be empty.
+// Principal bound to tenant can't switch it, only platform principal selects tenant
+func TenantOf(p *Principal, requested string) (string, error) {
+	switch {
+	// Case with anonymous principal or principal without tenant which is not platform one
+	case p == nil || p.TenantID == "" && !p.Platform:
+		return "", ErrTenantMismatch
+	// Case with principal of another tenant
+	case p.TenantID != "" && requested != "" && requested != p.TenantID:
+		return "", ErrTenantMismatch
+	// Case with platform principal without selected tenant
+	case p.TenantID == "" && requested == "":
+		return "", ErrTenantRequired
+	case p.TenantID == "":
+		return requested, nil
+	}
+	return p.TenantID, nil
+}
+
+// GetTenantID returns tenant of request or empty string
+func GetTenantID(ctx context.Context) string {
+	tenantID, _ := ctx.Value(ctxKeyTenant{}).(string)
+	return tenantID
+}
+
+// ResolveTenant returns middleware taking tenant from principal or from X-Tenant-ID header.
+// Principal bound to tenant can't switch it by header
+func ResolveTenant(log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			const op = "middleware.auth.ResolveTenant"
+
+			log := log.With(
+				slog.String("op", op),
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+				slog.String("trace_id", tracing.TraceID(r.Context())),
+				slog.String("principal", GetPrincipalID(r.Context())),
+			)
+
+			headerTenantID := r.Header.Get(TenantHeader)
+			tenantID, err := TenantOf(GetPrincipal(r.Context()), headerTenantID)
+			// Case with principal of another tenant
+			if errors.Is(err, ErrTenantMismatch) {
+				log.Info("tenant mismatch", slog.String("tenant", headerTenantID))
+				w.WriteHeader(403)
+				render.JSON(w, r, "Error: tenant is not accessible")
+				return
+			}
+			// Case with platform principal without selected tenant
+			if err != nil {
+				log.Info("tenant is not set")
+				w.WriteHeader(400)
+				render.JSON(w, r, "Error: "+TenantHeader+" header is required")
+				return
+			}
+
+			ctx := WithTenantID(r.Context(), tenantID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
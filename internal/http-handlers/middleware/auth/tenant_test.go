@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveTenant(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name       string
+		principal  *Principal
+		header     string
+		wantStatus int
+		wantTenant string
+	}{
+		{
+			name:       "bound principal",
+			principal:  &Principal{ID: "api_key:1", Role: RoleViewer, TenantID: "depot-a"},
+			wantStatus: 200,
+			wantTenant: "depot-a",
+		},
+		{
+			name:       "bound principal with the same header",
+			principal:  &Principal{ID: "api_key:1", Role: RoleViewer, TenantID: "depot-a"},
+			header:     "depot-a",
+			wantStatus: 200,
+			wantTenant: "depot-a",
+		},
+		{
+			name:       "bound principal with another tenant header",
+			principal:  &Principal{ID: "api_key:1", Role: RoleAdmin, TenantID: "depot-a"},
+			header:     "depot-b",
+			wantStatus: 403,
+		},
+		{
+			name:       "platform principal with header",
+			principal:  &Principal{ID: "jwt:ops", Role: RoleAdmin, Platform: true},
+			header:     "depot-b",
+			wantStatus: 200,
+			wantTenant: "depot-b",
+		},
+		{
+			name:       "platform principal without header",
+			principal:  &Principal{ID: "jwt:ops", Role: RoleAdmin, Platform: true},
+			wantStatus: 400,
+		},
+		{
+			name:       "principal without tenant",
+			principal:  &Principal{ID: "jwt:legacy", Role: RoleAdmin},
+			header:     "depot-b",
+			wantStatus: 403,
+		},
+		{
+			name:       "anonymous",
+			header:     "depot-b",
+			wantStatus: 403,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotTenant string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotTenant = GetScope(r).TenantID
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/catalog", nil)
+			if tt.header != "" {
+				r.Header.Set(TenantHeader, tt.header)
+			}
+			r = r.WithContext(WithPrincipal(r.Context(), tt.principal))
+			w := httptest.NewRecorder()
+
+			ResolveTenant(log)(next).ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if gotTenant != tt.wantTenant {
+				t.Fatalf("tenant = %q, want %q", gotTenant, tt.wantTenant)
+			}
+		})
+	}
+}
+
+func TestGetScopeWithoutPrincipal(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/catalog", nil)
+
+	scope := GetScope(r)
+	if scope.TenantID != "" || !scope.Restricted() || scope.Allows("") {
+		t.Fatalf("anonymous scope must hide everything, got %+v", scope)
+	}
+}
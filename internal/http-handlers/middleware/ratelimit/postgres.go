@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/entities"
+)
+
+// Postgres keeps buckets in rate_limit_bucket table, so limits hold across instances
+type Postgres struct {
+	storage *postgres.Storage
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgres(storage *postgres.Storage) *Postgres {
+	return &Postgres{
+		storage:   storage,
+		lastSweep: time.Now(),
+	}
+}
+
+func (p *Postgres) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	if err := p.sweep(ctx); err != nil {
+		return Result{}, err
+	}
+
+	tokens, allowed, err := entities.TakeRateLimitToken(ctx, p.storage, key, l.Rate, l.Burst)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{Allowed: allowed, Tokens: tokens}, nil
+}
+
+// sweep drops idle buckets once in sweepInterval per instance
+func (p *Postgres) sweep(ctx context.Context) error {
+	p.mu.Lock()
+	now := time.Now()
+	due := now.Sub(p.lastSweep) >= sweepInterval
+	if due {
+		p.lastSweep = now
+	}
+	p.mu.Unlock()
+
+	if !due {
+		return nil
+	}
+	return entities.DeleteRateLimitBuckets(ctx, p.storage, now.Add(-idleTimeout))
+}
@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/tracing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Limit is token bucket refilled with Rate tokens per second up to Burst tokens
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Disabled reports whether limit lets everything through
+func (l Limit) Disabled() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// Result of taking token from bucket
+type Result struct {
+	Allowed bool
+	// Tokens left in bucket, can be fractional
+	Tokens float64
+}
+
+// Limiter keeps token buckets by key
+type Limiter interface {
+	Take(ctx context.Context, key string, l Limit) (Result, error)
+}
+
+// New returns middleware limiting requests of route group. Bucket is chosen by principal
+// (API key or JWT subject) and falls back to client IP for anonymous requests.
+// Limiter errors let requests through
+func New(log *slog.Logger, limiter Limiter, group string, l Limit) func(http.Handler) http.Handler {
+	return limit(log, limiter, group, l, func(r *http.Request) string {
+		if id := auth.GetPrincipalID(r.Context()); id != "" {
+			return id
+		}
+		return "ip:" + clientIP(r)
+	})
+}
+
+// ByIP is New choosing bucket by client IP only. It's mounted before authentication,
+// so requests with wrong credentials are limited too
+func ByIP(log *slog.Logger, limiter Limiter, group string, l Limit) func(http.Handler) http.Handler {
+	return limit(log, limiter, group, l, func(r *http.Request) string {
+		return "ip:" + clientIP(r)
+	})
+}
+
+func limit(log *slog.Logger, limiter Limiter, group string, l Limit, keyOf func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l.Disabled() {
+			return next
+		}
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			const op = "middleware.ratelimit.New"
+
+			key := keyOf(r)
+
+			res, err := limiter.Take(r.Context(), group+":"+key, l)
+			if err != nil {
+				log.Error("failed to take rate limit token",
+					slog.String("op", op),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.String("trace_id", tracing.TraceID(r.Context())),
+					sl.Err(err),
+				)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			setHeaders(w, l, res)
+
+			if !res.Allowed {
+				log.Info("rate limit exceeded",
+					slog.String("op", op),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.String("trace_id", tracing.TraceID(r.Context())),
+					slog.String("group", group),
+					slog.String("key", key),
+				)
+				w.Header().Set("Retry-After", strconv.Itoa(secondsUntil(1-res.Tokens, l.Rate)))
+				w.WriteHeader(429)
+				render.JSON(w, r, "Error: too many requests")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// setHeaders sets RateLimit-* headers of IETF httpapi-ratelimit-headers draft
+func setHeaders(w http.ResponseWriter, l Limit, res Result) {
+	remaining := int(math.Max(0, math.Floor(res.Tokens)))
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(l.Burst))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(secondsUntil(float64(l.Burst)-res.Tokens, l.Rate)))
+	w.Header().Set("RateLimit-Policy", strconv.Itoa(l.Burst)+";w="+strconv.Itoa(secondsUntil(float64(l.Burst), l.Rate)))
+}
+
+// secondsUntil returns whole seconds needed to refill tokens
+func secondsUntil(tokens, rate float64) int {
+	if tokens <= 0 {
+		return 0
+	}
+	return int(math.Ceil(tokens / rate))
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// refill returns tokens of bucket after elapsed time
+func refill(tokens float64, elapsed time.Duration, l Limit) float64 {
+	return math.Min(float64(l.Burst), tokens+elapsed.Seconds()*l.Rate)
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"catalog/internal/http-handlers/middleware/auth"
+)
+
+func TestMemoryRefill(t *testing.T) {
+	now := time.Now()
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	l := Limit{Rate: 1, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		if res, _ := m.Take(context.Background(), "k", l); !res.Allowed {
+			t.Fatalf("request %d within burst was rejected", i)
+		}
+	}
+	if res, _ := m.Take(context.Background(), "k", l); res.Allowed {
+		t.Fatal("request over burst was allowed")
+	}
+	// Other keys have own buckets
+	if res, _ := m.Take(context.Background(), "other", l); !res.Allowed {
+		t.Fatal("request of other key was rejected")
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	if res, _ := m.Take(context.Background(), "k", l); !res.Allowed {
+		t.Fatal("request after refill was rejected")
+	}
+	if res, _ := m.Take(context.Background(), "k", l); res.Allowed {
+		t.Fatal("refill must give only one token in 1.5s")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	l := Limit{Rate: 0.5, Burst: 1}
+	h := New(log, NewMemory(), "new", l)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest(http.MethodPost, "/new", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 200 {
+		t.Fatalf("first request status = %d, want 200", w.Code)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Fatalf("RateLimit-Remaining = %q, want 0", got)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 429 {
+		t.Fatalf("second request status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("Retry-After = %q, want 2", got)
+	}
+	if got := w.Header().Get("RateLimit-Limit"); got != "1" {
+		t.Fatalf("RateLimit-Limit = %q, want 1", got)
+	}
+
+	// Another client IP is not limited
+	r.RemoteAddr = "10.0.0.2:5000"
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 200 {
+		t.Fatalf("other client status = %d, want 200", w.Code)
+	}
+}
+
+func TestByIP(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	// Limit runs before authentication, rejected credentials take tokens too
+	h := ByIP(log, NewMemory(), "ip", Limit{Rate: 0.5, Burst: 1})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(401)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/catalog", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 401 {
+		t.Fatalf("first request status = %d, want 401", w.Code)
+	}
+
+	// Principals don't get own buckets
+	r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{ID: "api_key:1"}))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 429 {
+		t.Fatalf("second request status = %d, want 429", w.Code)
+	}
+}
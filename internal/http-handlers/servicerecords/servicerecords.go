@@ -0,0 +1,298 @@
+package servicerecords
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/tracing"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/entities"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+const dateLayout = "2006-01-02"
+
+// Request is service record of car. Date is in YYYY-MM-DD format and can't be in the future
+type Request struct {
+	Date     string  `json:"date" validate:"required,datetime=2006-01-02"`
+	Odometer int     `json:"odometer" validate:"gte=0"`
+	WorkType string  `json:"workType" validate:"required,max=200"`
+	Cost     float64 `json:"cost" validate:"gte=0"`
+	Notes    string  `json:"notes,omitempty" validate:"max=4000"`
+}
+
+// New adds service record to car {id}
+func New(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.servicerecords.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		carID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to make int car id", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		req, ok := decode(log, w, r)
+		if !ok {
+			return
+		}
+
+		s := record(req)
+		s.CarID = carID
+		s.CreatedBy = auth.GetPrincipalID(r.Context())
+		err = s.New(r.Context(), storage, auth.GetScope(r))
+		// Case with missing car or car outside of caller scope
+		if errors.Is(err, entities.ErrNotFound) {
+			w.WriteHeader(404)
+			log.Debug("car not found", sl.Err(err))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to add service record", sl.Err(err))
+			return
+		}
+
+		log.Info("service record added", slog.Int("record_id", s.RecordID))
+
+		render.Status(r, 201)
+		render.JSON(w, r, s)
+	}
+}
+
+// List returns service records of car {id}, the latest service first
+func List(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.servicerecords.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		carID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to make int car id", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		ss := entities.ServiceRecords{}
+		err = ss.Get(r.Context(), storage, carID, auth.GetScope(r))
+		// Case with missing car or car outside of caller scope
+		if errors.Is(err, entities.ErrNotFound) {
+			w.WriteHeader(404)
+			log.Debug("car not found", sl.Err(err))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to get service records", sl.Err(err))
+			return
+		}
+
+		render.JSON(w, r, ss)
+	}
+}
+
+// Get returns service record {recordId} of car {id}
+func Get(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.servicerecords.Get"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		carID, recordID, err := ids(r)
+		if err != nil {
+			log.Error("failed to make int ids", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		var s entities.ServiceRecord
+		err = s.Get(r.Context(), storage, carID, recordID, auth.GetScope(r))
+		// Case with missing record or car outside of caller scope
+		if errors.Is(err, entities.ErrNotFound) {
+			w.WriteHeader(404)
+			log.Debug("service record not found", sl.Err(err))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to get service record", sl.Err(err))
+			return
+		}
+
+		render.JSON(w, r, s)
+	}
+}
+
+// Edit replaces service record {recordId} of car {id}
+func Edit(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.servicerecords.Edit"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		carID, recordID, err := ids(r)
+		if err != nil {
+			log.Error("failed to make int ids", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		req, ok := decode(log, w, r)
+		if !ok {
+			return
+		}
+
+		s := record(req)
+		s.RecordID = recordID
+		s.CarID = carID
+		err = s.Edit(r.Context(), storage, auth.GetScope(r))
+		// Case with missing record or car outside of caller scope
+		if errors.Is(err, entities.ErrNotFound) {
+			w.WriteHeader(404)
+			log.Debug("service record not found", sl.Err(err))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to edit service record", sl.Err(err))
+			return
+		}
+
+		log.Info("service record edited", slog.Int("record_id", recordID))
+
+		render.JSON(w, r, s)
+	}
+}
+
+// Delete deletes service record {recordId} of car {id}
+func Delete(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.servicerecords.Delete"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		carID, recordID, err := ids(r)
+		if err != nil {
+			log.Error("failed to make int ids", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		var s entities.ServiceRecord
+		err = s.Delete(r.Context(), storage, carID, recordID, auth.GetScope(r))
+		// Case with missing record or car outside of caller scope
+		if errors.Is(err, entities.ErrNotFound) {
+			w.WriteHeader(404)
+			log.Debug("service record not found", sl.Err(err))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to delete service record", sl.Err(err))
+			return
+		}
+
+		log.Info("service record deleted", slog.Int("record_id", recordID))
+	}
+}
+
+// decode reads and validates request JSON, bad request is answered with 400
+func decode(log *slog.Logger, w http.ResponseWriter, r *http.Request) (Request, bool) {
+	var req Request
+
+	// Decode request JSON
+	err := render.DecodeJSON(r.Body, &req)
+	// Case with empty request
+	if errors.Is(err, io.EOF) {
+		log.Error("request body is empty")
+		w.WriteHeader(400)
+		return req, false
+	}
+	// Case with common errors
+	if err != nil {
+		log.Error("failed to decode request body", sl.Err(err))
+		w.WriteHeader(400)
+		return req, false
+	}
+
+	// Validate request JSON
+	if err := validator.New().Struct(req); err != nil {
+		w.WriteHeader(400)
+		log.Error("invalid request", sl.Err(err))
+		return req, false
+	}
+
+	// Case with service in the future, date is already valid
+	date, _ := time.Parse(dateLayout, req.Date)
+	if date.After(time.Now()) {
+		w.WriteHeader(400)
+		render.JSON(w, r, "Error: date must not be in the future")
+		log.Debug("service date in the future", slog.String("date", req.Date))
+		return req, false
+	}
+
+	log.Info("request body decoded", slog.Any("request", req))
+
+	return req, true
+}
+
+func record(req Request) entities.ServiceRecord {
+	return entities.ServiceRecord{
+		Date:     req.Date,
+		Odometer: req.Odometer,
+		WorkType: req.WorkType,
+		Cost:     req.Cost,
+		Notes:    req.Notes,
+	}
+}
+
+func ids(r *http.Request) (int, int, error) {
+	carID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return 0, 0, err
+	}
+	recordID, err := strconv.Atoi(chi.URLParam(r, "recordId"))
+	if err != nil {
+		return 0, 0, err
+	}
+	return carID, recordID, nil
+}
@@ -0,0 +1,234 @@
+package stats
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"catalog/internal/http-handlers/catalog"
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/tracing"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/entities"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Defaults and limits of query parameters
+const (
+	defaultBucket = 5
+	defaultLimit  = 10
+	maxLimit      = 100
+)
+
+// Handlers take the same filter parameters as /catalog. snapshot lets them read
+// materialized views refreshed on schedule instead of tables
+
+// Counts returns cars count grouped by comma separated fields of by parameter
+func Counts(log *slog.Logger, storage *postgres.Storage, snapshot bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.stats.Counts"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		c, err := catalog.ParseFilter(r.URL.Query())
+		if err != nil {
+			log.Debug("failed to parse filter", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+		by, err := parseBy(r.URL.Query())
+		// Case with unknown grouping field
+		if err != nil {
+			log.Debug("failed to parse grouping fields", sl.Err(err))
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: "+err.Error())
+			return
+		}
+
+		var cc entities.CarCounts
+		if err := cc.Get(r.Context(), storage, &c, by, auth.GetScope(r), snapshot); err != nil {
+			log.Error("failed to count cars", sl.Err(err))
+			w.WriteHeader(500)
+			return
+		}
+
+		render.JSON(w, r, cc)
+	}
+}
+
+// Ages returns age distribution of cars grouped by fields of by parameter.
+// bucket parameter is width of histogram bucket in years
+func Ages(log *slog.Logger, storage *postgres.Storage, snapshot bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.stats.Ages"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		c, err := catalog.ParseFilter(r.URL.Query())
+		if err != nil {
+			log.Debug("failed to parse filter", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+		by, err := parseBy(r.URL.Query())
+		// Case with unknown grouping field
+		if err != nil {
+			log.Debug("failed to parse grouping fields", sl.Err(err))
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: "+err.Error())
+			return
+		}
+		bucket, err := parseInt(r.URL.Query(), "bucket", defaultBucket, 1, 100)
+		if err != nil {
+			log.Debug("failed to parse bucket", sl.Err(err))
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: "+err.Error())
+			return
+		}
+
+		var as entities.AgeStats
+		if err := as.Get(r.Context(), storage, &c, by, bucket, auth.GetScope(r), snapshot); err != nil {
+			log.Error("failed to get age stats", sl.Err(err))
+			w.WriteHeader(500)
+			return
+		}
+
+		render.JSON(w, r, as)
+	}
+}
+
+// Owners returns owners with the most cars. minCars parameter skips owners of fewer
+// cars, limit parameter is number of owners
+func Owners(log *slog.Logger, storage *postgres.Storage, snapshot bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.stats.Owners"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		c, err := catalog.ParseFilter(r.URL.Query())
+		if err != nil {
+			log.Debug("failed to parse filter", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+		minCars, err := parseInt(r.URL.Query(), "minCars", 1, 1, 0)
+		if err != nil {
+			log.Debug("failed to parse minCars", sl.Err(err))
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: "+err.Error())
+			return
+		}
+		limit, err := parseInt(r.URL.Query(), "limit", defaultLimit, 1, maxLimit)
+		if err != nil {
+			log.Debug("failed to parse limit", sl.Err(err))
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: "+err.Error())
+			return
+		}
+
+		var to entities.TopOwners
+		if err := to.Get(r.Context(), storage, &c, minCars, limit, auth.GetScope(r), snapshot); err != nil {
+			log.Error("failed to get top owners", sl.Err(err))
+			w.WriteHeader(500)
+			return
+		}
+
+		render.JSON(w, r, to)
+	}
+}
+
+// Growth returns cars added by month or year of interval parameter
+func Growth(log *slog.Logger, storage *postgres.Storage, snapshot bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.stats.Growth"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		c, err := catalog.ParseFilter(r.URL.Query())
+		if err != nil {
+			log.Debug("failed to parse filter", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+		interval := r.URL.Query().Get("interval")
+		if interval == "" {
+			interval = entities.IntervalMonth
+		}
+		// Case with unknown interval
+		if interval != entities.IntervalMonth && interval != entities.IntervalYear {
+			log.Debug("unknown interval", slog.String("interval", interval))
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: interval must be month or year")
+			return
+		}
+
+		var g entities.Growth
+		if err := g.Get(r.Context(), storage, &c, interval, auth.GetScope(r), snapshot); err != nil {
+			log.Error("failed to get growth stats", sl.Err(err))
+			w.WriteHeader(500)
+			return
+		}
+
+		render.JSON(w, r, g)
+	}
+}
+
+// parseBy reads comma separated grouping fields, repeated ones are dropped
+func parseBy(query url.Values) ([]string, error) {
+	var by []string
+	seen := make(map[string]bool)
+	for _, f := range strings.Split(query.Get("by"), ",") {
+		f = strings.TrimSpace(f)
+		if f == "" || seen[f] {
+			continue
+		}
+		seen[f] = true
+		by = append(by, f)
+	}
+	if err := entities.ValidateStatsFields(by); err != nil {
+		return nil, err
+	}
+	return by, nil
+}
+
+// parseInt reads integer parameter in [min, max], zero max means no upper bound
+func parseInt(query url.Values, name string, def, min, max int) (int, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be integer", name)
+	}
+	if v < min || (max > 0 && v > max) {
+		return 0, fmt.Errorf("%s is out of range", name)
+	}
+	return v, nil
+}
@@ -0,0 +1,186 @@
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"catalog/internal/http-handlers/catalog"
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/tracing"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/changefeed"
+	"catalog/internal/storage/entities"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+const heartbeatInterval = 15 * time.Second
+
+func New(log *slog.Logger, storage *postgres.Storage, feed *changefeed.Feed) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.stream.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		filter, err := catalog.ParseFilter(r.URL.Query())
+		if err != nil {
+			log.Debug("failed to parse filter", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+		tags, err := catalog.ParseTags(r.URL.Query())
+		// Case with invalid tag
+		if err != nil {
+			log.Debug("failed to parse tags", sl.Err(err))
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: "+err.Error())
+			return
+		}
+		// Case with filter changes can't be matched by, they don't carry tags and services
+		if tags != nil || r.URL.Query().Get("serviceDue") != "" {
+			log.Debug("unsupported filter of stream")
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: tag and serviceDue filters are not supported by stream")
+			return
+		}
+		if catalog.HasAttributes(r.URL.Query()) {
+			var schema entities.AttributeSchema
+			if err := schema.Get(r.Context(), storage, auth.GetScope(r).TenantID); err != nil {
+				log.Error("failed to get attribute schema", sl.Err(err))
+				w.WriteHeader(500)
+				return
+			}
+			var order *entities.AttributeOrder
+			filter.Attributes, order, err = catalog.ParseAttributes(r.URL.Query(), schema)
+			// Case with stream sorted, changes go in order they are made
+			if err == nil && order != nil {
+				err = errors.New("sort is not supported by stream")
+			}
+			// Case with unknown attribute or value of wrong type
+			if err != nil {
+				log.Debug("failed to parse attributes", sl.Err(err))
+				w.WriteHeader(400)
+				render.JSON(w, r, "Error: "+err.Error())
+				return
+			}
+		}
+
+		scope := auth.GetScope(r)
+
+		// Resume position from reconnecting EventSource or from query for manual clients
+		var lastID int64
+		rawLastID := r.Header.Get("Last-Event-ID")
+		if rawLastID == "" {
+			rawLastID = r.URL.Query().Get("lastEventId")
+		}
+		if rawLastID != "" {
+			lastID, err = strconv.ParseInt(rawLastID, 10, 64)
+			if err != nil {
+				log.Debug("failed to make int Last-Event-ID", sl.Err(err))
+				w.WriteHeader(400)
+				return
+			}
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			log.Error("streaming is not supported by response writer")
+			w.WriteHeader(500)
+			return
+		}
+
+		// Subscribe before catch up, so changes made meanwhile are not lost
+		changes, unsubscribe := feed.Subscribe()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(200)
+		flusher.Flush()
+
+		log.Info("stream opened", slog.Int64("last_event_id", lastID))
+
+		// Send changes missed since Last-Event-ID
+		if rawLastID != "" {
+			var writeErr error
+			lastID, err = changefeed.CatchUp(r.Context(), storage, lastID, scope, func(ccs entities.CarChanges) error {
+				for _, cc := range ccs {
+					if writeErr = writeChange(w, &cc, &filter, &scope); writeErr != nil {
+						return writeErr
+					}
+				}
+				flusher.Flush()
+				return nil
+			})
+			if writeErr != nil {
+				log.Debug("failed to write event", sl.Err(writeErr))
+				return
+			}
+			if err != nil {
+				log.Error("failed to read change log", sl.Err(err))
+				return
+			}
+		}
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				log.Info("stream closed")
+				return
+			case cc, ok := <-changes:
+				if !ok {
+					log.Info("stream closed by feed")
+					return
+				}
+				// Skip changes already sent on catch up
+				if cc.ChangeID <= lastID {
+					continue
+				}
+				if err := writeChange(w, &cc, &filter, &scope); err != nil {
+					log.Debug("failed to write event", sl.Err(err))
+					return
+				}
+				lastID = cc.ChangeID
+				flusher.Flush()
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					log.Debug("failed to write heartbeat", sl.Err(err))
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// writeChange writes change as SSE event if car fits filter and scope
+func writeChange(w http.ResponseWriter, cc *entities.CarChange, filter *entities.Car, scope *entities.Scope) error {
+	if cc.TenantID != scope.TenantID || !cc.Car.Matches(filter) || !scope.Allows(cc.Car.Region) {
+		return nil
+	}
+
+	data, err := json.Marshal(cc)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", cc.ChangeID, cc.Op, data)
+	return err
+}
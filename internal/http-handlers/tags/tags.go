@@ -0,0 +1,255 @@
+package tags
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"catalog/internal/http-handlers/middleware/auth"
+	"catalog/internal/lib/logger/sl"
+	"catalog/internal/lib/tracing"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/entities"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+// BulkRequest adds tags Add to cars CarIDs and removes tags Remove of them
+type BulkRequest struct {
+	CarIDs []int    `json:"carIds" validate:"required,max=1000,dive,gt=0"`
+	Add    []string `json:"add,omitempty" validate:"max=100"`
+	Remove []string `json:"remove,omitempty" validate:"max=100"`
+}
+
+// Response tells how many car tags were changed, already set and missing ones are not counted
+type Response struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+// List returns tags of cars visible for caller with count of cars, the most used first
+func List(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.tags.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		ts := entities.Tags{}
+		if err := ts.Get(r.Context(), storage, auth.GetScope(r)); err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to get tags", sl.Err(err))
+			return
+		}
+
+		render.JSON(w, r, ts)
+	}
+}
+
+// CarTags returns sorted tags of car {id}
+func CarTags(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.tags.CarTags"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		carID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to make int car id", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		tags, err := entities.GetCarTags(r.Context(), storage, carID, auth.GetScope(r))
+		// Case with missing car or car outside of caller scope
+		if errors.Is(err, entities.ErrNotFound) {
+			w.WriteHeader(404)
+			log.Debug("car not found", sl.Err(err))
+			return
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			log.Error("failed to get car tags", sl.Err(err))
+			return
+		}
+
+		render.JSON(w, r, tags)
+	}
+}
+
+// Add adds tag {tag} to car {id}
+func Add(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return single(log, "handlers.tags.Add", storage, false)
+}
+
+// Remove removes tag {tag} of car {id}
+func Remove(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return single(log, "handlers.tags.Remove", storage, true)
+}
+
+// single changes one tag of one car
+func single(log *slog.Logger, op string, storage *postgres.Storage, remove bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		carID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error("failed to make int car id", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+		tag, err := tagParam(r)
+		// Case with invalid tag
+		if err != nil {
+			log.Debug("invalid tag", sl.Err(err))
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: "+err.Error())
+			return
+		}
+
+		te := entities.TagEdit{CarIDs: []int{carID}, CreatedBy: auth.GetPrincipalID(r.Context())}
+		if remove {
+			te.Remove = []string{tag}
+		} else {
+			te.Add = []string{tag}
+		}
+		apply(log, w, r, storage, &te)
+	}
+}
+
+// Bulk changes tags of many cars at once, see BulkRequest
+func Bulk(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.tags.Bulk"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", tracing.TraceID(r.Context())),
+			slog.String("principal", auth.GetPrincipalID(r.Context())),
+		)
+
+		var req BulkRequest
+
+		// Decode request JSON
+		err := render.DecodeJSON(r.Body, &req)
+		// Case with empty request
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			w.WriteHeader(400)
+			return
+		}
+		// Case with common errors
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			w.WriteHeader(400)
+			return
+		}
+
+		// Validate request JSON
+		if err := validator.New().Struct(req); err != nil {
+			w.WriteHeader(400)
+			log.Error("invalid request", sl.Err(err))
+			return
+		}
+		// Case with nothing to change
+		if len(req.Add) == 0 && len(req.Remove) == 0 {
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: add or remove is required")
+			log.Debug("no tags to change")
+			return
+		}
+
+		te := entities.TagEdit{CarIDs: req.CarIDs, CreatedBy: auth.GetPrincipalID(r.Context())}
+		if te.Add, err = normalize(req.Add); err == nil {
+			te.Remove, err = normalize(req.Remove)
+		}
+		// Case with invalid tag
+		if err != nil {
+			w.WriteHeader(400)
+			render.JSON(w, r, "Error: "+err.Error())
+			log.Debug("invalid tag", sl.Err(err))
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+
+		apply(log, w, r, storage, &te)
+	}
+}
+
+// apply writes result of te
+func apply(log *slog.Logger, w http.ResponseWriter, r *http.Request, storage *postgres.Storage, te *entities.TagEdit) {
+	err := te.Apply(r.Context(), storage, auth.GetScope(r))
+	// Case with missing car or car outside of caller scope
+	if errors.Is(err, entities.ErrNotFound) {
+		w.WriteHeader(404)
+		log.Debug("car not found", sl.Err(err))
+		return
+	}
+	if err != nil {
+		w.WriteHeader(500)
+		log.Error("failed to change tags", sl.Err(err))
+		return
+	}
+
+	log.Info("tags changed", slog.Int("added", te.Added), slog.Int("removed", te.Removed))
+
+	render.JSON(w, r, Response{Added: te.Added, Removed: te.Removed})
+}
+
+// tagParam returns unescaped {tag}. Router unescapes path itself unless it has
+// escaped slashes
+func tagParam(r *http.Request) (string, error) {
+	tag := chi.URLParam(r, "tag")
+	if r.URL.RawPath != "" {
+		var err error
+		if tag, err = url.PathUnescape(tag); err != nil {
+			return "", err
+		}
+	}
+	tags, err := normalize([]string{tag})
+	if err != nil {
+		return "", err
+	}
+	return tags[0], nil
+}
+
+// normalize trims tags and drops repeated ones
+func normalize(tags []string) ([]string, error) {
+	var unique []string
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if !entities.ValidTag(t) {
+			return nil, fmt.Errorf("tag must be 1 to %d characters, got %q", entities.TagMaxLen, t)
+		}
+		if !seen[t] {
+			seen[t] = true
+			unique = append(unique, t)
+		}
+	}
+	return unique, nil
+}
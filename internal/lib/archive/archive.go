@@ -0,0 +1,142 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"catalog/internal/lib/metrics"
+	"catalog/internal/lib/tracing"
+
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrNotFound = errors.New("car not found in archive")
+	// ErrInvalidResponse is returned for empty or incomplete archive answer
+	ErrInvalidResponse = errors.New("invalid archive response")
+)
+
+type Person struct {
+	Name       string `json:"name,omitempty" validate:"required"`
+	Surname    string `json:"surname,omitempty" validate:"required"`
+	Patronymic string `json:"patronymic,omitempty"`
+}
+
+type Car struct {
+	RegNum string `json:"regNum,omitempty" validate:"required"`
+	Mark   string `json:"mark,omitempty" validate:"required"`
+	Model  string `json:"model,omitempty" validate:"required"`
+	Year   int    `json:"year,omitempty"`
+	Owner  Person `json:"owner,omitempty" validate:"required"`
+}
+
+// Client of archive with car information by registration number
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func New(baseURL string, timeout time.Duration) *Client {
+	return &Client{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// GetCar requests car with regNum from archive
+func (c *Client) GetCar(ctx context.Context, regNum string) (*Car, error) {
+	const op = "lib.archive.GetCar"
+
+	ctx, span := tracing.Start(ctx, "archive GetCar", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("car.reg_num", regNum)))
+
+	start := time.Now()
+	car, outcome, err := c.getCar(ctx, regNum)
+	metrics.ObserveArchive(outcome, start)
+	span.SetAttributes(attribute.String("archive.outcome", outcome))
+	// Missing car is regular answer of archive
+	if errors.Is(err, ErrNotFound) {
+		tracing.End(span, nil)
+	} else {
+		tracing.End(span, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return car, nil
+}
+
+// Ping checks that archive answers HTTP requests. Any status below 500 is fine,
+// archive has no dedicated health endpoint
+func (c *Client) Ping(ctx context.Context) error {
+	const op = "lib.archive.Ping"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.baseURL+"/car_information", nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%s: archive responded with status %d", op, resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (c *Client) getCar(ctx context.Context, regNum string) (*Car, string, error) {
+	// GET request to archive. Response about car with regNum
+	reqURL := c.baseURL + "/car_information?" + url.Values{"regNum": {regNum}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, metrics.ArchiveError, err
+	}
+	// Archive continues our trace
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, metrics.ArchiveError, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, metrics.ArchiveNotFound, ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, metrics.ArchiveBadStatus, fmt.Errorf("archive responded with status %d", resp.StatusCode)
+	}
+
+	var car Car
+	// Decode response JSON
+	err = render.DecodeJSON(resp.Body, &car)
+	// Case with empty response
+	if errors.Is(err, io.EOF) {
+		return nil, metrics.ArchiveInvalid, fmt.Errorf("%w: empty body", ErrInvalidResponse)
+	}
+	if err != nil {
+		return nil, metrics.ArchiveInvalid, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+
+	// Validate response JSON
+	if err := validator.New().Struct(car); err != nil {
+		return nil, metrics.ArchiveInvalid, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+
+	return &car, metrics.ArchiveOK, nil
+}
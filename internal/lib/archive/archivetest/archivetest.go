@@ -0,0 +1,146 @@
+// Package archivetest implements archive contract for development and tests:
+// GET /car_information?regNum=X answers with car or 404
+package archivetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"catalog/internal/lib/archive"
+	"catalog/internal/lib/seed"
+)
+
+type Options struct {
+	// Cars by registration number. Cars missing here are generated if Generate is set,
+	// otherwise they are not found
+	Cars map[string]archive.Car
+	// Generate makes car for any regNum, the same for the same Seed and regNum
+	Generate bool
+	Seed     int64
+	// NotFound regNums are answered with 404 even if they are in Cars
+	NotFound map[string]bool
+	// NotFoundRate and ErrorRate are shares of requests answered with 404 and 500
+	NotFoundRate float64
+	ErrorRate    float64
+	// Every answer is delayed by Latency plus random up to Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+}
+
+// Server is archive stand-in
+type Server struct {
+	opts Options
+
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func NewServer(opts Options) *Server {
+	return &Server{
+		opts: opts,
+		r:    rand.New(rand.NewSource(opts.Seed)),
+	}
+}
+
+// LoadCars reads fixture file with JSON array of archive cars
+func LoadCars(path string) (map[string]archive.Car, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []archive.Car
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("fixture %s: %w", path, err)
+	}
+
+	cars := make(map[string]archive.Car, len(list))
+	for _, c := range list {
+		cars[c.RegNum] = c
+	}
+	return cars, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/car_information" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	delay, failed, missing := s.roll()
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	regNum := r.URL.Query().Get("regNum")
+	if regNum == "" {
+		http.Error(w, "regNum is required", http.StatusBadRequest)
+		return
+	}
+	if failed {
+		http.Error(w, "archive failure", http.StatusInternalServerError)
+		return
+	}
+
+	car, ok := s.car(regNum)
+	if !ok || missing || s.opts.NotFound[regNum] {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(car)
+}
+
+// roll decides delay and failures of request
+func (s *Server) roll() (time.Duration, bool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delay := s.opts.Latency
+	if s.opts.Jitter > 0 {
+		delay += time.Duration(s.r.Int63n(int64(s.opts.Jitter)))
+	}
+	failed := s.r.Float64() < s.opts.ErrorRate
+	missing := s.r.Float64() < s.opts.NotFoundRate
+	return delay, failed, missing
+}
+
+func (s *Server) car(regNum string) (archive.Car, bool) {
+	if c, ok := s.opts.Cars[regNum]; ok {
+		return c, true
+	}
+	if !s.opts.Generate {
+		return archive.Car{}, false
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(regNum))
+	g := seed.New(seed.Options{Seed: s.opts.Seed ^ int64(h.Sum64()), MinYear: 1980, MaxYear: time.Now().Year()})
+	c := g.Car()
+
+	return archive.Car{
+		RegNum: regNum,
+		Mark:   c.Mark,
+		Model:  c.Model,
+		Year:   c.Year,
+		Owner: archive.Person{
+			Name:       c.Owner.Name,
+			Surname:    c.Owner.Surname,
+			Patronymic: c.Owner.Patronymic,
+		},
+	}, true
+}
@@ -0,0 +1,54 @@
+package archivetest
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"catalog/internal/lib/archive"
+)
+
+func TestServer(t *testing.T) {
+	fixture := archive.Car{RegNum: "X123XX150", Mark: "Lada", Model: "Vesta", Year: 2002,
+		Owner: archive.Person{Name: "Ivan", Surname: "Petrov"}}
+	srv := httptest.NewServer(NewServer(Options{
+		Cars:     map[string]archive.Car{fixture.RegNum: fixture},
+		Generate: true,
+		Seed:     7,
+		NotFound: map[string]bool{"A000AA00": true},
+	}))
+	defer srv.Close()
+
+	client := archive.New(srv.URL, time.Second)
+	ctx := context.Background()
+
+	car, err := client.GetCar(ctx, fixture.RegNum)
+	if err != nil || *car != fixture {
+		t.Fatalf("fixture car = %+v, %v", car, err)
+	}
+
+	if _, err := client.GetCar(ctx, "A000AA00"); !errors.Is(err, archive.ErrNotFound) {
+		t.Fatalf("not found regNum error = %v", err)
+	}
+
+	c1, err := client.GetCar(ctx, "B777BB77")
+	if err != nil {
+		t.Fatalf("failed to get generated car: %v", err)
+	}
+	c2, _ := client.GetCar(ctx, "B777BB77")
+	if c1.RegNum != "B777BB77" || *c1 != *c2 {
+		t.Fatalf("generated cars differ: %+v and %+v", c1, c2)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	srv := httptest.NewServer(NewServer(Options{Generate: true, ErrorRate: 1}))
+	defer srv.Close()
+
+	_, err := archive.New(srv.URL, time.Second).GetCar(context.Background(), "B777BB77")
+	if err == nil || errors.Is(err, archive.ErrNotFound) {
+		t.Fatalf("error = %v, want archive failure", err)
+	}
+}
@@ -0,0 +1,85 @@
+// Package blobtest implements in-memory stand-in of S3 compatible storage for development
+// and tests: PUT, GET and DELETE of objects addressed in path style /bucket/key
+package blobtest
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type object struct {
+	data        []byte
+	contentType string
+}
+
+// S3Server keeps objects of any bucket. Requests must be signed with AccessKey,
+// signature itself isn't checked
+type S3Server struct {
+	AccessKey string
+
+	mu      sync.Mutex
+	objects map[string]object
+}
+
+func NewS3Server(accessKey string) *S3Server {
+	return &S3Server{AccessKey: accessKey, objects: make(map[string]object)}
+}
+
+// Keys returns paths of stored objects as bucket/key in sorted order
+func (s *S3Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.objects))
+	for k := range s.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *S3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	credential := "AWS4-HMAC-SHA256 Credential=" + s.AccessKey + "/"
+	if !strings.HasPrefix(r.Header.Get("Authorization"), credential) || r.Header.Get("X-Amz-Date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if bucket, key, ok := strings.Cut(path, "/"); !ok || bucket == "" || key == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.objects[path] = object{data: data, contentType: r.Header.Get("Content-Type")}
+		s.mu.Unlock()
+	case http.MethodGet:
+		s.mu.Lock()
+		obj, ok := s.objects[path]
+		s.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Write(obj.data)
+	case http.MethodDelete:
+		s.mu.Lock()
+		delete(s.objects, path)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
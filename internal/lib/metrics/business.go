@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// countsMaxAge is how long counts are reused by scrapes, each query counts all cars
+const countsMaxAge = time.Minute
+
+// CountFunc returns counts by tenant
+type CountFunc func() (map[string]int, error)
+
+// businessCollector queries catalog counts on scrape if cached ones are older than countsMaxAge
+type businessCollector struct {
+	cars   CountFunc
+	owners CountFunc
+
+	carsDesc   *prometheus.Desc
+	ownersDesc *prometheus.Desc
+}
+
+// RegisterBusiness exports cars and owners counts by tenant
+func RegisterBusiness(cars, owners CountFunc) error {
+	return prometheus.Register(&businessCollector{
+		cars:   cached(cars, countsMaxAge),
+		owners: cached(owners, countsMaxAge),
+		carsDesc: prometheus.NewDesc(namespace+"_cars", "Active cars in catalog.",
+			[]string{"tenant"}, nil),
+		ownersDesc: prometheus.NewDesc(namespace+"_owners", "Persons owning at least one active car.",
+			[]string{"tenant"}, nil),
+	})
+}
+
+func (c *businessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.carsDesc
+	ch <- c.ownersDesc
+}
+
+func (c *businessCollector) Collect(ch chan<- prometheus.Metric) {
+	collectCounts(ch, c.carsDesc, c.cars)
+	collectCounts(ch, c.ownersDesc, c.owners)
+}
+
+func collectCounts(ch chan<- prometheus.Metric, desc *prometheus.Desc, count CountFunc) {
+	counts, err := count()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(desc, err)
+		return
+	}
+	for tenantID, n := range counts {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(n), tenantID)
+	}
+}
+
+// cached returns count reusing its result for maxAge. Errors are not cached
+func cached(count CountFunc, maxAge time.Duration) CountFunc {
+	var mu sync.Mutex
+	var counts map[string]int
+	var countedAt time.Time
+	return func() (map[string]int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if counts != nil && time.Since(countedAt) < maxAge {
+			return counts, nil
+		}
+		c, err := count()
+		if err != nil {
+			return nil, err
+		}
+		counts, countedAt = c, time.Now()
+		return counts, nil
+	}
+}
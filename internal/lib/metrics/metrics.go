@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "catalog"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route pattern and status.",
+	}, []string{"method", "route", "status"})
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Duration of storage operations.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"op"})
+
+	archiveRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "archive_requests_total",
+		Help:      "Archive requests by outcome.",
+	}, []string{"outcome"})
+	archiveDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "archive_request_duration_seconds",
+		Help:      "Archive request latency by outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+)
+
+// Handler serves metrics of default registry
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware counts requests and their latency by chi route pattern, so path
+// parameters don't multiply series
+func Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		// Handler wrote nothing
+		if status == 0 {
+			status = 200
+		}
+
+		httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		httpDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	}
+	return http.HandlerFunc(fn)
+}
+
+// ObserveQuery records duration of storage operation op started at start.
+// Use as defer metrics.ObserveQuery(op, time.Now())
+func ObserveQuery(op string, start time.Time) {
+	queryDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
+// Outcomes of archive requests
+const (
+	ArchiveOK        = "ok"
+	ArchiveNotFound  = "not_found"
+	ArchiveBadStatus = "bad_status"
+	ArchiveInvalid   = "invalid"
+	ArchiveError     = "error"
+)
+
+// ObserveArchive records archive request started at start
+func ObserveArchive(outcome string, start time.Time) {
+	archiveRequests.WithLabelValues(outcome).Inc()
+	archiveDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
+
+// RegisterDB exports connection pool stats of db
+func RegisterDB(db *sql.DB) error {
+	return prometheus.Register(collectors.NewDBStatsCollector(db, "catalog"))
+}
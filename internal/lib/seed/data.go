@@ -0,0 +1,134 @@
+package seed
+
+type maleName struct {
+	name string
+	// Patronymics of children
+	son      string
+	daughter string
+}
+
+var maleNames = []maleName{
+	{"Aleksandr", "Aleksandrovich", "Aleksandrovna"},
+	{"Aleksey", "Alekseevich", "Alekseevna"},
+	{"Andrey", "Andreevich", "Andreevna"},
+	{"Anton", "Antonovich", "Antonovna"},
+	{"Artem", "Artemovich", "Artemovna"},
+	{"Boris", "Borisovich", "Borisovna"},
+	{"Denis", "Denisovich", "Denisovna"},
+	{"Dmitriy", "Dmitrievich", "Dmitrievna"},
+	{"Egor", "Egorovich", "Egorovna"},
+	{"Evgeniy", "Evgenievich", "Evgenievna"},
+	{"Fedor", "Fedorovich", "Fedorovna"},
+	{"Grigoriy", "Grigorievich", "Grigorievna"},
+	{"Igor", "Igorevich", "Igorevna"},
+	{"Ilya", "Ilyich", "Ilyinichna"},
+	{"Ivan", "Ivanovich", "Ivanovna"},
+	{"Kirill", "Kirillovich", "Kirillovna"},
+	{"Konstantin", "Konstantinovich", "Konstantinovna"},
+	{"Leonid", "Leonidovich", "Leonidovna"},
+	{"Maksim", "Maksimovich", "Maksimovna"},
+	{"Mikhail", "Mikhaylovich", "Mikhaylovna"},
+	{"Nikita", "Nikitich", "Nikitichna"},
+	{"Nikolay", "Nikolaevich", "Nikolaevna"},
+	{"Oleg", "Olegovich", "Olegovna"},
+	{"Pavel", "Pavlovich", "Pavlovna"},
+	{"Petr", "Petrovich", "Petrovna"},
+	{"Roman", "Romanovich", "Romanovna"},
+	{"Sergey", "Sergeevich", "Sergeevna"},
+	{"Stanislav", "Stanislavovich", "Stanislavovna"},
+	{"Timur", "Timurovich", "Timurovna"},
+	{"Vadim", "Vadimovich", "Vadimovna"},
+	{"Valeriy", "Valerievich", "Valerievna"},
+	{"Viktor", "Viktorovich", "Viktorovna"},
+	{"Vladimir", "Vladimirovich", "Vladimirovna"},
+	{"Vladislav", "Vladislavovich", "Vladislavovna"},
+	{"Yuriy", "Yurievich", "Yurievna"},
+}
+
+var femaleNames = []string{
+	"Alena", "Alina", "Anastasiya", "Anna", "Daria", "Ekaterina", "Elena", "Galina",
+	"Irina", "Kseniya", "Larisa", "Lyudmila", "Mariya", "Marina", "Nadezhda", "Natalia",
+	"Olga", "Polina", "Svetlana", "Sofiya", "Tatiana", "Valentina", "Veronika", "Viktoriya",
+	"Yuliya", "Zoya",
+}
+
+// surname is male form of surname ending with -ov, -ev or -in, female one adds -a
+type surname string
+
+func (s surname) male() string {
+	return string(s)
+}
+
+func (s surname) female() string {
+	return string(s) + "a"
+}
+
+var surnames = []surname{
+	"Alekseev", "Andreev", "Belov", "Bogdanov", "Borisov", "Volkov", "Vorobiev", "Gusev",
+	"Egorov", "Zaitsev", "Ivanov", "Ilyin", "Karpov", "Kiselev", "Kozlov", "Kuznetsov",
+	"Lebedev", "Makarov", "Medvedev", "Mikhaylov", "Morozov", "Nikitin", "Nikolaev", "Novikov",
+	"Orlov", "Pavlov", "Petrov", "Popov", "Romanov", "Semenov", "Smirnov", "Sokolov",
+	"Solovyev", "Stepanov", "Tarasov", "Fedorov", "Frolov", "Zhukov", "Sorokin", "Golubev",
+	"Vinogradov", "Vasiliev", "Yakovlev", "Titov", "Kudryavtsev", "Baranov", "Kulikov", "Gromov",
+}
+
+type mark struct {
+	mark   string
+	models []string
+	share  int
+}
+
+func (m mark) weight() int {
+	return m.share
+}
+
+// marks are weighted by rough share of cars in Russia
+var marks = []mark{
+	{"Lada", []string{"Granta", "Vesta", "Niva", "Largus", "Priora", "Kalina", "XRAY", "2107"}, 28},
+	{"Kia", []string{"Rio", "Sportage", "Ceed", "Optima", "Sorento"}, 9},
+	{"Hyundai", []string{"Solaris", "Creta", "Tucson", "Santa Fe", "Elantra"}, 8},
+	{"Toyota", []string{"Camry", "Corolla", "RAV4", "Land Cruiser", "Prado"}, 8},
+	{"Renault", []string{"Logan", "Duster", "Sandero", "Kaptur"}, 6},
+	{"Volkswagen", []string{"Polo", "Tiguan", "Passat", "Golf"}, 6},
+	{"Skoda", []string{"Octavia", "Rapid", "Kodiaq"}, 4},
+	{"Nissan", []string{"Almera", "Qashqai", "X-Trail"}, 4},
+	{"Chevrolet", []string{"Niva", "Lacetti", "Cruze"}, 3},
+	{"Mitsubishi", []string{"Outlander", "Lancer", "Pajero"}, 3},
+	{"Ford", []string{"Focus", "Mondeo", "Kuga"}, 3},
+	{"GAZ", []string{"Gazelle", "Volga", "Sobol"}, 3},
+	{"UAZ", []string{"Patriot", "Hunter", "Bukhanka"}, 2},
+	{"BMW", []string{"3 Series", "5 Series", "X5"}, 2},
+	{"Mercedes-Benz", []string{"C-Class", "E-Class", "GLE"}, 2},
+	{"Haval", []string{"Jolion", "F7"}, 2},
+	{"Chery", []string{"Tiggo 4", "Tiggo 7 Pro"}, 2},
+	{"Geely", []string{"Coolray", "Atlas"}, 1},
+}
+
+type region struct {
+	region string
+	// Plate region codes
+	codes []string
+	share int
+}
+
+func (r region) weight() int {
+	return r.share
+}
+
+var regions = []region{
+	{"moscow", []string{"77", "97", "99", "177", "197", "199", "777", "797", "799", "977"}, 20},
+	{"moscow-oblast", []string{"50", "90", "150", "190", "750", "550", "250"}, 14},
+	{"saint-petersburg", []string{"78", "98", "178", "198"}, 9},
+	{"leningrad-oblast", []string{"47", "147"}, 3},
+	{"krasnodar", []string{"23", "93", "123", "193"}, 6},
+	{"tatarstan", []string{"16", "116", "716"}, 4},
+	{"sverdlovsk", []string{"66", "96", "196"}, 4},
+	{"novosibirsk", []string{"54", "154"}, 3},
+	{"bashkortostan", []string{"02", "102", "702"}, 3},
+	{"rostov", []string{"61", "161", "761"}, 3},
+	{"samara", []string{"63", "163", "763"}, 3},
+	{"nizhny-novgorod", []string{"52", "152"}, 3},
+	{"chelyabinsk", []string{"74", "174", "774"}, 3},
+	{"primorsky", []string{"25", "125"}, 2},
+	{"kaliningrad", []string{"39", "91"}, 1},
+}
@@ -0,0 +1,193 @@
+// Package seed generates plausible synthetic catalog data. Output depends only on seed
+// and options, so the same data set can be recreated for load tests
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"catalog/internal/storage/entities"
+)
+
+type Options struct {
+	Seed    int64
+	Persons int
+	Cars    int
+	// Cars years are around MinYear + 3/4 of range, older cars are rarer
+	MinYear int
+	MaxYear int
+}
+
+// Validate checks that options can be generated in reasonable time
+func (o *Options) Validate() error {
+	var errs []error
+	// Retries of repeated names become slow closer to the limit
+	if limit := maxPersons() / 2; o.Persons < 0 || o.Persons > limit {
+		errs = append(errs, fmt.Errorf("persons must be in [0, %d]", limit))
+	}
+	if o.Cars < 0 {
+		errs = append(errs, errors.New("cars must not be negative"))
+	}
+	if o.Cars > 0 && o.Persons == 0 {
+		errs = append(errs, errors.New("cars need persons to own them"))
+	}
+	if o.MinYear > o.MaxYear {
+		errs = append(errs, errors.New("min year must not be after max year"))
+	}
+	return errors.Join(errs...)
+}
+
+// maxPersons is count of unique full names
+func maxPersons() int {
+	return (len(maleNames) + len(femaleNames)) * len(surnames) * len(maleNames)
+}
+
+// Generator makes persons and cars owned by them
+type Generator struct {
+	opts   Options
+	r      *rand.Rand
+	plates map[string]bool
+}
+
+func New(opts Options) *Generator {
+	return &Generator{
+		opts:   opts,
+		r:      rand.New(rand.NewSource(opts.Seed)),
+		plates: make(map[string]bool),
+	}
+}
+
+// Persons returns opts.Persons persons with unique full names
+func (g *Generator) Persons() []entities.Person {
+	persons := make([]entities.Person, 0, g.opts.Persons)
+	seen := make(map[entities.Person]bool, g.opts.Persons)
+	for len(persons) < g.opts.Persons {
+		p := g.person()
+		// Full names are unique in tenant, name space is big enough for retries
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		persons = append(persons, p)
+	}
+	return persons
+}
+
+func (g *Generator) person() entities.Person {
+	father := pick(g.r, maleNames)
+	if g.r.Intn(2) == 0 {
+		return entities.Person{
+			Name:       pick(g.r, maleNames).name,
+			Surname:    pick(g.r, surnames).male(),
+			Patronymic: father.son,
+		}
+	}
+	return entities.Person{
+		Name:       pick(g.r, femaleNames),
+		Surname:    pick(g.r, surnames).female(),
+		Patronymic: father.daughter,
+	}
+}
+
+// Cars returns opts.Cars cars, owner is index of person in Persons result.
+// Owners have 1 car mostly, some have several
+func (g *Generator) Cars() ([]entities.Car, []int) {
+	cars := make([]entities.Car, 0, g.opts.Cars)
+	owners := make([]int, 0, g.opts.Cars)
+	for i := 0; i < g.opts.Cars; i++ {
+		cars = append(cars, g.car())
+		owners = append(owners, g.owner())
+	}
+	return cars, owners
+}
+
+// Car returns car with generated owner and region. Plate is unique in Generator
+func (g *Generator) Car() entities.Car {
+	c := g.car()
+	c.Owner = g.person()
+	return c
+}
+
+// car returns car without owner. Cars draws owners from persons, so random sequence of
+// seeded data set doesn't depend on Car
+func (g *Generator) car() entities.Car {
+	m := weighted(g.r, marks)
+	plate, region := g.plate()
+	return entities.Car{
+		RegNum: plate,
+		Mark:   m.mark,
+		Model:  pick(g.r, m.models),
+		Year:   g.year(),
+		Region: region,
+	}
+}
+
+// owner prefers persons with low indexes: first tenth of persons owns about half of cars
+func (g *Generator) owner() int {
+	n := g.opts.Persons
+	if g.r.Intn(2) == 0 {
+		return g.r.Intn(max(n/10, 1))
+	}
+	return g.r.Intn(n)
+}
+
+// year is normally distributed with mean at 3/4 of years range
+func (g *Generator) year() int {
+	span := float64(g.opts.MaxYear - g.opts.MinYear)
+	y := float64(g.opts.MinYear) + span*0.75 + g.r.NormFloat64()*span/5
+	return min(max(int(math.Round(y)), g.opts.MinYear), g.opts.MaxYear)
+}
+
+// plateLetters are Latin letters looking like Cyrillic ones allowed in plates
+const plateLetters = "ABEKMHOPCTYX"
+
+// plate returns unique plate like A123BC77 and car region of plate region code
+func (g *Generator) plate() (string, string) {
+	for {
+		r := weighted(g.r, regions)
+		var b strings.Builder
+		b.WriteByte(plateLetters[g.r.Intn(len(plateLetters))])
+		// 000 is not issued
+		b.WriteString(leftPad(strconv.Itoa(1+g.r.Intn(999)), 3))
+		b.WriteByte(plateLetters[g.r.Intn(len(plateLetters))])
+		b.WriteByte(plateLetters[g.r.Intn(len(plateLetters))])
+		b.WriteString(pick(g.r, r.codes))
+
+		plate := b.String()
+		if !g.plates[plate] {
+			g.plates[plate] = true
+			return plate, r.region
+		}
+	}
+}
+
+func leftPad(s string, n int) string {
+	return strings.Repeat("0", max(n-len(s), 0)) + s
+}
+
+func pick[T any](r *rand.Rand, items []T) T {
+	return items[r.Intn(len(items))]
+}
+
+type weightedItem interface {
+	weight() int
+}
+
+func weighted[T weightedItem](r *rand.Rand, items []T) T {
+	total := 0
+	for _, item := range items {
+		total += item.weight()
+	}
+	n := r.Intn(total)
+	for _, item := range items {
+		if n < item.weight() {
+			return item
+		}
+		n -= item.weight()
+	}
+	return items[len(items)-1]
+}
@@ -0,0 +1,95 @@
+package seed
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+var plateFormat = regexp.MustCompile(`^[ABEKMHOPCTYX]\d{3}[ABEKMHOPCTYX]{2}\d{2,3}$`)
+
+func TestDeterministic(t *testing.T) {
+	opts := Options{Seed: 42, Persons: 200, Cars: 300, MinYear: 1990, MaxYear: 2024}
+
+	g1, g2 := New(opts), New(opts)
+	p1, p2 := g1.Persons(), g2.Persons()
+	c1, o1 := g1.Cars()
+	c2, o2 := g2.Cars()
+	if !reflect.DeepEqual(p1, p2) || !reflect.DeepEqual(c1, c2) || !reflect.DeepEqual(o1, o2) {
+		t.Fatal("the same seed made different data")
+	}
+
+	opts.Seed = 43
+	if p3 := New(opts).Persons(); reflect.DeepEqual(p1, p3) {
+		t.Fatal("other seed made the same persons")
+	}
+}
+
+func TestGeneratedData(t *testing.T) {
+	opts := Options{Seed: 1, Persons: 500, Cars: 2000, MinYear: 1990, MaxYear: 2024}
+	g := New(opts)
+
+	persons := g.Persons()
+	if len(persons) != opts.Persons {
+		t.Fatalf("got %d persons, want %d", len(persons), opts.Persons)
+	}
+	seen := make(map[string]bool)
+	for _, p := range persons {
+		full := p.Surname + " " + p.Name + " " + p.Patronymic
+		if seen[full] {
+			t.Fatalf("person %s is repeated", full)
+		}
+		seen[full] = true
+	}
+
+	cars, owners := g.Cars()
+	if len(cars) != opts.Cars || len(owners) != opts.Cars {
+		t.Fatalf("got %d cars and %d owners, want %d", len(cars), len(owners), opts.Cars)
+	}
+	plates := make(map[string]bool)
+	for i, c := range cars {
+		if !plateFormat.MatchString(c.RegNum) {
+			t.Fatalf("invalid plate %q", c.RegNum)
+		}
+		if plates[c.RegNum] {
+			t.Fatalf("plate %s is repeated", c.RegNum)
+		}
+		plates[c.RegNum] = true
+		if c.Year < opts.MinYear || c.Year > opts.MaxYear {
+			t.Fatalf("year %d is out of range", c.Year)
+		}
+		if c.Mark == "" || c.Model == "" || c.Region == "" {
+			t.Fatalf("car %+v is incomplete", c)
+		}
+		if owners[i] < 0 || owners[i] >= opts.Persons {
+			t.Fatalf("owner %d is out of range", owners[i])
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (&Options{Persons: 10, Cars: 10, MinYear: 2000, MaxYear: 2020}).Validate(); err != nil {
+		t.Fatalf("valid options: %v", err)
+	}
+	if err := (&Options{Cars: 10, MinYear: 2020, MaxYear: 2000}).Validate(); err == nil {
+		t.Fatal("cars without persons and reversed years are valid")
+	}
+}
+
+// Data sets seeded before are recreated only if random sequence doesn't change
+func TestStableCars(t *testing.T) {
+	g := New(Options{Seed: 42, Persons: 200, Cars: 3, MinYear: 1990, MaxYear: 2024})
+	g.Persons()
+	cars, owners := g.Cars()
+
+	var plates []string
+	for _, c := range cars {
+		plates = append(plates, c.RegNum)
+	}
+	if want := []string{"M458PH196", "P748EX190", "A234TO154"}; !reflect.DeepEqual(plates, want) {
+		t.Fatalf("plates = %v, want %v", plates, want)
+	}
+	if want := []int{17, 9, 35}; !reflect.DeepEqual(owners, want) {
+		t.Fatalf("owners = %v, want %v", owners, want)
+	}
+}
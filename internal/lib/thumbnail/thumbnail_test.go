@@ -0,0 +1,58 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func pngImage(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		w, h         int
+		wantW, wantH int
+	}{
+		{800, 400, 200, 100},
+		{300, 600, 100, 200},
+		{120, 80, 120, 80},
+		{1000, 3, 200, 1},
+	}
+	for _, tt := range tests {
+		data, err := Make(pngImage(t, tt.w, tt.h), 200)
+		if err != nil {
+			t.Fatalf("Make %dx%d: %v", tt.w, tt.h, err)
+		}
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("thumbnail of %dx%d is not JPEG: %v", tt.w, tt.h, err)
+		}
+		if cfg.Width != tt.wantW || cfg.Height != tt.wantH {
+			t.Errorf("thumbnail of %dx%d is %dx%d, want %dx%d", tt.w, tt.h, cfg.Width, cfg.Height, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestMakeUnsupported(t *testing.T) {
+	if _, err := Make([]byte("%PDF-1.4"), 200); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("Make of PDF = %v, want ErrUnsupported", err)
+	}
+}
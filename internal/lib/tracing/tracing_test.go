@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	parentTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentHeader  = "00-" + parentTraceID + "-00f067aa0ba902b7-01"
+)
+
+func TestMiddlewarePropagation(t *testing.T) {
+	if _, err := Setup(context.Background(), ExporterNone, "", 1); err != nil {
+		t.Fatalf("failed to setup tracing: %v", err)
+	}
+
+	var traceID string
+	outgoing := http.Header{}
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/catalog", func(w http.ResponseWriter, r *http.Request) {
+		traceID = TraceID(r.Context())
+		Inject(r.Context(), outgoing)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/catalog", nil)
+	r.Header.Set("traceparent", parentHeader)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	if traceID != parentTraceID {
+		t.Fatalf("trace id = %q, want %q of incoming request", traceID, parentTraceID)
+	}
+	got := outgoing.Get("traceparent")
+	if !strings.Contains(got, parentTraceID) || got == parentHeader {
+		t.Fatalf("outgoing traceparent = %q, want child of %q", got, parentHeader)
+	}
+}
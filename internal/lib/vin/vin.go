@@ -0,0 +1,147 @@
+// Package vin validates vehicle identification numbers and decodes them offline:
+// manufacturer is found by world manufacturer identifier (WMI) in embedded table,
+// model year by year code
+package vin
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Length of VIN
+const Length = 17
+
+var (
+	ErrLength     = errors.New("VIN must have 17 characters")
+	ErrCharacter  = errors.New("VIN has invalid character")
+	ErrCheckDigit = errors.New("VIN check digit doesn't match")
+)
+
+// values are transliteration of VIN characters, I, O and Q are not allowed
+var values = map[rune]int{
+	'0': 0, '1': 1, '2': 2, '3': 3, '4': 4, '5': 5, '6': 6, '7': 7, '8': 8, '9': 9,
+	'A': 1, 'B': 2, 'C': 3, 'D': 4, 'E': 5, 'F': 6, 'G': 7, 'H': 8,
+	'J': 1, 'K': 2, 'L': 3, 'M': 4, 'N': 5, 'P': 7, 'R': 9,
+	'S': 2, 'T': 3, 'U': 4, 'V': 5, 'W': 6, 'X': 7, 'Y': 8, 'Z': 9,
+}
+
+// weights of positions in check digit sum, the 9th position is check digit itself
+var weights = [Length]int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+// Normalize returns VIN in upper case without surrounding spaces
+func Normalize(vin string) string {
+	return strings.ToUpper(strings.TrimSpace(vin))
+}
+
+// Validate checks length, characters and check digit of normalized vin
+func Validate(vin string) error {
+	if len(vin) != Length {
+		return ErrLength
+	}
+
+	sum := 0
+	for i, r := range vin {
+		v, ok := values[r]
+		if !ok {
+			return fmt.Errorf("%w %q at position %d", ErrCharacter, r, i+1)
+		}
+		sum += v * weights[i]
+	}
+
+	check := byte('0' + sum%11)
+	if sum%11 == 10 {
+		check = 'X'
+	}
+	if vin[8] != check {
+		return fmt.Errorf("%w: got %c, want %c", ErrCheckDigit, vin[8], check)
+	}
+	return nil
+}
+
+// yearCodes are codes of model years from 1980, they repeat every 30 years
+const yearCodes = "ABCDEFGHJKLMNPRSTVWXY123456789"
+
+// Info is data derived from VIN
+type Info struct {
+	WMI string `json:"wmi"`
+	// Manufacturer and Marks are empty if WMI is not in table
+	Manufacturer string   `json:"manufacturer,omitempty"`
+	Marks        []string `json:"marks,omitempty"`
+	// ModelYear is 0 for invalid year code
+	ModelYear int `json:"modelYear,omitempty"`
+}
+
+// Decode decodes valid vin. Year code repeats every 30 years, the cycle is chosen by
+// the 7th position as in North America: digit means 1980-2009, letter means 2010-2039
+func Decode(vin string) Info {
+	info := Info{WMI: vin[:3]}
+	if m, ok := manufacturers[info.WMI]; ok {
+		info.Manufacturer = m.name
+		info.Marks = m.marks
+	}
+
+	if i := strings.IndexByte(yearCodes, vin[9]); i >= 0 {
+		info.ModelYear = 1980 + i
+		if vin[6] < '0' || vin[6] > '9' {
+			info.ModelYear += 30
+		}
+	}
+	return info
+}
+
+// Fields of car which can disagree with VIN
+const (
+	FieldMark = "mark"
+	FieldYear = "year"
+)
+
+// Mismatches returns fields of car contradicting info. Unknown values are not compared,
+// year matches model year of any 30 years cycle
+func (i Info) Mismatches(mark string, year int) []string {
+	mismatches := []string{}
+	if mark != "" && len(i.Marks) > 0 {
+		matched := false
+		for _, m := range i.Marks {
+			if strings.EqualFold(m, mark) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			mismatches = append(mismatches, FieldMark)
+		}
+	}
+	if year != 0 && i.ModelYear != 0 && (year-i.ModelYear)%30 != 0 {
+		mismatches = append(mismatches, FieldYear)
+	}
+	return mismatches
+}
+
+//go:embed wmi.csv
+var wmiTable []byte
+
+type manufacturer struct {
+	name  string
+	marks []string
+}
+
+// manufacturers by WMI
+var manufacturers = mustParseWMI(wmiTable)
+
+// mustParseWMI parses CSV with header: wmi, manufacturer, marks separated by |
+func mustParseWMI(data []byte) map[string]manufacturer {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		panic("vin: invalid WMI table: " + err.Error())
+	}
+
+	m := make(map[string]manufacturer, len(records))
+	for _, rec := range records[1:] {
+		m[rec[0]] = manufacturer{name: rec[1], marks: strings.Split(rec[2], "|")}
+	}
+	return m
+}
@@ -0,0 +1,73 @@
+package vin
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		vin  string
+		want error
+	}{
+		{"1M8GDM9AXKP042788", nil},
+		{"11111111111111111", nil},
+		{"XTA21099XN1234567", nil},
+		{"WBA3A5C53CF256985", nil},
+		{"1M8GDM9AXKP04278", ErrLength},
+		{"1M8GDM9AXKP0427889", ErrLength},
+		{"1M8GDM9AXKP04278O", ErrCharacter},
+		{"1m8GDM9AXKP042788", ErrCharacter},
+		{"1M8GDM9A1KP042788", ErrCheckDigit},
+		{"WBA3A5C54CF256985", ErrCheckDigit},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.vin); !errors.Is(err, tt.want) {
+			t.Errorf("Validate(%q) = %v, want %v", tt.vin, err, tt.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		vin  string
+		want Info
+	}{
+		{"XTA21099XN1234567", Info{WMI: "XTA", Manufacturer: "AvtoVAZ", Marks: []string{"Lada", "VAZ"}, ModelYear: 1992}},
+		{"WBA3A5C53CF256985", Info{WMI: "WBA", Manufacturer: "BMW", Marks: []string{"BMW"}, ModelYear: 2012}},
+		{"5YJ3E1EA9LF000316", Info{WMI: "5YJ", Manufacturer: "Tesla", Marks: []string{"Tesla"}, ModelYear: 2020}},
+		{"1M8GDM9AXKP042788", Info{WMI: "1M8", ModelYear: 1989}},
+		{"11111111111111111", Info{WMI: "111", ModelYear: 2001}},
+	}
+	for _, tt := range tests {
+		if got := Decode(tt.vin); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Decode(%q) = %+v, want %+v", tt.vin, got, tt.want)
+		}
+	}
+}
+
+func TestMismatches(t *testing.T) {
+	info := Decode("XTA21099XN1234567")
+	tests := []struct {
+		mark string
+		year int
+		want []string
+	}{
+		{"lada", 1992, []string{}},
+		{"VAZ", 2022, []string{}},
+		{"", 0, []string{}},
+		{"Kia", 1992, []string{FieldMark}},
+		{"Lada", 1995, []string{FieldYear}},
+		{"BMW", 2000, []string{FieldMark, FieldYear}},
+	}
+	for _, tt := range tests {
+		if got := info.Mismatches(tt.mark, tt.year); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Mismatches(%q, %d) = %v, want %v", tt.mark, tt.year, got, tt.want)
+		}
+	}
+
+	if got := Decode("11111111111111111").Mismatches("Lada", 2031); len(got) != 0 {
+		t.Errorf("unknown WMI gave mismatches %v", got)
+	}
+}
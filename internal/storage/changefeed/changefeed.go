@@ -0,0 +1,262 @@
+package changefeed
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"catalog/internal/lib/logger/sl"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/entities"
+
+	"github.com/lib/pq"
+)
+
+const (
+	channel = "car_change"
+
+	minReconnectInterval = 1 * time.Second
+	maxReconnectInterval = 30 * time.Second
+	pingInterval         = 60 * time.Second
+	// gapInterval is how often changes behind running transactions are read again
+	gapInterval = 1 * time.Second
+	// catchUpRetryInterval is the same for subscribers catching up, they wait for fewer changes
+	catchUpRetryInterval = 100 * time.Millisecond
+
+	retention      = 7 * 24 * time.Hour
+	pruneInterval  = 1 * time.Hour
+	catchUpBatch   = 500
+	subscriberSize = 64
+)
+
+// Feed listens car_change notifications from PostgreSQL and fans them out to subscribers
+type Feed struct {
+	log      *slog.Logger
+	storage  *postgres.Storage
+	connInfo string
+
+	mu          sync.Mutex
+	subscribers map[chan entities.CarChange]struct{}
+	lastID      int64
+	gaps        entities.ChangeGaps
+}
+
+func New(log *slog.Logger, storage *postgres.Storage, connInfo string) *Feed {
+	return &Feed{
+		log:         log.With(slog.String("op", "storage.changefeed")),
+		storage:     storage,
+		connInfo:    connInfo,
+		subscribers: make(map[chan entities.CarChange]struct{}),
+		gaps:        make(entities.ChangeGaps),
+	}
+}
+
+// Subscribe returns channel with live changes and function to stop receiving them.
+// Slow subscriber is dropped: its channel is closed when buffer is full
+func (f *Feed) Subscribe() (<-chan entities.CarChange, func()) {
+	ch := make(chan entities.CarChange, subscriberSize)
+
+	f.mu.Lock()
+	f.subscribers[ch] = struct{}{}
+	f.mu.Unlock()
+
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subscribers[ch]; ok {
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Run listens notifications until ctx is done
+func (f *Feed) Run(ctx context.Context) {
+	listener := pq.NewListener(f.connInfo, minReconnectInterval, maxReconnectInterval,
+		func(ev pq.ListenerEventType, err error) {
+			if err != nil {
+				f.log.Error("listener event", slog.Int("event", int(ev)), sl.Err(err))
+			}
+		})
+	defer listener.Close()
+
+	if err := listener.Listen(channel); err != nil {
+		f.log.Error("failed to listen channel", sl.Err(err))
+		return
+	}
+
+	// Start from the end of log, older changes are served by subscribers catch up
+	lastID, err := entities.LastCarChangeID(ctx, f.storage)
+	if err != nil {
+		f.log.Error("failed to get last change id", sl.Err(err))
+	}
+	f.lastID = lastID
+	if !f.waitRunning(ctx) {
+		return
+	}
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	gaps := time.NewTicker(gapInterval)
+	defer gaps.Stop()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			f.closeAll()
+			return
+		case n := <-listener.Notify:
+			// nil notification is sent after reconnect, some changes could be missed
+			if n == nil {
+				f.catchUp(ctx)
+				continue
+			}
+			changeID, err := strconv.ParseInt(n.Extra, 10, 64)
+			if err != nil {
+				f.log.Error("invalid notification payload", slog.String("payload", n.Extra))
+				continue
+			}
+			if changeID <= f.lastID {
+				continue
+			}
+			// Read log instead of single change: notifications of concurrent
+			// transactions may arrive out of order
+			f.catchUp(ctx)
+		case <-gaps.C:
+			if len(f.gaps) > 0 {
+				f.catchUp(ctx)
+			}
+		case <-ping.C:
+			if err := listener.Ping(); err != nil {
+				f.log.Error("listener ping failed", sl.Err(err))
+			}
+		case <-prune.C:
+			var ccs entities.CarChanges
+			n, err := ccs.DeleteBefore(ctx, f.storage, time.Now().Add(-retention))
+			if err != nil {
+				f.log.Error("failed to prune change log", sl.Err(err))
+				continue
+			}
+			f.log.Debug("change log pruned", slog.Int64("deleted", n))
+		}
+	}
+}
+
+// waitRunning waits until transactions running now are finished: changes they are making
+// can have ids before the last one and would be skipped otherwise. Returns false if ctx is done
+func (f *Feed) waitRunning(ctx context.Context) bool {
+	var start entities.TxSnapshot
+	if err := start.Get(ctx, f.storage); err != nil {
+		f.log.Error("failed to get transaction snapshot", sl.Err(err))
+		return true
+	}
+
+	ticker := time.NewTicker(gapInterval)
+	defer ticker.Stop()
+
+	for {
+		var now entities.TxSnapshot
+		if err := now.Get(ctx, f.storage); err != nil {
+			f.log.Error("failed to get transaction snapshot", sl.Err(err))
+			return true
+		}
+		if now.Xmin >= start.Xmax {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			f.closeAll()
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// catchUp reads changes after the last published one and publishes them in log order.
+// Changes after ids of running transactions wait for them, see entities.ChangeGaps
+func (f *Feed) catchUp(ctx context.Context) {
+	for {
+		var ccs entities.CarChanges
+		if err := ccs.GetSince(ctx, f.storage, f.lastID, catchUpBatch); err != nil {
+			f.log.Error("failed to read change log", sl.Err(err))
+			return
+		}
+		var snapshot entities.TxSnapshot
+		if err := snapshot.Get(ctx, f.storage); err != nil {
+			f.log.Error("failed to get transaction snapshot", sl.Err(err))
+			return
+		}
+		final := f.gaps.Final(f.lastID, ccs, snapshot)
+		for _, cc := range final {
+			f.publish(cc)
+			f.lastID = cc.ChangeID
+		}
+		if len(final) < len(ccs) || len(ccs) < catchUpBatch {
+			return
+		}
+	}
+}
+
+func (f *Feed) publish(cc entities.CarChange) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for ch := range f.subscribers {
+		select {
+		case ch <- cc:
+		default:
+			f.log.Debug("slow subscriber dropped")
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (f *Feed) closeAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for ch := range f.subscribers {
+		delete(f.subscribers, ch)
+		close(ch)
+	}
+}
+
+// CatchUp reads changes of scope tenant recorded after lastID and passes them to send by
+// batches in log order. Returns id of the last read change, later ones are published by Feed
+func CatchUp(ctx context.Context, storage *postgres.Storage, lastID int64, scope entities.Scope, send func(entities.CarChanges) error) (int64, error) {
+	gaps := make(entities.ChangeGaps)
+	for {
+		var ccs entities.CarChanges
+		if err := ccs.GetSinceInScope(ctx, storage, lastID, catchUpBatch, scope); err != nil {
+			return lastID, err
+		}
+		var snapshot entities.TxSnapshot
+		if err := snapshot.Get(ctx, storage); err != nil {
+			return lastID, err
+		}
+		final := gaps.Final(lastID, ccs, snapshot)
+		if len(final) > 0 {
+			if err := send(final); err != nil {
+				return lastID, err
+			}
+			lastID = final[len(final)-1].ChangeID
+		}
+
+		switch {
+		case len(final) < len(ccs):
+			// The rest waits for transactions which can add changes before it
+			select {
+			case <-ctx.Done():
+				return lastID, ctx.Err()
+			case <-time.After(catchUpRetryInterval):
+			}
+		case len(ccs) < catchUpBatch:
+			return lastID, nil
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package entities
+
+import (
+	"catalog/internal/lib/metrics"
+	postgres "catalog/internal/storage"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	qrNewAPIKey = `INSERT INTO api_key("name", prefix, key_hash, created_by, "role", regions, tenant_id)
+				   VALUES ($1, $2, $3, $4, $5, $6, $7)
+				   RETURNING key_id, created_at;`
+	qrGetAPIKeyByHash = `UPDATE api_key SET last_used_at = now()
+						 WHERE key_hash = $1 AND revoked_at IS NULL
+						 RETURNING key_id, "name", prefix, "role", regions, tenant_id, created_by, created_at,
+						 last_used_at, revoked_at;`
+	qrGetAPIKeys = `SELECT key_id, "name", prefix, "role", regions, tenant_id, created_by, created_at, last_used_at, revoked_at
+					FROM api_key WHERE tenant_id = $1 ORDER BY key_id;`
+	qrGetAPIKey = `SELECT key_id, "name", prefix, "role", regions, tenant_id, created_by, created_at, last_used_at, revoked_at
+				   FROM api_key WHERE key_id = $1;`
+	qrRevokeAPIKey = `UPDATE api_key SET revoked_at = now()
+					  WHERE key_id = $1 AND tenant_id = $2 AND revoked_at IS NULL;`
+)
+
+const (
+	// APIKeyPrefix starts every generated key, so keys are distinguishable from JWT
+	APIKeyPrefix = "ck_"
+
+	apiKeyBytes      = 32
+	apiKeyShownChars = 8
+)
+
+type APIKey struct {
+	KeyID   int      `json:"keyId"`
+	Name    string   `json:"name"`
+	Prefix  string   `json:"prefix"`
+	Role    string   `json:"role"`
+	Regions []string `json:"regions"`
+	// TenantID is empty for platform keys choosing tenant by header
+	TenantID   string     `json:"tenantId,omitempty"`
+	CreatedBy  string     `json:"createdBy"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// HashAPIKey returns hex encoded SHA-256 of key. Keys are random, so plain hash is enough
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateAPIKey() (string, error) {
+	b := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (k *APIKey) scan(row interface{ Scan(...any) error }) error {
+	var lastUsedAt, revokedAt sql.NullTime
+	var regions pq.StringArray
+	var tenantID sql.NullString
+	if err := row.Scan(&k.KeyID, &k.Name, &k.Prefix, &k.Role, &regions, &tenantID, &k.CreatedBy, &k.CreatedAt,
+		&lastUsedAt, &revokedAt); err != nil {
+		return err
+	}
+	k.Regions = regions
+	k.TenantID = tenantID.String
+	k.LastUsedAt, k.RevokedAt = nil, nil
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+	return nil
+}
+
+// New stores key with k.Name, k.Role, k.Regions, k.TenantID and k.CreatedBy and returns its plaintext. Plaintext is not
+// stored anywhere, so it must be shown to caller once
+func (k *APIKey) New(ctx context.Context, storage *postgres.Storage) (string, error) {
+	const op = "storage.entities.APIKey.New"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	key, err := generateAPIKey()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	k.Prefix = key[:len(APIKeyPrefix)+apiKeyShownChars]
+
+	err = storage.QueryRow(ctx, qrNewAPIKey, k.Name, k.Prefix, HashAPIKey(key), k.CreatedBy, k.Role,
+		pq.Array(k.Regions), tenantParam(k.TenantID)).Scan(&k.KeyID, &k.CreatedAt)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// GetByKey finds active key by its plaintext and marks it used
+func (k *APIKey) GetByKey(ctx context.Context, storage *postgres.Storage, key string) error {
+	const op = "storage.entities.APIKey.GetByKey"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+
+	err := k.scan(storage.QueryRow(ctx, qrGetAPIKeyByHash, HashAPIKey(key)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (k *APIKey) Get(ctx context.Context, storage *postgres.Storage, keyID int) error {
+	const op = "storage.entities.APIKey.Get"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := k.scan(storage.QueryRow(ctx, qrGetAPIKey, keyID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Revoke disables key keyID of tenant
+func (k *APIKey) Revoke(ctx context.Context, storage *postgres.Storage, keyID int, tenantID string) error {
+	const op = "storage.entities.APIKey.Revoke"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	res, err := storage.Exec(ctx, qrRevokeAPIKey, keyID, tenantID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+
+	return nil
+}
+
+// Rotate revokes active key keyID and creates new one with the same name, role and regions.
+// k becomes the new key
+func (k *APIKey) Rotate(ctx context.Context, storage *postgres.Storage, keyID int, tenantID, createdBy string) (string, error) {
+	const op = "storage.entities.APIKey.Rotate"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	key, err := generateAPIKey()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := storage.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(qrRevokeAPIKey, keyID, tenantID)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	// Missing and already revoked keys can't be rotated
+	if n == 0 {
+		return "", fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+
+	var old APIKey
+	if err := old.scan(tx.QueryRow(qrGetAPIKey, keyID)); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	k.Name = old.Name
+	k.Role = old.Role
+	k.Regions = old.Regions
+	k.TenantID = old.TenantID
+	k.CreatedBy = createdBy
+	k.Prefix = key[:len(APIKeyPrefix)+apiKeyShownChars]
+	err = tx.QueryRow(qrNewAPIKey, k.Name, k.Prefix, HashAPIKey(key), k.CreatedBy, k.Role,
+		pq.Array(k.Regions), tenantParam(k.TenantID)).Scan(&k.KeyID, &k.CreatedAt)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+type APIKeys []APIKey
+
+// Get returns keys of tenant
+func (ks *APIKeys) Get(ctx context.Context, storage *postgres.Storage, tenantID string) error {
+	const op = "storage.entities.APIKeys.Get"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	qrResult, err := storage.Query(ctx, qrGetAPIKeys, tenantID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer qrResult.Close()
+
+	for qrResult.Next() {
+		var k APIKey
+		if err := k.scan(qrResult); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		*ks = append(*ks, k)
+	}
+	if err := qrResult.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// tenantParam returns NULL for empty tenant of platform key
+func tenantParam(tenantID string) interface{} {
+	if tenantID == "" {
+		return nil
+	}
+	return tenantID
+}
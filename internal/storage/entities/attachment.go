@@ -0,0 +1,219 @@
+package entities
+
+import (
+	"catalog/internal/lib/metrics"
+	postgres "catalog/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// Attachment is added only to existing car of scope
+	qrNewAttachment = `INSERT INTO attachment(car_id, tenant_id, kind, file_name, content_type, "size", blob_key,
+					   thumbnail_key, created_by)
+					   SELECT car_id, tenant_id, $3::TEXT, $4::TEXT, $5::TEXT, $6::BIGINT, $7::TEXT,
+					   NULLIF($8::TEXT, ''), $9::TEXT FROM car
+					   WHERE car_id = $1 AND tenant_id = $2 AND ($10::TEXT[] IS NULL OR region = ANY($10))
+					   RETURNING attachment_id, created_at;`
+	qrCarInScope = `SELECT EXISTS(SELECT 1 FROM car WHERE car_id = $1 AND tenant_id = $2
+					AND ($3::TEXT[] IS NULL OR region = ANY($3)));`
+	qrGetAttachments = `SELECT a.attachment_id, a.car_id, a.kind, a.file_name, a.content_type, a."size", a.blob_key,
+						COALESCE(a.thumbnail_key, ''), a.created_by, a.created_at
+						FROM attachment a JOIN car c ON c.car_id = a.car_id
+						WHERE a.car_id = $1 AND a.tenant_id = $2 AND ($3::TEXT[] IS NULL OR c.region = ANY($3))`
+	qrDeleteAttachment = `DELETE FROM attachment a USING car c
+						  WHERE c.car_id = a.car_id AND a.attachment_id = $1 AND a.car_id = $2 AND a.tenant_id = $3
+						  AND ($4::TEXT[] IS NULL OR c.region = ANY($4));`
+	qrGetBlobGarbage = `SELECT blob_key FROM blob_garbage ORDER BY deleted_at, blob_key LIMIT $1;`
+	qrForgetBlobs    = `DELETE FROM blob_garbage WHERE blob_key = ANY($1);`
+)
+
+// Kinds of attachments
+const (
+	AttachmentPhoto        = "photo"
+	AttachmentRegistration = "registration"
+	AttachmentInspection   = "inspection"
+	AttachmentOther        = "other"
+)
+
+var AttachmentKinds = []string{AttachmentPhoto, AttachmentRegistration, AttachmentInspection, AttachmentOther}
+
+// Attachment is file of car kept in blob storage. Blobs of deleted attachments are
+// queued for removal, see GetBlobGarbage
+type Attachment struct {
+	AttachmentID int    `json:"attachmentId"`
+	CarID        int    `json:"carId"`
+	Kind         string `json:"kind"`
+	FileName     string `json:"fileName"`
+	ContentType  string `json:"contentType"`
+	Size         int64  `json:"size"`
+	// Keys of blobs are internal, ThumbnailKey is empty if attachment has no thumbnail
+	BlobKey      string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	HasThumbnail bool      `json:"hasThumbnail"`
+	CreatedBy    string    `json:"createdBy"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type Attachments []Attachment
+
+// New adds attachment of blobs already put into storage. Missing car or car outside of
+// scope gives ErrNotFound
+func (a *Attachment) New(ctx context.Context, storage *postgres.Storage, scope Scope) error {
+	const op = "storage.entities.Attachment.New"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		err := tx.QueryRow(qrNewAttachment, a.CarID, scope.TenantID, a.Kind, a.FileName, a.ContentType, a.Size,
+			a.BlobKey, a.ThumbnailKey, a.CreatedBy, scope.regions()).Scan(&a.AttachmentID, &a.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.HasThumbnail = a.ThumbnailKey != ""
+	return nil
+}
+
+// Get returns attachments of car, oldest first. Missing car or car outside of scope
+// gives ErrNotFound
+func (as *Attachments) Get(ctx context.Context, storage *postgres.Storage, carID int, scope Scope) error {
+	const op = "storage.entities.Attachments.Get"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(qrCarInScope, carID, scope.TenantID, scope.regions()).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound
+		}
+
+		qrResult, err := tx.Query(qrGetAttachments+` ORDER BY a.attachment_id;`, carID, scope.TenantID, scope.regions())
+		if err != nil {
+			return err
+		}
+		defer qrResult.Close()
+
+		return as.scanAll(qrResult)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Get gets attachment of car
+func (a *Attachment) Get(ctx context.Context, storage *postgres.Storage, carID, attachmentID int, scope Scope) error {
+	const op = "storage.entities.Attachment.Get"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	var as Attachments
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		qrResult, err := tx.Query(qrGetAttachments+` AND a.attachment_id = $4;`,
+			carID, scope.TenantID, scope.regions(), attachmentID)
+		if err != nil {
+			return err
+		}
+		defer qrResult.Close()
+
+		return as.scanAll(qrResult)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(as) == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+
+	*a = as[0]
+	return nil
+}
+
+// Delete deletes attachment of car, its blobs are queued for removal
+func (a *Attachment) Delete(ctx context.Context, storage *postgres.Storage, carID, attachmentID int, scope Scope) error {
+	const op = "storage.entities.Attachment.Delete"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		res, err := tx.Exec(qrDeleteAttachment, attachmentID, carID, scope.TenantID, scope.regions())
+		if err != nil {
+			return err
+		}
+		// Attachments of cars outside of scope look like missing ones
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (as *Attachments) scanAll(rows *sql.Rows) error {
+	for rows.Next() {
+		var a Attachment
+		if err := rows.Scan(&a.AttachmentID, &a.CarID, &a.Kind, &a.FileName, &a.ContentType, &a.Size, &a.BlobKey,
+			&a.ThumbnailKey, &a.CreatedBy, &a.CreatedAt); err != nil {
+			return err
+		}
+		a.HasThumbnail = a.ThumbnailKey != ""
+		*as = append(*as, a)
+	}
+	return rows.Err()
+}
+
+// GetBlobGarbage returns up to limit keys of blobs of deleted attachments, including
+// attachments deleted with their cars. Keys stay queued until ForgetBlobs
+func GetBlobGarbage(ctx context.Context, storage *postgres.Storage, limit int) ([]string, error) {
+	const op = "storage.entities.GetBlobGarbage"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	qrResult, err := storage.Query(ctx, qrGetBlobGarbage, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer qrResult.Close()
+
+	var keys []string
+	for qrResult.Next() {
+		var key string
+		if err := qrResult.Scan(&key); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := qrResult.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return keys, nil
+}
+
+// ForgetBlobs removes keys of blobs deleted from blob storage from queue
+func ForgetBlobs(ctx context.Context, storage *postgres.Storage, keys []string) error {
+	const op = "storage.entities.ForgetBlobs"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	if _, err := storage.Exec(ctx, qrForgetBlobs, pq.Array(keys)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,430 @@
+package entities
+
+import (
+	"catalog/internal/lib/metrics"
+	postgres "catalog/internal/storage"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	qrGetAttributeDefs = `SELECT "name", "type", required, enum_values, pattern, min_value, max_value
+						  FROM attribute_def WHERE tenant_id = $1 ORDER BY "name";`
+	qrSaveAttributeDef = `INSERT INTO attribute_def(tenant_id, "name", "type", required, enum_values, pattern, min_value,
+						  max_value, updated_by)
+						  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+						  ON CONFLICT (tenant_id, "name") DO UPDATE SET "type" = EXCLUDED."type",
+						  required = EXCLUDED.required, enum_values = EXCLUDED.enum_values, pattern = EXCLUDED.pattern,
+						  min_value = EXCLUDED.min_value, max_value = EXCLUDED.max_value,
+						  updated_by = EXCLUDED.updated_by, updated_at = now();`
+	qrDeleteAttributeDef = `DELETE FROM attribute_def WHERE tenant_id = $1 AND "name" = $2;`
+	// Values of deleted attribute would fail validation on the next edit of car
+	qrDropAttribute = `UPDATE car SET attributes = attributes - $2::TEXT
+					   WHERE tenant_id = $1 AND attributes ? $2::TEXT;`
+	qrGetCarAttributes = `SELECT attributes FROM car WHERE car_id = $1 AND tenant_id = $2
+						  AND ($3::TEXT[] IS NULL OR region = ANY($3)) FOR UPDATE;`
+)
+
+// Types of attribute values
+const (
+	AttributeString  = "string"
+	AttributeNumber  = "number"
+	AttributeInteger = "integer"
+	AttributeBoolean = "boolean"
+)
+
+var AttributeTypes = []string{AttributeString, AttributeNumber, AttributeInteger, AttributeBoolean}
+
+var (
+	// ErrInvalidAttributes is returned for attributes of car not matching schema of tenant
+	ErrInvalidAttributes = errors.New("invalid attributes")
+	// ErrInvalidAttributeDef is returned for definition which can't be saved
+	ErrInvalidAttributeDef = errors.New("invalid attribute definition")
+)
+
+var attributeName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// Attributes are custom fields of car, values are strings, float64 numbers and bools
+type Attributes map[string]interface{}
+
+// AttributeDef describes custom attribute of cars. Enum and Pattern, which must match the
+// whole value, are for strings, Min and Max are for numbers
+type AttributeDef struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Enum     []string `json:"enum,omitempty"`
+	Pattern  string   `json:"pattern,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	// pattern is compiled Pattern anchored to the whole value, see matches
+	pattern *regexp.Regexp
+}
+
+// AttributeSchema is definitions of attributes of tenant sorted by name
+type AttributeSchema []AttributeDef
+
+// AttributeOrder sorts catalog by attribute Name, cars without it go last
+type AttributeOrder struct {
+	Name string
+	Desc bool
+}
+
+// ValidAttributeName tells if name can be attribute: lowercase latin letters, digits
+// and underscores starting with letter, at most 50 characters
+func ValidAttributeName(name string) bool {
+	return attributeName.MatchString(name)
+}
+
+// Check validates definition itself
+func (d *AttributeDef) Check() error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidAttributeDef, fmt.Sprintf(format, args...))
+	}
+
+	if !ValidAttributeName(d.Name) {
+		return invalid("name must be lowercase latin letters, digits and underscores, got %q", d.Name)
+	}
+	switch d.Type {
+	case AttributeString:
+		if d.Min != nil || d.Max != nil {
+			return invalid("min and max are for numbers")
+		}
+		if d.Pattern != "" {
+			if _, err := regexp.Compile(d.Pattern); err != nil {
+				return invalid("pattern: %v", err)
+			}
+		}
+	case AttributeNumber, AttributeInteger:
+		if len(d.Enum) > 0 || d.Pattern != "" {
+			return invalid("enum and pattern are for strings")
+		}
+		if d.Min != nil && d.Max != nil && *d.Min > *d.Max {
+			return invalid("min is greater than max")
+		}
+	case AttributeBoolean:
+		if len(d.Enum) > 0 || d.Pattern != "" || d.Min != nil || d.Max != nil {
+			return invalid("boolean has no enum, pattern, min and max")
+		}
+	default:
+		return invalid("type must be one of %v, got %q", AttributeTypes, d.Type)
+	}
+	return nil
+}
+
+// check validates value v of attribute, v is decoded from JSON
+func (d *AttributeDef) check(v interface{}) error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s %s", ErrInvalidAttributes, d.Name, fmt.Sprintf(format, args...))
+	}
+
+	switch d.Type {
+	case AttributeString:
+		s, ok := v.(string)
+		if !ok {
+			return invalid("must be string")
+		}
+		if len(d.Enum) > 0 && !isOneOf(s, d.Enum) {
+			return invalid("must be one of %v", d.Enum)
+		}
+		if d.Pattern != "" {
+			ok, err := d.matches(s)
+			if err != nil {
+				return invalid("has invalid pattern: %v", err)
+			}
+			if !ok {
+				return invalid("must match %s", d.Pattern)
+			}
+		}
+	case AttributeNumber, AttributeInteger:
+		n, ok := v.(float64)
+		if !ok {
+			return invalid("must be number")
+		}
+		if d.Type == AttributeInteger && n != math.Trunc(n) {
+			return invalid("must be integer")
+		}
+		if d.Min != nil && n < *d.Min || d.Max != nil && n > *d.Max {
+			return invalid("is out of range")
+		}
+	case AttributeBoolean:
+		if _, ok := v.(bool); !ok {
+			return invalid("must be boolean")
+		}
+	}
+	return nil
+}
+
+// matches reports whether the whole s matches Pattern. Pattern is compiled on the first
+// call and kept in definition, so loaded schema compiles it once
+func (d *AttributeDef) matches(s string) (bool, error) {
+	if d.pattern == nil {
+		re, err := regexp.Compile(`^(?:` + d.Pattern + `)$`)
+		if err != nil {
+			return false, err
+		}
+		d.pattern = re
+	}
+	return d.pattern.MatchString(s), nil
+}
+
+// Def returns definition of attribute name or nil
+func (s AttributeSchema) Def(name string) *AttributeDef {
+	for i := range s {
+		if s[i].Name == name {
+			return &s[i]
+		}
+	}
+	return nil
+}
+
+// Validate checks attrs against schema: every attribute is defined, has valid value and
+// required ones are set
+func (s AttributeSchema) Validate(attrs Attributes) error {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	// The first problem is reported, so order is fixed
+	sort.Strings(names)
+	for _, name := range names {
+		d := s.Def(name)
+		if d == nil {
+			return fmt.Errorf("%w: %s is not defined", ErrInvalidAttributes, name)
+		}
+		if err := d.check(attrs[name]); err != nil {
+			return err
+		}
+	}
+	for _, d := range s {
+		if _, ok := attrs[d.Name]; d.Required && !ok {
+			return fmt.Errorf("%w: %s is required", ErrInvalidAttributes, d.Name)
+		}
+	}
+	return nil
+}
+
+// ValidateFilter checks attrs filtering catalog: every attribute is defined and its value
+// has type of definition, the rest of definition isn't checked as by ParseValue
+func (s AttributeSchema) ValidateFilter(attrs Attributes) error {
+	for name, v := range attrs {
+		d := s.Def(name)
+		if d == nil {
+			return fmt.Errorf("%w: %s is not defined", ErrInvalidAttributes, name)
+		}
+		var ok bool
+		switch d.Type {
+		case AttributeString:
+			_, ok = v.(string)
+		case AttributeNumber, AttributeInteger:
+			_, ok = v.(float64)
+		case AttributeBoolean:
+			_, ok = v.(bool)
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s must be %s", ErrInvalidAttributes, name, d.Type)
+		}
+	}
+	return nil
+}
+
+// ParseValue converts query parameter raw to value of attribute name
+func (s AttributeSchema) ParseValue(name, raw string) (interface{}, error) {
+	d := s.Def(name)
+	if d == nil {
+		return nil, fmt.Errorf("%w: %s is not defined", ErrInvalidAttributes, name)
+	}
+
+	var v interface{} = raw
+	var err error
+	switch d.Type {
+	case AttributeNumber, AttributeInteger:
+		var n float64
+		n, err = strconv.ParseFloat(raw, 64)
+		// JSON has no NaN and infinities
+		if err == nil && (math.IsNaN(n) || math.IsInf(n, 0)) {
+			err = strconv.ErrSyntax
+		}
+		v = n
+	case AttributeBoolean:
+		v, err = strconv.ParseBool(raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be %s", ErrInvalidAttributes, name, d.Type)
+	}
+	return v, nil
+}
+
+// Get gets schema of tenant
+func (s *AttributeSchema) Get(ctx context.Context, storage *postgres.Storage, tenantID string) error {
+	const op = "storage.entities.AttributeSchema.Get"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := storage.InTenant(ctx, tenantID, func(tx *postgres.Tx) error {
+		return s.get(tx, tenantID)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *AttributeSchema) get(tx *postgres.Tx, tenantID string) error {
+	qrResult, err := tx.Query(qrGetAttributeDefs, tenantID)
+	if err != nil {
+		return err
+	}
+	defer qrResult.Close()
+
+	*s = AttributeSchema{}
+	for qrResult.Next() {
+		var d AttributeDef
+		var enum pq.StringArray
+		var min, max sql.NullFloat64
+		if err := qrResult.Scan(&d.Name, &d.Type, &d.Required, &enum, &d.Pattern, &min, &max); err != nil {
+			return err
+		}
+		d.Enum = enum
+		if min.Valid {
+			d.Min = &min.Float64
+		}
+		if max.Valid {
+			d.Max = &max.Float64
+		}
+		*s = append(*s, d)
+	}
+	return qrResult.Err()
+}
+
+// Save creates or replaces definition of tenant. Values of existing cars are not
+// validated again
+func (d *AttributeDef) Save(ctx context.Context, storage *postgres.Storage, tenantID, updatedBy string) error {
+	const op = "storage.entities.AttributeDef.Save"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	if err := d.Check(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var enum interface{}
+	if len(d.Enum) > 0 {
+		enum = pq.Array(d.Enum)
+	}
+	err := storage.InTenant(ctx, tenantID, func(tx *postgres.Tx) error {
+		_, err := tx.Exec(qrSaveAttributeDef, tenantID, d.Name, d.Type, d.Required, enum, d.Pattern, d.Min, d.Max,
+			updatedBy)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteAttributeDef deletes definition name of tenant with values of the attribute
+func DeleteAttributeDef(ctx context.Context, storage *postgres.Storage, tenantID, name string) error {
+	const op = "storage.entities.DeleteAttributeDef"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := storage.InTenant(ctx, tenantID, func(tx *postgres.Tx) error {
+		res, err := tx.Exec(qrDeleteAttributeDef, tenantID, name)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+
+		_, err = tx.Exec(qrDropAttribute, tenantID, name)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// newAttributes returns JSON of validated attributes of new car
+func newAttributes(tx *postgres.Tx, attrs Attributes, tenantID string) (string, error) {
+	var schema AttributeSchema
+	if err := schema.get(tx, tenantID); err != nil {
+		return "", err
+	}
+	if err := schema.Validate(attrs); err != nil {
+		return "", err
+	}
+	return marshalAttributes(attrs)
+}
+
+// editedAttributes returns JSON of attributes of car carID with changes applied. Change
+// with nil value removes attribute
+func editedAttributes(tx *postgres.Tx, carID int, changes Attributes, scope Scope) (string, error) {
+	var raw []byte
+	err := tx.QueryRow(qrGetCarAttributes, carID, scope.TenantID, scope.regions()).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	attrs := Attributes{}
+	if err := json.Unmarshal(raw, &attrs); err != nil {
+		return "", err
+	}
+	for name, v := range changes {
+		if v == nil {
+			delete(attrs, name)
+		} else {
+			attrs[name] = v
+		}
+	}
+
+	var schema AttributeSchema
+	if err := schema.get(tx, scope.TenantID); err != nil {
+		return "", err
+	}
+	if err := schema.Validate(attrs); err != nil {
+		return "", err
+	}
+	return marshalAttributes(attrs)
+}
+
+// Value makes JSON of attributes for queries, e.g. of JSONB containment
+func (a Attributes) Value() (driver.Value, error) {
+	return marshalAttributes(a)
+}
+
+func marshalAttributes(attrs Attributes) (string, error) {
+	if attrs == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(attrs)
+	return string(data), err
+}
+
+func isOneOf(v string, values []string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
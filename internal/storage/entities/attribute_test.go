@@ -0,0 +1,132 @@
+package entities
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestAttributeDefCheck(t *testing.T) {
+	one, zero := 1.0, 0.0
+	tests := []struct {
+		def  AttributeDef
+		want bool
+	}{
+		{AttributeDef{Name: "color", Type: AttributeString, Enum: []string{"red"}, Pattern: "[a-z]+"}, true},
+		{AttributeDef{Name: "mileage", Type: AttributeInteger, Min: &zero, Max: &one}, true},
+		{AttributeDef{Name: "electric", Type: AttributeBoolean}, true},
+		{AttributeDef{Name: "Color", Type: AttributeString}, false},
+		{AttributeDef{Name: "1st", Type: AttributeString}, false},
+		{AttributeDef{Name: "color", Type: "date"}, false},
+		{AttributeDef{Name: "color", Type: AttributeString, Pattern: "("}, false},
+		{AttributeDef{Name: "color", Type: AttributeString, Min: &zero}, false},
+		{AttributeDef{Name: "mileage", Type: AttributeNumber, Min: &one, Max: &zero}, false},
+		{AttributeDef{Name: "mileage", Type: AttributeNumber, Enum: []string{"1"}}, false},
+		{AttributeDef{Name: "electric", Type: AttributeBoolean, Pattern: "true"}, false},
+	}
+	for _, tt := range tests {
+		err := tt.def.Check()
+		if (err == nil) != tt.want || err != nil && !errors.Is(err, ErrInvalidAttributeDef) {
+			t.Errorf("Check(%+v) = %v, want valid %v", tt.def, err, tt.want)
+		}
+	}
+}
+
+func TestAttributeSchemaValidate(t *testing.T) {
+	zero := 0.0
+	schema := AttributeSchema{
+		{Name: "color", Type: AttributeString, Enum: []string{"red", "blue"}},
+		{Name: "electric", Type: AttributeBoolean},
+		{Name: "mileage", Type: AttributeInteger, Required: true, Min: &zero},
+		{Name: "plate", Type: AttributeString, Pattern: "[A-Z]{2}"},
+	}
+
+	tests := []struct {
+		attrs Attributes
+		want  bool
+	}{
+		{Attributes{"mileage": 100.0}, true},
+		{Attributes{"mileage": 0.0, "color": "red", "electric": true, "plate": "AB"}, true},
+		{Attributes{}, false},
+		{Attributes{"mileage": 1.5}, false},
+		{Attributes{"mileage": -1.0}, false},
+		{Attributes{"mileage": "100"}, false},
+		{Attributes{"mileage": 1.0, "color": "green"}, false},
+		{Attributes{"mileage": 1.0, "electric": "yes"}, false},
+		// Pattern matches the whole value
+		{Attributes{"mileage": 1.0, "plate": "ABC"}, false},
+		{Attributes{"mileage": 1.0, "wheels": 4.0}, false},
+	}
+	for _, tt := range tests {
+		err := schema.Validate(tt.attrs)
+		if (err == nil) != tt.want || err != nil && !errors.Is(err, ErrInvalidAttributes) {
+			t.Errorf("Validate(%v) = %v, want valid %v", tt.attrs, err, tt.want)
+		}
+	}
+	// Pattern is compiled once and kept in schema
+	if schema.Def("plate").pattern == nil {
+		t.Error("pattern is not kept after validation")
+	}
+}
+
+func TestAttributesValue(t *testing.T) {
+	if v, err := (Attributes{"color": "red"}).Value(); err != nil || v != `{"color":"red"}` {
+		t.Errorf("Value() = %v, %v", v, err)
+	}
+	// JSON has no NaN, error goes to query instead of empty filter
+	if _, err := (Attributes{"mileage": math.NaN()}).Value(); err == nil {
+		t.Error("Value() of NaN has no error")
+	}
+}
+
+func TestAttributeSchemaParseValue(t *testing.T) {
+	schema := AttributeSchema{
+		{Name: "color", Type: AttributeString},
+		{Name: "electric", Type: AttributeBoolean},
+		{Name: "mileage", Type: AttributeInteger},
+	}
+
+	tests := []struct {
+		name, raw string
+		want      interface{}
+		wantErr   bool
+	}{
+		{name: "color", raw: "red", want: "red"},
+		{name: "electric", raw: "true", want: true},
+		{name: "mileage", raw: "100", want: 100.0},
+		{name: "mileage", raw: "NaN", wantErr: true},
+		{name: "electric", raw: "maybe", wantErr: true},
+		{name: "wheels", raw: "4", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := schema.ParseValue(tt.name, tt.raw)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseValue(%s, %s) = %v, %v, want %v", tt.name, tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestAttributeSchemaValidateFilter(t *testing.T) {
+	schema := AttributeSchema{
+		{Name: "color", Type: AttributeString, Enum: []string{"red"}},
+		{Name: "electric", Type: AttributeBoolean},
+		{Name: "mileage", Type: AttributeInteger},
+	}
+
+	tests := []struct {
+		attrs   Attributes
+		wantErr bool
+	}{
+		{attrs: Attributes{"color": "blue", "electric": true, "mileage": 100.0}},
+		{attrs: nil},
+		{attrs: Attributes{"mileage": "100"}, wantErr: true},
+		{attrs: Attributes{"electric": nil}, wantErr: true},
+		{attrs: Attributes{"wheels": 4.0}, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := schema.ValidateFilter(tt.attrs)
+		if (err != nil) != tt.wantErr || err != nil && !errors.Is(err, ErrInvalidAttributes) {
+			t.Errorf("ValidateFilter(%v) = %v, want error %v", tt.attrs, err, tt.wantErr)
+		}
+	}
+}
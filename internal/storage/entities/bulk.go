@@ -0,0 +1,110 @@
+package entities
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"catalog/internal/lib/metrics"
+	postgres "catalog/internal/storage"
+
+	"github.com/lib/pq"
+)
+
+// COPY isn't supported for tables with row level security, so rows are copied into
+// temporary tables first and moved by INSERT ... SELECT
+const (
+	qrCreateBulkPerson = `CREATE TEMP TABLE bulk_person(n INT, "name" TEXT, surname TEXT, patronymic TEXT)
+						  ON COMMIT DROP;`
+	qrCreateBulkCar = `CREATE TEMP TABLE bulk_car(reg_num TEXT, mark TEXT, model TEXT, "year" INT,
+					   region TEXT, owner_n INT) ON COMMIT DROP;`
+	qrMoveBulkPersons = `INSERT INTO person("name", surname, patronymic, tenant_id)
+						 SELECT "name", surname, patronymic, $1 FROM bulk_person
+						 ON CONFLICT (tenant_id, "name", surname, patronymic) DO NOTHING;`
+	qrMoveBulkCars = `INSERT INTO car(reg_num, mark, model, "year", "owner", region, tenant_id)
+					  SELECT c.reg_num, c.mark, c.model, c."year", p.person_id, c.region, $1 FROM bulk_car c
+					  JOIN bulk_person bp ON bp.n = c.owner_n
+					  JOIN person p ON p.tenant_id = $1 AND p."name" = bp."name"
+					  AND p.surname = bp.surname AND p.patronymic = bp.patronymic;`
+	// Tables are dropped on commit too, but transaction can be nested as savepoint in tests
+	qrDropBulk = `DROP TABLE bulk_person, bulk_car;`
+)
+
+// BulkInsert adds persons and cars of tenant with COPY. owners[i] is index of owner
+// of cars[i] in persons, Owner fields of cars are ignored. Existing persons are reused.
+// Returns count of inserted cars
+func BulkInsert(ctx context.Context, storage *postgres.Storage, persons []Person, cars []Car, owners []int, tenantID string) (int64, error) {
+	const op = "storage.entities.BulkInsert"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	if len(cars) != len(owners) {
+		return 0, fmt.Errorf("%s: %d cars with %d owners", op, len(cars), len(owners))
+	}
+
+	var inserted int64
+	err := storage.InTenant(ctx, tenantID, func(tx *postgres.Tx) error {
+		if _, err := tx.Exec(qrCreateBulkPerson); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(qrCreateBulkCar); err != nil {
+			return err
+		}
+
+		err := copyRows(tx, pq.CopyIn("bulk_person", "n", "name", "surname", "patronymic"), len(persons),
+			func(i int) []interface{} {
+				p := &persons[i]
+				return []interface{}{i, p.Name, p.Surname, p.Patronymic}
+			})
+		if err != nil {
+			return err
+		}
+		err = copyRows(tx, pq.CopyIn("bulk_car", "reg_num", "mark", "model", "year", "region", "owner_n"), len(cars),
+			func(i int) []interface{} {
+				c := &cars[i]
+				return []interface{}{c.RegNum, c.Mark, c.Model, c.Year, c.Region, owners[i]}
+			})
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(qrMoveBulkPersons, tenantID); err != nil {
+			return err
+		}
+		res, err := tx.Exec(qrMoveBulkCars, tenantID)
+		if err != nil {
+			return err
+		}
+		inserted, err = res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(qrDropBulk)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return inserted, nil
+}
+
+// copyRows runs COPY query with n rows made by row
+func copyRows(tx *postgres.Tx, query string, n int, row func(i int) []interface{}) error {
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i := 0; i < n; i++ {
+		if _, err := stmt.Exec(row(i)...); err != nil {
+			return err
+		}
+	}
+	// Flush buffered rows
+	if _, err := stmt.Exec(); err != nil {
+		return err
+	}
+	return stmt.Close()
+}
@@ -0,0 +1,61 @@
+package entities
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"catalog/internal/storage/storagetest"
+)
+
+func TestBulkInsert(t *testing.T) {
+	storage := storagetest.New(t)
+	ctx := context.Background()
+
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	scope := Scope{TenantID: "test-bulk-" + suffix}
+	persons := []Person{
+		{Name: "Ivan", Surname: "Petrov", Patronymic: "Sergeevich"},
+		{Name: "Anna", Surname: "Ivanova", Patronymic: "Petrovna"},
+	}
+	cars := []Car{
+		{RegNum: "A001AA77", Mark: "Lada", Model: "Vesta", Year: 2020, Region: "moscow"},
+		{RegNum: "B002BB77", Mark: "Kia", Model: "Rio", Year: 2015, Region: "moscow"},
+		{RegNum: "C003CC78", Mark: "Lada", Model: "Granta", Year: 2018, Region: "saint-petersburg"},
+	}
+	owners := []int{0, 1, 1}
+
+	n, err := BulkInsert(ctx, storage, persons, cars, owners, scope.TenantID)
+	if err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+	if n != int64(len(cars)) {
+		t.Fatalf("inserted %d cars, want %d", n, len(cars))
+	}
+
+	var cp CatalogPage
+	err = cp.GetCatalogPage(ctx, storage, &Car{Owner: persons[1]}, 1, scope)
+	if err != nil {
+		t.Fatalf("failed to get cars of owner: %v", err)
+	}
+	if cp.Pagination.TotalPage == 0 || len(cp.Cars) == 0 || cp.Cars[0].Owner.Surname != persons[1].Surname {
+		t.Fatalf("cars of owner = %+v", cp)
+	}
+
+	// All cars of filter are read at once, in order of catalog
+	var regNums []string
+	err = EachCar(ctx, storage, &Car{Mark: "Lada"}, scope, func(c *Car) error {
+		regNums = append(regNums, c.RegNum)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(regNums, []string{"C003CC78", "A001AA77"}) {
+		t.Fatalf("EachCar = %v, %v", regNums, err)
+	}
+
+	// Persons are reused by the next insert
+	if _, err := BulkInsert(ctx, storage, persons[:1], cars[:1], owners[:1], scope.TenantID); err != nil {
+		t.Fatalf("failed to insert for existing person: %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package entities
+
+import (
+	"catalog/internal/lib/metrics"
+	postgres "catalog/internal/storage"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	qrGetCarChange       = `SELECT change_id, op, car, changed_at, tenant_id FROM car_change WHERE change_id = $1;`
+	qrGetCarChangesSince = `SELECT change_id, op, car, changed_at, tenant_id FROM car_change WHERE change_id > $1
+						    ORDER BY change_id LIMIT $2;`
+	qrGetTenantCarChangesSince = `SELECT change_id, op, car, changed_at, tenant_id FROM car_change
+								  WHERE change_id > $1 AND tenant_id = $3
+								  ORDER BY change_id LIMIT $2;`
+	qrDeleteCarChanges   = `DELETE FROM car_change WHERE changed_at < $1;`
+	qrGetLastCarChangeID = `SELECT COALESCE(max(change_id), 0) FROM car_change;`
+	qrGetTxSnapshot      = `SELECT pg_snapshot_xmin(s)::text::bigint, pg_snapshot_xmax(s)::text::bigint
+							FROM pg_current_snapshot() s;`
+)
+
+// Operations recorded in car change log
+const (
+	OpInsert = "insert"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+type CarChange struct {
+	ChangeID  int64     `json:"changeId"`
+	Op        string    `json:"op"`
+	Car       Car       `json:"car"`
+	ChangedAt time.Time `json:"changedAt"`
+	TenantID  string    `json:"-"`
+}
+
+func (cc *CarChange) scan(row interface{ Scan(...any) error }) error {
+	var rawCar []byte
+	if err := row.Scan(&cc.ChangeID, &cc.Op, &rawCar, &cc.ChangedAt, &cc.TenantID); err != nil {
+		return err
+	}
+	return json.Unmarshal(rawCar, &cc.Car)
+}
+
+func (cc *CarChange) Get(ctx context.Context, storage *postgres.Storage, changeID int64) error {
+	const op = "storage.entities.CarChange.Get"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := storage.AsPlatform(ctx, func(tx *postgres.Tx) error {
+		return cc.scan(tx.QueryRow(qrGetCarChange, changeID))
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// LastCarChangeID returns id of the latest recorded change or 0 for empty log
+func LastCarChangeID(ctx context.Context, storage *postgres.Storage) (int64, error) {
+	const op = "storage.entities.LastCarChangeID"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	var changeID int64
+	err := storage.AsPlatform(ctx, func(tx *postgres.Tx) error {
+		return tx.QueryRow(qrGetLastCarChangeID).Scan(&changeID)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return changeID, nil
+}
+
+// TxSnapshot bounds transactions running at some moment: ones before Xmin are finished
+// and ones from Xmax on are not started yet
+type TxSnapshot struct {
+	Xmin int64
+	Xmax int64
+}
+
+func (s *TxSnapshot) Get(ctx context.Context, storage *postgres.Storage) error {
+	const op = "storage.entities.TxSnapshot.Get"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	if err := storage.QueryRow(ctx, qrGetTxSnapshot).Scan(&s.Xmin, &s.Xmax); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ChangeGaps remembers changes read after missing ids of change log. Ids are taken from
+// sequence, but transactions commit in any order, so missing id can belong to transaction
+// still running. It was started before the change after it was read, so the gap is final
+// when all transactions running at that moment are finished. Values are Xmax of snapshots
+// taken right after changes were read for the first time
+type ChangeGaps map[int64]int64
+
+// Final returns changes of ccs that can be sent after lastID: the longest prefix without
+// gaps that are not final yet. ccs are sorted by id and snapshot is taken after reading them
+func (g ChangeGaps) Final(lastID int64, ccs CarChanges, snapshot TxSnapshot) CarChanges {
+	for i, cc := range ccs {
+		bound, ok := g[cc.ChangeID]
+		if !ok {
+			bound = snapshot.Xmax
+			g[cc.ChangeID] = bound
+		}
+		if cc.ChangeID != lastID+1 && snapshot.Xmin < bound {
+			return ccs[:i]
+		}
+		delete(g, cc.ChangeID)
+		lastID = cc.ChangeID
+	}
+	return ccs
+}
+
+type CarChanges []CarChange
+
+// GetSince returns up to limit changes of all tenants recorded after changeID in log order
+func (ccs *CarChanges) GetSince(ctx context.Context, storage *postgres.Storage, changeID int64, limit int) error {
+	const op = "storage.entities.CarChanges.GetSince"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := storage.AsPlatform(ctx, func(tx *postgres.Tx) error {
+		qrResult, err := tx.Query(qrGetCarChangesSince, changeID, limit)
+		if err != nil {
+			return err
+		}
+		defer qrResult.Close()
+
+		return ccs.scanAll(qrResult)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetSinceInScope is GetSince limited by scope tenant. Regions are not checked
+func (ccs *CarChanges) GetSinceInScope(ctx context.Context, storage *postgres.Storage, changeID int64, limit int, scope Scope) error {
+	const op = "storage.entities.CarChanges.GetSinceInScope"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		qrResult, err := tx.Query(qrGetTenantCarChangesSince, changeID, limit, scope.TenantID)
+		if err != nil {
+			return err
+		}
+		defer qrResult.Close()
+
+		return ccs.scanAll(qrResult)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (ccs *CarChanges) scanAll(rows *sql.Rows) error {
+	for rows.Next() {
+		var cc CarChange
+		if err := cc.scan(rows); err != nil {
+			return err
+		}
+		*ccs = append(*ccs, cc)
+	}
+	return rows.Err()
+}
+
+// DeleteBefore drops changes recorded before t, returns count of deleted records
+func (ccs *CarChanges) DeleteBefore(ctx context.Context, storage *postgres.Storage, t time.Time) (int64, error) {
+	const op = "storage.entities.CarChanges.DeleteBefore"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	var n int64
+	err := storage.AsPlatform(ctx, func(tx *postgres.Tx) error {
+		res, err := tx.Exec(qrDeleteCarChanges, t)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return n, nil
+}
+
+// Matches reports whether car fits filter f. Empty fields of f match everything,
+// same as in carCond, but empty status matches active cars as catalog does
+func (c *Car) Matches(f *Car) bool {
+	f = f.orActive()
+	var emptyCar Car
+	if f.CarID != emptyCar.CarID && c.CarID != f.CarID {
+		return false
+	}
+	if f.RegNum != emptyCar.RegNum && c.RegNum != f.RegNum {
+		return false
+	}
+	if f.Mark != emptyCar.Mark && c.Mark != f.Mark {
+		return false
+	}
+	if f.Model != emptyCar.Model && c.Model != f.Model {
+		return false
+	}
+	if f.Year != emptyCar.Year && c.Year != f.Year {
+		return false
+	}
+	if f.Region != emptyCar.Region && c.Region != f.Region {
+		return false
+	}
+	if f.VIN != emptyCar.VIN && c.VIN != f.VIN {
+		return false
+	}
+	if f.Status != emptyCar.Status && f.Status != StatusAny && c.Status != f.Status {
+		return false
+	}
+	for name, v := range f.Attributes {
+		if c.Attributes[name] != v {
+			return false
+		}
+	}
+	o, fo := &c.Owner, &f.Owner
+	if fo.PersonID != 0 && o.PersonID != fo.PersonID || fo.Name != "" && o.Name != fo.Name ||
+		fo.Surname != "" && o.Surname != fo.Surname || fo.Patronymic != "" && o.Patronymic != fo.Patronymic {
+		return false
+	}
+	return true
+}
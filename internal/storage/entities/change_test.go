@@ -0,0 +1,58 @@
+package entities
+
+import "testing"
+
+func TestChangeGapsFinal(t *testing.T) {
+	changes := func(ids ...int64) CarChanges {
+		ccs := make(CarChanges, 0, len(ids))
+		for _, id := range ids {
+			ccs = append(ccs, CarChange{ChangeID: id})
+		}
+		return ccs
+	}
+	gaps := make(ChangeGaps)
+
+	// Id 3 is taken by transaction running while 4 and 5 are read
+	final := gaps.Final(1, changes(2, 4, 5), TxSnapshot{Xmin: 100, Xmax: 110})
+	if len(final) != 1 || final[0].ChangeID != 2 {
+		t.Fatalf("final = %+v, want change 2", final)
+	}
+
+	// Transactions running at the first read are not finished yet
+	if final := gaps.Final(2, changes(4, 5, 6), TxSnapshot{Xmin: 105, Xmax: 120}); len(final) != 0 {
+		t.Fatalf("final = %+v, want none", final)
+	}
+
+	// 3 is committed, so 4-6 follow it without gaps, 8 is read for the first time
+	final = gaps.Final(2, changes(3, 4, 5, 6, 8), TxSnapshot{Xmin: 110, Xmax: 125})
+	if len(final) != 4 || final[3].ChangeID != 6 {
+		t.Fatalf("final = %+v, want changes 3-6", final)
+	}
+	if _, ok := gaps[8]; !ok || len(gaps) != 1 {
+		t.Fatalf("gaps = %v, want only 8", gaps)
+	}
+
+	// 7 is rolled back: gap is final when its bound is passed
+	if final := gaps.Final(6, changes(8), TxSnapshot{Xmin: 125, Xmax: 130}); len(final) != 1 || len(gaps) != 0 {
+		t.Fatalf("final = %+v, gaps = %v, want change 8 without gaps", final, gaps)
+	}
+}
+
+func TestCarMatchesStatus(t *testing.T) {
+	sold := Car{Mark: "Lada", Status: StatusSold}
+	tests := []struct {
+		filter Car
+		want   bool
+	}{
+		// Stream shows the same cars as catalog with the same filter
+		{Car{Mark: "Lada"}, false},
+		{Car{Mark: "Lada", Status: StatusSold}, true},
+		{Car{Mark: "Lada", Status: StatusAny}, true},
+		{Car{Mark: "Kia", Status: StatusAny}, false},
+	}
+	for _, tt := range tests {
+		if got := sold.Matches(&tt.filter); got != tt.want {
+			t.Errorf("Matches(%+v) = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
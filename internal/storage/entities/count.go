@@ -0,0 +1,65 @@
+package entities
+
+import (
+	"catalog/internal/lib/metrics"
+	postgres "catalog/internal/storage"
+	"context"
+	"fmt"
+	"time"
+)
+
+// Counts are of active cars, the ones catalog shows by default
+const (
+	qrCountCarsByTenant   = `SELECT tenant_id, count(car_id) FROM car WHERE status = 'active' GROUP BY tenant_id;`
+	qrCountOwnersByTenant = `SELECT tenant_id, count(DISTINCT "owner") FROM car WHERE status = 'active'
+							 GROUP BY tenant_id;`
+)
+
+// CountCarsByTenant returns active cars count of every tenant
+func CountCarsByTenant(ctx context.Context, storage *postgres.Storage) (map[string]int, error) {
+	const op = "storage.entities.CountCarsByTenant"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	counts, err := countByTenant(ctx, storage, qrCountCarsByTenant)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return counts, nil
+}
+
+// CountOwnersByTenant returns count of persons owning active cars of every tenant
+func CountOwnersByTenant(ctx context.Context, storage *postgres.Storage) (map[string]int, error) {
+	const op = "storage.entities.CountOwnersByTenant"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	counts, err := countByTenant(ctx, storage, qrCountOwnersByTenant)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return counts, nil
+}
+
+func countByTenant(ctx context.Context, storage *postgres.Storage, query string) (map[string]int, error) {
+	counts := make(map[string]int)
+	err := storage.AsPlatform(ctx, func(tx *postgres.Tx) error {
+		qrResult, err := tx.Query(query)
+		if err != nil {
+			return err
+		}
+		defer qrResult.Close()
+
+		for qrResult.Next() {
+			var tenantID string
+			var n int
+			if err := qrResult.Scan(&tenantID, &n); err != nil {
+				return err
+			}
+			counts[tenantID] = n
+		}
+		return qrResult.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -1,310 +1,545 @@
-package entities
-
-import (
-	postgres "catalog/internal/storage"
-	"fmt"
-	"strconv"
-
-	"github.com/go-playground/validator/v10"
-)
-
-const (
-	qrNewCar       = `INSERT INTO car(reg_num, mark, model, year, owner) VALUES ($1, $2, $3, $4, $5);`
-	qrDelete       = `DELETE FROM car WHERE car_id = $1;`
-	qrGetCarsCount = `SELECT count("car_id") FROM car;`
-	qrGetPersonID  = `SELECT person_id FROM person WHERE "name" = $1 AND surname = $2 AND patronymic = $3;`
-	qrGetPerson    = `SELECT "name", surname, patronymic FROM person WHERE person_id = $1;`
-	qrNewPerson    = `INSERT INTO person("name", surname, patronymic) VALUES ($1, $2, $3)
-				   	  ON CONFLICT ("name", surname, patronymic) DO NOTHING;`
-)
-
-type Person struct {
-	PersonID   int    `json:"personId,omitempty"`
-	Name       string `json:"name,omitempty"`
-	Surname    string `json:"surname,omitempty"`
-	Patronymic string `json:"patronymic,omitempty"`
-}
-
-type Car struct {
-	CarID  int    `json:"carId,omitempty" validate:"required"`
-	RegNum string `json:"regNum,omitempty"`
-	Mark   string `json:"mark,omitempty"`
-	Model  string `json:"model,omitempty"`
-	Year   int    `json:"year,omitempty"`
-	Owner  Person `json:"owner,omitempty"`
-}
-
-func (c *Car) Delete(storage *postgres.Storage, carID int) error {
-	const op = "storage.entities.Delete"
-
-	_, err := storage.DB.Exec(qrDelete, carID)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-	return nil
-}
-
-func (c *Car) Edit(storage *postgres.Storage) error {
-	const op = "storage.entities.Edit"
-
-	qrEdit := "UPDATE car SET "
-	qrParameters := []interface{}{}
-
-	var emptyCar Car
-	i := 0
-	if c.RegNum != emptyCar.RegNum {
-		i++
-		qrEdit += " reg_num = $" + strconv.Itoa(i) + " "
-		qrParameters = append(qrParameters, c.RegNum)
-	}
-	if c.Mark != emptyCar.Mark {
-		if i != 0 {
-			qrEdit += ", "
-		}
-		i++
-		qrEdit += " mark = $" + strconv.Itoa(i) + " "
-		qrParameters = append(qrParameters, c.Mark)
-	}
-	if c.Model != emptyCar.Model {
-		if i != 0 {
-			qrEdit += ", "
-		}
-		i++
-		qrEdit += " model = $" + strconv.Itoa(i) + " "
-		qrParameters = append(qrParameters, c.Model)
-	}
-	if c.Year != emptyCar.Year {
-		if i != 0 {
-			qrEdit += ", "
-		}
-		i++
-		qrEdit += ` "year" = $` + strconv.Itoa(i) + " "
-		qrParameters = append(qrParameters, c.Year)
-	}
-	if c.Owner != emptyCar.Owner {
-		// Validate request JSON
-		if err := validator.New().Struct(c.Owner); err != nil {
-			return fmt.Errorf("%s: %w", op, err)
-		}
-
-		_, err := storage.DB.Exec(qrNewPerson, c.Owner.Name, c.Owner.Surname, c.Owner.Patronymic)
-		if err != nil {
-			return fmt.Errorf("%s: %w", op, err)
-		}
-
-		var personID int
-		err = storage.DB.QueryRow(qrGetPersonID, c.Owner.Name, c.Owner.Surname, c.Owner.Patronymic).Scan(&personID)
-		if err != nil {
-			return fmt.Errorf("%s: %w", op, err)
-		}
-
-		if i != 0 {
-			qrEdit += ", "
-		}
-		qrEdit += ` "owner" = ` + strconv.Itoa(personID) + " "
-	}
-	qrEdit += ` WHERE car_id = ` + strconv.Itoa(c.CarID) + ";"
-
-	_, err := storage.DB.Exec(qrEdit, qrParameters...)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-
-	return nil
-}
-
-type Cars []Car
-
-type CatalogPage struct {
-	Cars
-	Pagination Pagination
-}
-
-func (cp *CatalogPage) GetCatalogPage(storage *postgres.Storage, c *Car, page int) error {
-	const op = "storage.entities.GetCatalogPage"
-
-	qrGetCars := "SELECT * FROM car"
-	qrParameters := []interface{}{}
-	var recordsCount int
-	i := 0 // i - query parameters counter
-
-	var emptyCar Car
-	if *c != emptyCar {
-		qrGetCars += " WHERE "
-		if c.CarID != emptyCar.CarID {
-			qrGetCars += " car_id = $" + strconv.Itoa(i) + " "
-			qrParameters = append(qrParameters, c.CarID)
-		}
-		if c.RegNum != emptyCar.RegNum {
-			if i != 0 {
-				qrGetCars += " AND "
-			}
-			i++
-			qrGetCars += " reg_num = $" + strconv.Itoa(i) + " "
-			qrParameters = append(qrParameters, c.RegNum)
-		}
-		if c.Mark != emptyCar.Mark {
-			if i != 0 {
-				qrGetCars += " AND "
-			}
-			i++
-			qrGetCars += " mark = $" + strconv.Itoa(i) + " "
-			qrParameters = append(qrParameters, c.Mark)
-		}
-		if c.Model != emptyCar.Model {
-			if i != 0 {
-				qrGetCars += " AND "
-			}
-			i++
-			qrGetCars += " model = $" + strconv.Itoa(i) + " "
-			qrParameters = append(qrParameters, c.Model)
-		}
-		if c.Year != emptyCar.Year {
-			if i != 0 {
-				qrGetCars += " AND "
-			}
-			i++
-			qrGetCars += ` "year" = $` + strconv.Itoa(i) + " "
-			qrParameters = append(qrParameters, c.Year)
-		}
-		if c.Owner != emptyCar.Owner {
-			// Validate request JSON
-			if err := validator.New().Struct(c.Owner); err != nil {
-				return fmt.Errorf("%s: %w", op, err)
-			}
-
-			_, err := storage.DB.Exec(qrNewPerson, c.Owner.Name, c.Owner.Surname, c.Owner.Patronymic)
-			if err != nil {
-				return fmt.Errorf("%s: %w", op, err)
-			}
-
-			var personID int
-			err = storage.DB.QueryRow(qrGetPersonID, c.Owner.Name, c.Owner.Surname, c.Owner.Patronymic).Scan(&personID)
-			if err != nil {
-				return fmt.Errorf("%s: %w", op, err)
-			}
-
-			if i != 0 {
-				qrGetCars += " AND "
-			}
-			qrGetCars += ` "owner" = ` + strconv.Itoa(personID) + " "
-		}
-
-		// Get filtered records count
-		if err := storage.DB.QueryRow(("SELECT count(car_id) FROM car WHERE " + qrGetCars[24:] + ";"), qrParameters...).Scan(&recordsCount); err != nil {
-			return fmt.Errorf("%s: %w", op, err)
-		}
-	}
-	// Count all records it there was not filter
-	if i == 0 {
-		// Get all records count
-		if err := storage.DB.QueryRow(`SELECT count(car_id) FROM car;`).Scan(&recordsCount); err != nil {
-			return fmt.Errorf("%s: %w", op, err)
-		}
-	}
-	// Make pagination
-	if page < 0 {
-		return fmt.Errorf("%s: page in out of range", op)
-	}
-	if page == 0 {
-		page = 1
-	}
-	limit := 2
-	offset := limit * (page - 1)
-	if err := cp.Pagination.NewPagination(recordsCount, limit, page); err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-	i++
-	qrGetCars += ` order by car_id desc limit $` + strconv.Itoa(i) + " "
-	qrParameters = append(qrParameters, limit)
-	i++
-	qrGetCars += " offset $" + strconv.Itoa(i)
-	qrParameters = append(qrParameters, offset)
-	qrGetCars += ";"
-
-	qrResult, err := storage.DB.Query(qrGetCars, qrParameters...)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-	defer qrResult.Close()
-
-	for qrResult.Next() {
-		var c Car
-		var ownerID int
-		if err := qrResult.Scan(&c.CarID, &c.RegNum, &c.Mark, &c.Model, &c.Year, &ownerID); err != nil {
-			return fmt.Errorf("%s: %w", op, err)
-		}
-		if err := storage.DB.QueryRow(qrGetPerson, ownerID).Scan(&c.Owner.Name, &c.Owner.Surname, &c.Owner.Patronymic); err != nil {
-			return fmt.Errorf("%s: %w", op, err)
-		}
-		cp.Cars = append(cp.Cars, c)
-	}
-
-	return nil
-}
-
-func (c *Car) New(storage *postgres.Storage) error {
-	const op = "storage.entities.New"
-
-	_, err := storage.DB.Exec(qrNewPerson, c.Owner.Name, c.Owner.Surname, c.Owner.Patronymic)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-
-	var personID int
-	err = storage.DB.QueryRow(qrGetPersonID, c.Owner.Name, c.Owner.Surname, c.Owner.Patronymic).Scan(&personID)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-
-	_, err = storage.DB.Exec(qrNewCar, c.RegNum, c.Mark, c.Model, c.Year, personID)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-
-	return nil
-}
-
-type Pagination struct {
-	Next          int
-	Previous      int
-	RecordPerPage int
-	CurrentPage   int
-	TotalPage     int
-}
-
-// Generated Pagination Meta data
-func (p *Pagination) NewPagination(recordsCount, limit, page int) error {
-	const op = "storage.entities.NewPagination"
-
-	total := (recordsCount / limit)
-
-	// Calculator Total Page
-	remainder := (recordsCount % limit)
-	if remainder == 0 {
-		p.TotalPage = total
-	} else {
-		p.TotalPage = total + 1
-	}
-
-	if page > p.TotalPage {
-		return fmt.Errorf("%s: page in out of range", op)
-	}
-
-	// Set current/record per page meta data
-	p.CurrentPage = page
-	p.RecordPerPage = limit
-
-	// Calculator the Next/Previous Page
-	if page <= 0 {
-		p.Next = page + 1
-	} else if page < p.TotalPage {
-		p.Previous = page - 1
-		p.Next = page + 1
-	} else if page == p.TotalPage {
-		p.Previous = page - 1
-		p.Next = 0
-	}
-
-	return nil
-}
+package entities
+
+import (
+	"catalog/internal/lib/metrics"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/query"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/lib/pq"
+)
+
+const (
+	qrNewCar = `INSERT INTO car(reg_num, mark, model, year, owner, region, tenant_id, vin, attributes)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9::JSONB);`
+	qrDelete = `DELETE FROM car WHERE car_id = $1 AND tenant_id = $2
+				AND ($3::TEXT[] IS NULL OR region = ANY($3));`
+	qrGetCarsCount = `SELECT count("car_id") FROM car WHERE tenant_id = $1;`
+	qrGetPersonID  = `SELECT person_id FROM person WHERE "name" = $1 AND surname = $2 AND patronymic = $3
+					  AND tenant_id = $4;`
+	// Year is optional in archive, VIN is optional everywhere
+	qrGetCars = `SELECT c.car_id, c.reg_num, c.mark, c.model, COALESCE(c."year", 0), c.region, COALESCE(c.vin, ''),
+				 c.status, c.attributes, p.person_id, p."name", p.surname, p.patronymic
+				 FROM car c JOIN person p ON p.person_id = c."owner"`
+	qrCountCars = `SELECT count(c.car_id) FROM car c JOIN person p ON p.person_id = c."owner"`
+	qrNewPerson = `INSERT INTO person("name", surname, patronymic, tenant_id) VALUES ($1, $2, $3, $4)
+				   ON CONFLICT (tenant_id, "name", surname, patronymic) DO NOTHING;`
+)
+
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrOutOfScope = errors.New("out of scope")
+
+	ErrPageOutOfRange = errors.New("page in out of range")
+	// ErrNothingToEdit is returned by Edit when no field of car is set
+	ErrNothingToEdit = errors.New("nothing to edit")
+	// ErrVINExists is returned by New and Edit when tenant already has car with the VIN
+	ErrVINExists = errors.New("car with this VIN already exists")
+)
+
+// Scope limits cars visible and editable by caller
+type Scope struct {
+	// TenantID is required, every query is limited by it
+	TenantID string
+	// Regions allowed for caller. Nil means all regions
+	Regions []string
+}
+
+func (s *Scope) Restricted() bool {
+	return s.Regions != nil
+}
+
+func (s *Scope) Allows(region string) bool {
+	if !s.Restricted() {
+		return true
+	}
+	for _, r := range s.Regions {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers reports whether all of regions are allowed. Nil regions mean all regions, so they
+// are covered by unrestricted scope only
+func (s *Scope) Covers(regions []string) bool {
+	if !s.Restricted() {
+		return true
+	}
+	if regions == nil {
+		return false
+	}
+	for _, r := range regions {
+		if !s.Allows(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// regions returns scope regions as query parameter, NULL for unrestricted scope
+func (s *Scope) regions() interface{} {
+	return pq.Array(s.Regions)
+}
+
+// Person and Car fields tagged by db are columns used by filters and edits
+type Person struct {
+	PersonID   int    `json:"personId,omitempty" db:"person_id"`
+	Name       string `json:"name,omitempty" db:"name"`
+	Surname    string `json:"surname,omitempty" db:"surname"`
+	Patronymic string `json:"patronymic,omitempty" db:"patronymic"`
+}
+
+type Car struct {
+	CarID  int    `json:"carId,omitempty" validate:"required" db:"car_id"`
+	RegNum string `json:"regNum,omitempty" db:"reg_num"`
+	Mark   string `json:"mark,omitempty" db:"mark"`
+	Model  string `json:"model,omitempty" db:"model"`
+	Year   int    `json:"year,omitempty" db:"year"`
+	Region string `json:"region,omitempty" db:"region"`
+	VIN    string `json:"vin,omitempty" db:"vin"`
+	// Status is one of CarStatuses, it is changed only by StatusChange
+	Status string `json:"status,omitempty" db:"status"`
+	// Attributes are custom fields defined by AttributeSchema of tenant. Filter matches
+	// cars having all of them
+	Attributes Attributes `json:"attributes,omitempty"`
+	Owner      Person     `json:"owner,omitempty"`
+	// ServiceDue and Tags limit filter to cars due for service and cars with tags,
+	// Order sorts catalog by attribute. They aren't fields of car
+	ServiceDue *ServiceIntervals `json:"-"`
+	Tags       *TagFilter        `json:"-"`
+	Order      *AttributeOrder   `json:"-"`
+}
+
+func (c *Car) Delete(ctx context.Context, storage *postgres.Storage, carID int, scope Scope) error {
+	const op = "storage.entities.Delete"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		res, err := tx.Exec(qrDelete, carID, scope.TenantID, scope.regions())
+		if err != nil {
+			return err
+		}
+		// Cars outside of scope look like missing ones
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ownerID returns id of person p in tenant, person is created if needed
+func ownerID(tx *postgres.Tx, p *Person, tenantID string) (int, error) {
+	_, err := tx.Exec(qrNewPerson, p.Name, p.Surname, p.Patronymic, tenantID)
+	if err != nil {
+		return 0, err
+	}
+
+	var personID int
+	err = tx.QueryRow(qrGetPersonID, p.Name, p.Surname, p.Patronymic, tenantID).Scan(&personID)
+	if err != nil {
+		return 0, err
+	}
+	return personID, nil
+}
+
+func (c *Car) Edit(ctx context.Context, storage *postgres.Storage, scope Scope) error {
+	const op = "storage.entities.Edit"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	if c.Region != "" && !scope.Allows(c.Region) {
+		return fmt.Errorf("%s: %w", op, ErrOutOfScope)
+	}
+
+	// Car is found by id, the rest of set fields are changed
+	set := *c
+	set.CarID = 0
+	set.Status = ""
+	fields := query.Fields(&set)
+
+	var emptyCar Car
+	if len(fields) == 0 && c.Owner == emptyCar.Owner && c.Attributes == nil {
+		return fmt.Errorf("%s: %w", op, ErrNothingToEdit)
+	}
+	if c.Owner != emptyCar.Owner {
+		// Validate request JSON
+		if err := validator.New().Struct(c.Owner); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		if c.Owner != emptyCar.Owner {
+			personID, err := ownerID(tx, &c.Owner, scope.TenantID)
+			if err != nil {
+				return err
+			}
+			fields = append(fields, query.Field{Column: "owner", Value: personID})
+		}
+		if c.Attributes != nil {
+			attrs, err := editedAttributes(tx, c.CarID, c.Attributes, scope)
+			if err != nil {
+				return err
+			}
+			fields = append(fields, query.Field{Column: "attributes", Value: attrs})
+		}
+
+		q, err := query.New("UPDATE car").Set(fields)
+		if err != nil {
+			return err
+		}
+		cond := (&query.Cond{}).Eq("", "car_id", c.CarID).Eq("", "tenant_id", scope.TenantID)
+		if scope.Restricted() {
+			cond.Add("region = ANY(?)", scope.regions())
+		}
+		q.Where(cond)
+
+		res, err := tx.Exec(q.String(), q.Args()...)
+		if err != nil {
+			return vinErr(err)
+		}
+		// Cars outside of scope look like missing ones
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetByVIN gets car of scope with VIN vin
+func (c *Car) GetByVIN(ctx context.Context, storage *postgres.Storage, vin string, scope Scope) error {
+	const op = "storage.entities.GetByVIN"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	q := query.New(qrGetCars).Where(carCond(&Car{VIN: vin}, scope))
+
+	var cs Cars
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		qrResult, err := tx.Query(q.String(), q.Args()...)
+		if err != nil {
+			return err
+		}
+		defer qrResult.Close()
+
+		return cs.scanAll(qrResult)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	// Cars outside of scope look like missing ones
+	if len(cs) == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+
+	*c = cs[0]
+	return nil
+}
+
+// vinErr replaces violation of unique VIN index with ErrVINExists
+func vinErr(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "car_vin_idx" {
+		return ErrVINExists
+	}
+	return err
+}
+
+type Cars []Car
+
+type CatalogPage struct {
+	Cars
+	Pagination Pagination
+	// Facets are counted only if requested
+	Facets Facets `json:",omitempty"`
+}
+
+// GetCatalogPage gets page of cars matching filter c. Values of facets are counted under
+// the same filter without faceted field, see FacetFields
+func (cp *CatalogPage) GetCatalogPage(ctx context.Context, storage *postgres.Storage, c *Car, page int, scope Scope, facets ...string) error {
+	const op = "storage.entities.GetCatalogPage"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	c = c.orActive()
+	cond := carCond(c, scope)
+
+	// Make pagination
+	if page < 0 {
+		return fmt.Errorf("%s: %w", op, ErrPageOutOfRange)
+	}
+	if page == 0 {
+		page = 1
+	}
+	limit := 2
+	offset := limit * (page - 1)
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		// Get filtered records count
+		var recordsCount int
+		qc := query.New(qrCountCars).Where(cond)
+		if err := tx.QueryRow(qc.String(), qc.Args()...).Scan(&recordsCount); err != nil {
+			return err
+		}
+		if err := cp.Pagination.NewPagination(recordsCount, limit, page); err != nil {
+			return err
+		}
+
+		if len(facets) > 0 {
+			if err := cp.Facets.get(tx, c, facets, scope); err != nil {
+				return err
+			}
+		}
+
+		q := query.New(qrGetCars).Where(cond)
+		orderCars(q, c.Order)
+		q.Limit(limit, offset)
+		qrResult, err := tx.Query(q.String(), q.Args()...)
+		if err != nil {
+			return err
+		}
+		defer qrResult.Close()
+
+		return cp.Cars.scanAll(qrResult)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// EachCar calls fn for every car matching filter c in order of catalog. Cars are read
+// by one query in one transaction, so they are consistent unlike pages of catalog
+func EachCar(ctx context.Context, storage *postgres.Storage, c *Car, scope Scope, fn func(c *Car) error) error {
+	const op = "storage.entities.EachCar"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	c = c.orActive()
+	q := query.New(qrGetCars).Where(carCond(c, scope))
+	orderCars(q, c.Order)
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		qrResult, err := tx.Query(q.String(), q.Args()...)
+		if err != nil {
+			return err
+		}
+		defer qrResult.Close()
+
+		for qrResult.Next() {
+			car, err := scanCar(qrResult)
+			if err != nil {
+				return err
+			}
+			if err := fn(&car); err != nil {
+				return err
+			}
+		}
+		return qrResult.Err()
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// orderCars orders q of qrGetCars by attribute o or newest first without o
+func orderCars(q *query.Query, o *AttributeOrder) {
+	if o == nil {
+		q.OrderBy("c", "car_id", true)
+		return
+	}
+	dir := " ASC"
+	if o.Desc {
+		dir = " DESC"
+	}
+	q.Write(" ORDER BY c.attributes -> ?"+dir+" NULLS LAST, c.car_id DESC", o.Name)
+}
+
+// GetByOwners returns cars of scope owned by persons personIDs, newest first
+func (cs *Cars) GetByOwners(ctx context.Context, storage *postgres.Storage, personIDs []int, scope Scope) error {
+	const op = "storage.entities.Cars.GetByOwners"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	cond := carCond(&Car{}, scope).Add(`c."owner" = ANY(?)`, pq.Array(personIDs))
+	q := query.New(qrGetCars).Where(cond).OrderBy("c", "car_id", true)
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		qrResult, err := tx.Query(q.String(), q.Args()...)
+		if err != nil {
+			return err
+		}
+		defer qrResult.Close()
+
+		return cs.scanAll(qrResult)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// scanAll appends cars of qrGetCars result
+func (cs *Cars) scanAll(rows *sql.Rows) error {
+	for rows.Next() {
+		c, err := scanCar(rows)
+		if err != nil {
+			return err
+		}
+		*cs = append(*cs, c)
+	}
+	return rows.Err()
+}
+
+// scanCar scans current row of qrGetCars result
+func scanCar(rows *sql.Rows) (Car, error) {
+	var c Car
+	var attrs []byte
+	o := &c.Owner
+	if err := rows.Scan(&c.CarID, &c.RegNum, &c.Mark, &c.Model, &c.Year, &c.Region, &c.VIN,
+		&c.Status, &attrs, &o.PersonID, &o.Name, &o.Surname, &o.Patronymic); err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(attrs, &c.Attributes); err != nil {
+		return c, err
+	}
+	if len(c.Attributes) == 0 {
+		c.Attributes = nil
+	}
+	return c, nil
+}
+
+// orActive returns filter c of active cars unless other status is asked. Catalog and
+// stats show cars in use by default
+func (c *Car) orActive() *Car {
+	if c.Status != "" {
+		return c
+	}
+	active := *c
+	active.Status = StatusActive
+	return &active
+}
+
+// carCond returns conditions of cars of scope matching non-zero fields of filter c and its owner.
+// Car table is aliased as c and person table as p
+func carCond(c *Car, scope Scope) *query.Cond {
+	cond := (&query.Cond{}).Eq("c", "tenant_id", scope.TenantID)
+	f := *c
+	if f.Status == StatusAny {
+		f.Status = ""
+	}
+	cond.Fields("c", query.Fields(&f))
+	cond.Fields("p", query.Fields(&c.Owner))
+	if c.ServiceDue != nil {
+		addServiceDue(cond, c.ServiceDue)
+	}
+	if c.Tags != nil {
+		addTagCond(cond, c.Tags)
+	}
+	if len(c.Attributes) > 0 {
+		cond.Add("c.attributes @> ?::JSONB", c.Attributes)
+	}
+	// Hide cars outside of caller scope
+	if scope.Restricted() {
+		cond.Add("c.region = ANY(?)", scope.regions())
+	}
+	return cond
+}
+
+func (c *Car) New(ctx context.Context, storage *postgres.Storage, scope Scope) error {
+	const op = "storage.entities.New"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	if !scope.Allows(c.Region) {
+		return fmt.Errorf("%s: %w", op, ErrOutOfScope)
+	}
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		personID, err := ownerID(tx, &c.Owner, scope.TenantID)
+		if err != nil {
+			return err
+		}
+
+		attrs, err := newAttributes(tx, c.Attributes, scope.TenantID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(qrNewCar, c.RegNum, c.Mark, c.Model, c.Year, personID, c.Region, scope.TenantID, c.VIN, attrs)
+		return vinErr(err)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+type Pagination struct {
+	Next          int
+	Previous      int
+	RecordPerPage int
+	CurrentPage   int
+	TotalPage     int
+}
+
+// Generated Pagination Meta data
+func (p *Pagination) NewPagination(recordsCount, limit, page int) error {
+	const op = "storage.entities.NewPagination"
+
+	total := (recordsCount / limit)
+
+	// Calculator Total Page
+	remainder := (recordsCount % limit)
+	if remainder == 0 {
+		p.TotalPage = total
+	} else {
+		p.TotalPage = total + 1
+	}
+
+	// The first page of empty result exists
+	if page > max(p.TotalPage, 1) {
+		return fmt.Errorf("%s: %w", op, ErrPageOutOfRange)
+	}
+
+	// Set current/record per page meta data
+	p.CurrentPage = page
+	p.RecordPerPage = limit
+
+	// Calculator the Next/Previous Page
+	if page <= 0 {
+		p.Next = page + 1
+	} else if page < p.TotalPage {
+		p.Previous = page - 1
+		p.Next = page + 1
+	} else if page == p.TotalPage {
+		p.Previous = page - 1
+		p.Next = 0
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package entities
+
+import (
+	"catalog/internal/storage/query"
+	"errors"
+	"fmt"
+
+	postgres "catalog/internal/storage"
+)
+
+const qrFacetValues = `SELECT ?::TEXT AS facet, %s::TEXT AS "value", count(c.car_id) AS n
+					   FROM car c JOIN person p ON p.person_id = c."owner"`
+
+// ErrUnknownFacet is returned for facet not listed in FacetFields
+var ErrUnknownFacet = errors.New("unknown facet")
+
+// FacetFields are car fields catalog can be faceted by
+var FacetFields = []string{"mark", "model", "year", "region"}
+
+// FacetValue is count of cars with value of faceted field
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets are values of faceted fields, the most frequent first
+type Facets map[string][]FacetValue
+
+// ValidateFacets returns ErrUnknownFacet if any of facets is not in FacetFields
+func ValidateFacets(facets []string) error {
+	for _, f := range facets {
+		if _, ok := facetFilter(&Car{}, f); !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownFacet, f)
+		}
+	}
+	return nil
+}
+
+// facetFilter returns filter c without faceted field, so counts show what filter
+// would give with another value of the field
+func facetFilter(c *Car, facet string) (Car, bool) {
+	f := *c
+	switch facet {
+	case "mark":
+		f.Mark = ""
+	case "model":
+		f.Model = ""
+	case "year":
+		f.Year = 0
+	case "region":
+		f.Region = ""
+	default:
+		return f, false
+	}
+	return f, true
+}
+
+// get counts values of facets under filter c in one query
+func (fs *Facets) get(tx *postgres.Tx, c *Car, facets []string, scope Scope) error {
+	q := query.New("")
+	for i, facet := range facets {
+		f, ok := facetFilter(c, facet)
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownFacet, facet)
+		}
+		column := query.Column("c", facet)
+
+		if i > 0 {
+			q.Write(" UNION ALL ")
+		}
+		q.Write(fmt.Sprintf(qrFacetValues, column), facet)
+		q.Where(carCond(&f, scope).Add(column + " IS NOT NULL"))
+		q.Write(" GROUP BY 1, 2")
+	}
+	q.Write(" ORDER BY facet, n DESC, \"value\"")
+
+	rows, err := tx.Query(q.String(), q.Args()...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	*fs = make(Facets, len(facets))
+	// Facets without values are present too
+	for _, facet := range facets {
+		(*fs)[facet] = []FacetValue{}
+	}
+	for rows.Next() {
+		var facet string
+		var v FacetValue
+		if err := rows.Scan(&facet, &v.Value, &v.Count); err != nil {
+			return err
+		}
+		(*fs)[facet] = append((*fs)[facet], v)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,12 @@
+package entities
+
+import (
+	"os"
+	"testing"
+
+	"catalog/internal/storage/storagetest"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(storagetest.Main(m))
+}
@@ -0,0 +1,115 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"catalog/internal/lib/metrics"
+	postgres "catalog/internal/storage"
+
+	"github.com/lib/pq"
+)
+
+const (
+	qrGetOwners = `SELECT p.person_id, p."name", p.surname, p.patronymic, count(c.car_id) FROM person p
+				   LEFT JOIN car c ON c."owner" = p.person_id AND c.tenant_id = p.tenant_id
+				   WHERE p.tenant_id = $1 AND p."name" ILIKE $2 AND p.surname ILIKE $3 AND p.patronymic ILIKE $4
+				   GROUP BY p.person_id ORDER BY p.surname, p."name", p.patronymic, p.person_id;`
+	qrCountPersons = `SELECT count(person_id) FROM person WHERE person_id = ANY($1) AND tenant_id = $2;`
+	qrMoveCars     = `UPDATE car SET "owner" = $1 WHERE "owner" = ANY($2) AND tenant_id = $3;`
+	qrDeletePerson = `DELETE FROM person WHERE person_id = ANY($1) AND tenant_id = $2;`
+)
+
+var (
+	likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+	ErrInvalidMerge = errors.New("person is repeated in merge")
+)
+
+// Owner is person with count of owned cars
+type Owner struct {
+	Person
+	Cars int `json:"cars"`
+}
+
+type Owners []Owner
+
+// Search returns persons of tenant whose names contain p fields, case is ignored.
+// Empty fields match everything
+func (ows *Owners) Search(ctx context.Context, storage *postgres.Storage, p *Person, tenantID string) error {
+	const op = "storage.entities.Owners.Search"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := storage.InTenant(ctx, tenantID, func(tx *postgres.Tx) error {
+		qrResult, err := tx.Query(qrGetOwners, tenantID, contains(p.Name), contains(p.Surname), contains(p.Patronymic))
+		if err != nil {
+			return err
+		}
+		defer qrResult.Close()
+
+		for qrResult.Next() {
+			var o Owner
+			if err := qrResult.Scan(&o.PersonID, &o.Name, &o.Surname, &o.Patronymic, &o.Cars); err != nil {
+				return err
+			}
+			*ows = append(*ows, o)
+		}
+		return qrResult.Err()
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// contains makes ILIKE pattern of substring s
+func contains(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
+// MergePersons moves cars of persons fromIDs to person intoID and deletes them,
+// e.g. duplicates differing in case. Returns count of moved cars
+func MergePersons(ctx context.Context, storage *postgres.Storage, intoID int, fromIDs []int, tenantID string) (int64, error) {
+	const op = "storage.entities.MergePersons"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	ids := append([]int{intoID}, fromIDs...)
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return 0, fmt.Errorf("%s: %w: %d", op, ErrInvalidMerge, id)
+		}
+		seen[id] = true
+	}
+
+	var moved int64
+	err := storage.InTenant(ctx, tenantID, func(tx *postgres.Tx) error {
+		var n int
+		if err := tx.QueryRow(qrCountPersons, pq.Array(ids), tenantID).Scan(&n); err != nil {
+			return err
+		}
+		if n != len(ids) {
+			return ErrNotFound
+		}
+
+		res, err := tx.Exec(qrMoveCars, intoID, pq.Array(fromIDs), tenantID)
+		if err != nil {
+			return err
+		}
+		if moved, err = res.RowsAffected(); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(qrDeletePerson, pq.Array(fromIDs), tenantID)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return moved, nil
+}
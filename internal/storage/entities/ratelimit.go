@@ -0,0 +1,52 @@
+package entities
+
+import (
+	"catalog/internal/lib/metrics"
+	postgres "catalog/internal/storage"
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// All SET expressions see bucket state before update
+	qrTakeRateLimitToken = `INSERT INTO rate_limit_bucket AS b (bucket_key, tokens, allowed, updated_at)
+							VALUES ($1, $2::FLOAT8 - 1, true, clock_timestamp())
+							ON CONFLICT (bucket_key) DO UPDATE SET
+							tokens = CASE
+								WHEN LEAST($2::FLOAT8, b.tokens + EXTRACT(EPOCH FROM clock_timestamp() - b.updated_at) * $3::FLOAT8) >= 1
+								THEN LEAST($2::FLOAT8, b.tokens + EXTRACT(EPOCH FROM clock_timestamp() - b.updated_at) * $3::FLOAT8) - 1
+								ELSE LEAST($2::FLOAT8, b.tokens + EXTRACT(EPOCH FROM clock_timestamp() - b.updated_at) * $3::FLOAT8)
+							END,
+							allowed = LEAST($2::FLOAT8, b.tokens + EXTRACT(EPOCH FROM clock_timestamp() - b.updated_at) * $3::FLOAT8) >= 1,
+							updated_at = clock_timestamp()
+							RETURNING tokens, allowed;`
+	qrDeleteRateLimitBuckets = `DELETE FROM rate_limit_bucket WHERE updated_at < $1;`
+)
+
+// TakeRateLimitToken takes token from bucket key refilled with rate tokens per second up to burst.
+// Returns tokens left and whether token was taken
+func TakeRateLimitToken(ctx context.Context, storage *postgres.Storage, key string, rate float64, burst int) (float64, bool, error) {
+	const op = "storage.entities.TakeRateLimitToken"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	var tokens float64
+	var allowed bool
+	if err := storage.QueryRow(ctx, qrTakeRateLimitToken, key, burst, rate).Scan(&tokens, &allowed); err != nil {
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, allowed, nil
+}
+
+// DeleteRateLimitBuckets drops buckets not used since t
+func DeleteRateLimitBuckets(ctx context.Context, storage *postgres.Storage, t time.Time) error {
+	const op = "storage.entities.DeleteRateLimitBuckets"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	if _, err := storage.Exec(ctx, qrDeleteRateLimitBuckets, t); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
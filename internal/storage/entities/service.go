@@ -0,0 +1,217 @@
+package entities
+
+import (
+	"catalog/internal/lib/metrics"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/query"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// Record is added only to existing car of scope
+	qrNewServiceRecord = `INSERT INTO service_record(car_id, tenant_id, service_date, odometer, work_type, cost, notes,
+						  created_by)
+						  SELECT car_id, tenant_id, $3::DATE, $4::INT, $5::TEXT, $6::NUMERIC, $7::TEXT, $8::TEXT FROM car
+						  WHERE car_id = $1 AND tenant_id = $2 AND ($9::TEXT[] IS NULL OR region = ANY($9))
+						  RETURNING record_id, created_at;`
+	qrGetServiceRecords = `SELECT s.record_id, s.car_id, to_char(s.service_date, 'YYYY-MM-DD'), s.odometer, s.work_type,
+						   s.cost::FLOAT8, s.notes, s.created_by, s.created_at
+						   FROM service_record s JOIN car c ON c.car_id = s.car_id
+						   WHERE s.car_id = $1 AND s.tenant_id = $2 AND ($3::TEXT[] IS NULL OR c.region = ANY($3))`
+	qrEditServiceRecord = `UPDATE service_record s SET service_date = $5, odometer = $6, work_type = $7, cost = $8, notes = $9
+						   FROM car c
+						   WHERE c.car_id = s.car_id AND s.record_id = $1 AND s.car_id = $2 AND s.tenant_id = $3
+						   AND ($4::TEXT[] IS NULL OR c.region = ANY($4))
+						   RETURNING s.created_by, s.created_at;`
+	qrDeleteServiceRecord = `DELETE FROM service_record s USING car c
+							 WHERE c.car_id = s.car_id AND s.record_id = $1 AND s.car_id = $2 AND s.tenant_id = $3
+							 AND ($4::TEXT[] IS NULL OR c.region = ANY($4));`
+)
+
+// ServiceRecord is maintenance of car. Date is in YYYY-MM-DD format, odometer is in km
+type ServiceRecord struct {
+	RecordID  int       `json:"recordId"`
+	CarID     int       `json:"carId"`
+	Date      string    `json:"date"`
+	Odometer  int       `json:"odometer"`
+	WorkType  string    `json:"workType"`
+	Cost      float64   `json:"cost"`
+	Notes     string    `json:"notes,omitempty"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ServiceRecords []ServiceRecord
+
+// ServiceIntervals tell when car is due for service: Time passed since the last service
+// or Distance driven since it. Zero interval isn't checked
+type ServiceIntervals struct {
+	Distance int
+	Time     time.Duration
+}
+
+// New adds record of car. Missing car or car outside of scope gives ErrNotFound
+func (s *ServiceRecord) New(ctx context.Context, storage *postgres.Storage, scope Scope) error {
+	const op = "storage.entities.ServiceRecord.New"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		err := tx.QueryRow(qrNewServiceRecord, s.CarID, scope.TenantID, s.Date, s.Odometer, s.WorkType, s.Cost, s.Notes,
+			s.CreatedBy, scope.regions()).Scan(&s.RecordID, &s.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Get returns records of car, the latest service first. Missing car or car outside
+// of scope gives ErrNotFound
+func (ss *ServiceRecords) Get(ctx context.Context, storage *postgres.Storage, carID int, scope Scope) error {
+	const op = "storage.entities.ServiceRecords.Get"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(qrCarInScope, carID, scope.TenantID, scope.regions()).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound
+		}
+
+		qrResult, err := tx.Query(qrGetServiceRecords+` ORDER BY s.service_date DESC, s.record_id DESC;`,
+			carID, scope.TenantID, scope.regions())
+		if err != nil {
+			return err
+		}
+		defer qrResult.Close()
+
+		return ss.scanAll(qrResult)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Get gets record of car
+func (s *ServiceRecord) Get(ctx context.Context, storage *postgres.Storage, carID, recordID int, scope Scope) error {
+	const op = "storage.entities.ServiceRecord.Get"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	var ss ServiceRecords
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		qrResult, err := tx.Query(qrGetServiceRecords+` AND s.record_id = $4;`, carID, scope.TenantID, scope.regions(), recordID)
+		if err != nil {
+			return err
+		}
+		defer qrResult.Close()
+
+		return ss.scanAll(qrResult)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(ss) == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNotFound)
+	}
+
+	*s = ss[0]
+	return nil
+}
+
+// Edit replaces date, odometer, work type, cost and notes of record s.RecordID of car s.CarID
+func (s *ServiceRecord) Edit(ctx context.Context, storage *postgres.Storage, scope Scope) error {
+	const op = "storage.entities.ServiceRecord.Edit"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		err := tx.QueryRow(qrEditServiceRecord, s.RecordID, s.CarID, scope.TenantID, scope.regions(),
+			s.Date, s.Odometer, s.WorkType, s.Cost, s.Notes).Scan(&s.CreatedBy, &s.CreatedAt)
+		// Records of cars outside of scope look like missing ones
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Delete deletes record of car
+func (s *ServiceRecord) Delete(ctx context.Context, storage *postgres.Storage, carID, recordID int, scope Scope) error {
+	const op = "storage.entities.ServiceRecord.Delete"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		res, err := tx.Exec(qrDeleteServiceRecord, recordID, carID, scope.TenantID, scope.regions())
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (ss *ServiceRecords) scanAll(rows *sql.Rows) error {
+	for rows.Next() {
+		var s ServiceRecord
+		if err := rows.Scan(&s.RecordID, &s.CarID, &s.Date, &s.Odometer, &s.WorkType, &s.Cost, &s.Notes,
+			&s.CreatedBy, &s.CreatedAt); err != nil {
+			return err
+		}
+		*ss = append(*ss, s)
+	}
+	return rows.Err()
+}
+
+// addServiceDue adds condition of cars due for service to cond. Car without records is
+// due. Distance since the last service is estimated by average daily distance between
+// the first and the last services, so car with services of one day is due by time only
+func addServiceDue(cond *query.Cond, si *ServiceIntervals) {
+	var notDue []string
+	var args []interface{}
+	if si.Time > 0 {
+		notDue = append(notDue, `max(s.service_date) + make_interval(secs => ?) > now()`)
+		args = append(args, si.Time.Seconds())
+	}
+	if si.Distance > 0 {
+		notDue = append(notDue, `COALESCE((max(s.odometer) - min(s.odometer))::FLOAT8
+			/ NULLIF(max(s.service_date) - min(s.service_date), 0)
+			* (current_date - max(s.service_date)) < ?, true)`)
+		args = append(args, si.Distance)
+	}
+	if len(notDue) == 0 {
+		return
+	}
+
+	// Aggregate of car without records is single row of NULLs, HAVING drops it
+	cond.Add(`NOT EXISTS (SELECT 1 FROM service_record s WHERE s.car_id = c.car_id HAVING `+
+		strings.Join(notDue, " AND ")+`)`, args...)
+}
@@ -0,0 +1,432 @@
+package entities
+
+import (
+	"catalog/internal/lib/metrics"
+	"catalog/internal/storage/query"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	postgres "catalog/internal/storage"
+)
+
+const (
+	qrStatsAge = `GREATEST(EXTRACT(YEAR FROM now())::INT - NULLIF(c."year", 0), 0)`
+	// Only one instance refreshes views at a time, the rest skip refresh
+	qrLockStatsRefresh = `SELECT pg_try_advisory_xact_lock(hashtext('car_stats'));`
+	qrRefreshCarStats  = `REFRESH MATERIALIZED VIEW CONCURRENTLY car_stats;`
+	qrSetStatsRefresh  = `UPDATE stats_refresh SET refreshed_at = now() WHERE view_name = 'car_stats';`
+)
+
+// ErrUnknownStatsField is returned for grouping field not listed in FacetFields
+var ErrUnknownStatsField = errors.New("unknown stats field")
+
+// Intervals of growth stats
+const (
+	IntervalMonth = "month"
+	IntervalYear  = "year"
+)
+
+// statsSource is table stats are computed from. Car table is aliased as c and person table as p
+type statsSource struct {
+	from string
+	// cars counts cars of group
+	cars string
+	// month is creation month of cars
+	month string
+	// asOf is time data is actual for
+	asOf string
+}
+
+var (
+	liveStats = statsSource{
+		from:  ` FROM car c JOIN person p ON p.person_id = c."owner"`,
+		cars:  `count(c.car_id)::INT`,
+		month: `date_trunc('month', c.created_at)`,
+		asOf:  `SELECT now();`,
+	}
+	snapshotStats = statsSource{
+		from:  ` FROM car_stats c JOIN person p ON p.person_id = c."owner"`,
+		cars:  `COALESCE(sum(c.cars), 0)::INT`,
+		month: `c.created_month`,
+		asOf:  `SELECT refreshed_at FROM stats_refresh WHERE view_name = 'car_stats';`,
+	}
+)
+
+// sourceOf returns snapshot if it is allowed and can apply filter c. Snapshot has no car
+// ids, registration numbers, VINs, services, tags and attributes
+func sourceOf(c *Car, snapshot bool) statsSource {
+	if snapshot && c.CarID == 0 && c.RegNum == "" && c.VIN == "" && c.ServiceDue == nil && c.Tags == nil &&
+		len(c.Attributes) == 0 {
+		return snapshotStats
+	}
+	return liveStats
+}
+
+// StatsGroup is value of grouping fields, e.g. {"mark": "Lada"}. Unknown year is empty string
+type StatsGroup map[string]string
+
+// groupColumns returns expressions of grouping fields
+func groupColumns(by []string) ([]string, error) {
+	columns := make([]string, 0, len(by))
+	for _, field := range by {
+		switch field {
+		case "mark", "model", "region":
+			columns = append(columns, query.Column("c", field))
+		case "year":
+			columns = append(columns, `COALESCE(NULLIF(c."year", 0)::TEXT, '')`)
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrUnknownStatsField, field)
+		}
+	}
+	return columns, nil
+}
+
+// ValidateStatsFields returns ErrUnknownStatsField if any of fields can't group stats
+func ValidateStatsFields(fields []string) error {
+	_, err := groupColumns(fields)
+	return err
+}
+
+// statsQuery starts query selecting grouping columns and extra expressions of cars matching cond.
+// The first len(by) columns of result are group values
+func statsQuery(src statsSource, cond *query.Cond, by []string, extra ...string) (*query.Query, error) {
+	columns, err := groupColumns(by)
+	if err != nil {
+		return nil, err
+	}
+	q := query.New("SELECT ")
+	for i, col := range append(columns, extra...) {
+		if i > 0 {
+			q.Write(", ")
+		}
+		q.Write(col)
+	}
+	q.Write(src.from).Where(cond)
+	return q, nil
+}
+
+// groupBy appends GROUP BY clause of n leading columns and returns q
+func groupBy(q *query.Query, n int) *query.Query {
+	for i := 1; i <= n; i++ {
+		if i == 1 {
+			q.Write(" GROUP BY ")
+		} else {
+			q.Write(", ")
+		}
+		q.Write(fmt.Sprint(i))
+	}
+	return q
+}
+
+// scanGroup scans group values followed by dest
+func scanGroup(row interface{ Scan(...any) error }, by []string, dest ...any) (StatsGroup, error) {
+	values := make([]string, len(by))
+	targets := make([]any, 0, len(by)+len(dest))
+	for i := range values {
+		targets = append(targets, &values[i])
+	}
+	if err := row.Scan(append(targets, dest...)...); err != nil {
+		return nil, err
+	}
+	group := make(StatsGroup, len(by))
+	for i, field := range by {
+		group[field] = values[i]
+	}
+	return group, nil
+}
+
+type CarCount struct {
+	Group StatsGroup `json:"group"`
+	Count int        `json:"count"`
+}
+
+type CarCounts struct {
+	AsOf   time.Time  `json:"asOf"`
+	Counts []CarCount `json:"counts"`
+}
+
+// Get counts cars matching filter c grouped by fields by, the largest groups first.
+// Without fields the only count is total one. snapshot allows to read car_stats view instead of tables
+func (cc *CarCounts) Get(ctx context.Context, storage *postgres.Storage, c *Car, by []string, scope Scope, snapshot bool) error {
+	const op = "storage.entities.CarCounts.Get"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	c = c.orActive()
+	src := sourceOf(c, snapshot)
+	q, err := statsQuery(src, carCond(c, scope), by, src.cars+" AS n")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	groupBy(q, len(by)).Write(" ORDER BY n DESC")
+	for i := 1; i <= len(by); i++ {
+		q.Write(fmt.Sprintf(", %d", i))
+	}
+
+	err = storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		if err := tx.QueryRow(src.asOf).Scan(&cc.AsOf); err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(q.String(), q.Args()...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		cc.Counts = []CarCount{}
+		for rows.Next() {
+			var count CarCount
+			if count.Group, err = scanGroup(rows, by, &count.Count); err != nil {
+				return err
+			}
+			cc.Counts = append(cc.Counts, count)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AgeBucket counts cars with age in [From, To) years
+type AgeBucket struct {
+	From  int `json:"from"`
+	To    int `json:"to"`
+	Count int `json:"count"`
+}
+
+type AgeStat struct {
+	Group StatsGroup `json:"group"`
+	// Count is number of cars with known year
+	Count      int         `json:"count"`
+	AverageAge float64     `json:"averageAge"`
+	Histogram  []AgeBucket `json:"histogram"`
+}
+
+type AgeStats struct {
+	AsOf time.Time `json:"asOf"`
+	Ages []AgeStat `json:"ages"`
+}
+
+// Get computes age distribution of cars matching filter c grouped by fields by. Age is
+// counted in whole years from car year, histogram buckets are bucket years wide.
+// Cars with unknown year are skipped
+func (as *AgeStats) Get(ctx context.Context, storage *postgres.Storage, c *Car, by []string, bucket int, scope Scope, snapshot bool) error {
+	const op = "storage.entities.AgeStats.Get"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	if bucket <= 0 {
+		return fmt.Errorf("%s: bucket must be positive, got %d", op, bucket)
+	}
+
+	c = c.orActive()
+	src := sourceOf(c, snapshot)
+	cond := carCond(c, scope).Add(`NULLIF(c."year", 0) IS NOT NULL`)
+	q, err := statsQuery(src, cond, by, qrStatsAge+" AS age", src.cars)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	groupBy(q, len(by)+1)
+
+	// Groups are keyed by values of grouping fields
+	stats := make(map[string]*AgeStat)
+	var keys []string
+	err = storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		if err := tx.QueryRow(src.asOf).Scan(&as.AsOf); err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(q.String(), q.Args()...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var age, count int
+			group, err := scanGroup(rows, by, &age, &count)
+			if err != nil {
+				return err
+			}
+			key := fmt.Sprint(group)
+			s, ok := stats[key]
+			if !ok {
+				s = &AgeStat{Group: group}
+				stats[key] = s
+				keys = append(keys, key)
+			}
+			s.add(age, count, bucket)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	as.Ages = make([]AgeStat, 0, len(keys))
+	for _, key := range keys {
+		s := stats[key]
+		s.AverageAge /= float64(s.Count)
+		sort.Slice(s.Histogram, func(i, j int) bool { return s.Histogram[i].From < s.Histogram[j].From })
+		as.Ages = append(as.Ages, *s)
+	}
+	sort.SliceStable(as.Ages, func(i, j int) bool { return as.Ages[i].Count > as.Ages[j].Count })
+
+	return nil
+}
+
+// add counts cars of age. AverageAge keeps sum of ages until all cars are added
+func (s *AgeStat) add(age, count, bucket int) {
+	s.Count += count
+	s.AverageAge += float64(age * count)
+
+	from := age / bucket * bucket
+	for i := range s.Histogram {
+		if s.Histogram[i].From == from {
+			s.Histogram[i].Count += count
+			return
+		}
+	}
+	s.Histogram = append(s.Histogram, AgeBucket{From: from, To: from + bucket, Count: count})
+}
+
+type OwnerStat struct {
+	Owner Person `json:"owner"`
+	Cars  int    `json:"cars"`
+}
+
+type TopOwners struct {
+	AsOf   time.Time   `json:"asOf"`
+	Owners []OwnerStat `json:"owners"`
+}
+
+// Get returns at most limit owners of at least minCars cars matching filter c, owners of
+// more cars first
+func (to *TopOwners) Get(ctx context.Context, storage *postgres.Storage, c *Car, minCars, limit int, scope Scope, snapshot bool) error {
+	const op = "storage.entities.TopOwners.Get"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	c = c.orActive()
+	src := sourceOf(c, snapshot)
+	q, err := statsQuery(src, carCond(c, scope), nil, `p.person_id, p."name", p.surname, p.patronymic`, src.cars+" AS n")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	groupBy(q, 4).Write(" HAVING "+src.cars+" >= ?", minCars).
+		Write(" ORDER BY n DESC, p.person_id").Limit(limit, 0)
+
+	err = storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		if err := tx.QueryRow(src.asOf).Scan(&to.AsOf); err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(q.String(), q.Args()...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		to.Owners = []OwnerStat{}
+		for rows.Next() {
+			var s OwnerStat
+			o := &s.Owner
+			if err := rows.Scan(&o.PersonID, &o.Name, &o.Surname, &o.Patronymic, &s.Cars); err != nil {
+				return err
+			}
+			to.Owners = append(to.Owners, s)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GrowthPoint is number of cars added in period starting at Period and total number of cars by its end
+type GrowthPoint struct {
+	Period time.Time `json:"period"`
+	Added  int       `json:"added"`
+	Total  int       `json:"total"`
+}
+
+type Growth struct {
+	AsOf   time.Time     `json:"asOf"`
+	Points []GrowthPoint `json:"points"`
+}
+
+// Get counts cars matching filter c by creation month or year. Deleted cars are not counted,
+// periods without added cars are skipped
+func (g *Growth) Get(ctx context.Context, storage *postgres.Storage, c *Car, interval string, scope Scope, snapshot bool) error {
+	const op = "storage.entities.Growth.Get"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	if interval != IntervalMonth && interval != IntervalYear {
+		return fmt.Errorf("%s: unknown interval %q", op, interval)
+	}
+
+	// Interval is one of constants, so it can be part of SQL
+	c = c.orActive()
+	src := sourceOf(c, snapshot)
+	q := query.New("SELECT period, n, (sum(n) OVER (ORDER BY period))::INT FROM (SELECT date_trunc('" +
+		interval + "', " + src.month + ") AS period, " + src.cars + " AS n" + src.from).
+		Where(carCond(c, scope)).
+		Write(" GROUP BY 1) AS g ORDER BY period")
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		if err := tx.QueryRow(src.asOf).Scan(&g.AsOf); err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(q.String(), q.Args()...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		g.Points = []GrowthPoint{}
+		for rows.Next() {
+			var p GrowthPoint
+			if err := rows.Scan(&p.Period, &p.Added, &p.Total); err != nil {
+				return err
+			}
+			g.Points = append(g.Points, p)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RefreshStats refreshes car_stats view. It returns false without refresh if another
+// instance is refreshing it now
+func RefreshStats(ctx context.Context, storage *postgres.Storage) (bool, error) {
+	const op = "storage.entities.RefreshStats"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	var locked bool
+	err := storage.AsPlatform(ctx, func(tx *postgres.Tx) error {
+		if err := tx.QueryRow(qrLockStatsRefresh).Scan(&locked); err != nil || !locked {
+			return err
+		}
+		if _, err := tx.Exec(qrRefreshCarStats); err != nil {
+			return err
+		}
+		_, err := tx.Exec(qrSetStatsRefresh)
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return locked, nil
+}
@@ -0,0 +1,130 @@
+package entities
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"catalog/internal/storage/storagetest"
+)
+
+func TestStats(t *testing.T) {
+	storage := storagetest.New(t)
+	ctx := context.Background()
+	scope := Scope{TenantID: "stats"}
+	year := time.Now().Year()
+
+	ivan := Person{Name: "Ivan", Surname: "Petrov", Patronymic: "Sergeevich"}
+	anna := Person{Name: "Anna", Surname: "Ivanova", Patronymic: "Petrovna"}
+	cars := []Car{
+		{RegNum: "A001AA77", Mark: "Lada", Model: "Vesta", Year: year - 1, Region: "moscow", Owner: ivan},
+		{RegNum: "B002BB77", Mark: "Lada", Model: "Granta", Year: year - 3, Region: "moscow", Owner: anna},
+		{RegNum: "C003CC16", Mark: "Kia", Model: "Rio", Year: year - 12, Region: "tatarstan", Owner: ivan},
+		// Year is unknown
+		{RegNum: "D004DD16", Mark: "Kia", Model: "Rio", Region: "tatarstan", Owner: ivan},
+	}
+	for _, c := range cars {
+		if err := c.New(ctx, storage, scope); err != nil {
+			t.Fatalf("failed to add car: %v", err)
+		}
+	}
+	// Sold car is counted only if asked
+	sold := Car{RegNum: "E005EE16", Mark: "Kia", Model: "Rio", Year: year - 5, Region: "tatarstan", Owner: ivan}
+	if err := sold.New(ctx, storage, scope); err != nil {
+		t.Fatalf("failed to add car: %v", err)
+	}
+	var cp CatalogPage
+	if err := cp.GetCatalogPage(ctx, storage, &Car{RegNum: sold.RegNum}, 1, scope); err != nil || len(cp.Cars) != 1 {
+		t.Fatalf("failed to get added car: %v", err)
+	}
+	sc := StatusChange{CarID: cp.Cars[0].CarID, To: StatusSold, ChangedBy: "test"}
+	if err := sc.New(ctx, storage, scope); err != nil {
+		t.Fatalf("failed to sell car: %v", err)
+	}
+	// Car of another tenant is not counted
+	other := Car{RegNum: "A001AA77", Mark: "Lada", Model: "Vesta", Year: year, Owner: ivan}
+	if err := other.New(ctx, storage, Scope{TenantID: "stats-other"}); err != nil {
+		t.Fatalf("failed to add car: %v", err)
+	}
+
+	refreshed, err := RefreshStats(ctx, storage)
+	if err != nil || !refreshed {
+		t.Fatalf("RefreshStats = %v, %v, want true", refreshed, err)
+	}
+
+	// Snapshot and tables give the same stats
+	for _, snapshot := range []bool{false, true} {
+		var cc CarCounts
+		if err := cc.Get(ctx, storage, &Car{}, []string{"mark", "year"}, scope, snapshot); err != nil {
+			t.Fatalf("snapshot %v: CarCounts: %v", snapshot, err)
+		}
+		wantCounts := []CarCount{
+			{Group: StatsGroup{"mark": "Kia", "year": ""}, Count: 1},
+			{Group: StatsGroup{"mark": "Kia", "year": fmt.Sprint(year - 12)}, Count: 1},
+			{Group: StatsGroup{"mark": "Lada", "year": fmt.Sprint(year - 3)}, Count: 1},
+			{Group: StatsGroup{"mark": "Lada", "year": fmt.Sprint(year - 1)}, Count: 1},
+		}
+		if !reflect.DeepEqual(cc.Counts, wantCounts) {
+			t.Fatalf("snapshot %v: counts = %+v, want %+v", snapshot, cc.Counts, wantCounts)
+		}
+
+		cc = CarCounts{}
+		if err := cc.Get(ctx, storage, &Car{Status: StatusAny}, nil, scope, snapshot); err != nil {
+			t.Fatalf("snapshot %v: CarCounts of any status: %v", snapshot, err)
+		}
+		if len(cc.Counts) != 1 || cc.Counts[0].Count != 5 {
+			t.Fatalf("snapshot %v: counts of any status = %+v, want 5 cars", snapshot, cc.Counts)
+		}
+		cc = CarCounts{}
+		if err := cc.Get(ctx, storage, &Car{Status: StatusSold}, []string{"mark"}, scope, snapshot); err != nil {
+			t.Fatalf("snapshot %v: CarCounts of sold: %v", snapshot, err)
+		}
+		if want := []CarCount{{Group: StatsGroup{"mark": "Kia"}, Count: 1}}; !reflect.DeepEqual(cc.Counts, want) {
+			t.Fatalf("snapshot %v: counts of sold = %+v, want %+v", snapshot, cc.Counts, want)
+		}
+
+		var as AgeStats
+		if err := as.Get(ctx, storage, &Car{}, nil, 5, scope, snapshot); err != nil {
+			t.Fatalf("snapshot %v: AgeStats: %v", snapshot, err)
+		}
+		wantAges := []AgeStat{{
+			Group:      StatsGroup{},
+			Count:      3,
+			AverageAge: 16.0 / 3,
+			Histogram:  []AgeBucket{{From: 0, To: 5, Count: 2}, {From: 10, To: 15, Count: 1}},
+		}}
+		if !reflect.DeepEqual(as.Ages, wantAges) {
+			t.Fatalf("snapshot %v: ages = %+v, want %+v", snapshot, as.Ages, wantAges)
+		}
+
+		var to TopOwners
+		if err := to.Get(ctx, storage, &Car{Region: "tatarstan"}, 2, 10, scope, snapshot); err != nil {
+			t.Fatalf("snapshot %v: TopOwners: %v", snapshot, err)
+		}
+		if len(to.Owners) != 1 || to.Owners[0].Owner.Surname != "Petrov" || to.Owners[0].Cars != 2 {
+			t.Fatalf("snapshot %v: owners = %+v, want Petrov with 2 cars", snapshot, to.Owners)
+		}
+
+		var g Growth
+		if err := g.Get(ctx, storage, &Car{Mark: "Lada"}, IntervalYear, scope, snapshot); err != nil {
+			t.Fatalf("snapshot %v: Growth: %v", snapshot, err)
+		}
+		if len(g.Points) != 1 || g.Points[0].Added != 2 || g.Points[0].Total != 2 || g.Points[0].Period.Year() != year {
+			t.Fatalf("snapshot %v: growth = %+v, want 2 cars of this year", snapshot, g.Points)
+		}
+	}
+}
+
+func TestAgeStatAdd(t *testing.T) {
+	var s AgeStat
+	s.add(3, 2, 5)
+	s.add(12, 1, 5)
+	s.add(4, 1, 5)
+
+	want := AgeStat{Count: 4, AverageAge: 22, Histogram: []AgeBucket{{From: 0, To: 5, Count: 3}, {From: 10, To: 15, Count: 1}}}
+	if !reflect.DeepEqual(s, want) {
+		t.Fatalf("stat = %+v, want %+v", s, want)
+	}
+}
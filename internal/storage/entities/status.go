@@ -0,0 +1,104 @@
+package entities
+
+import (
+	"catalog/internal/lib/metrics"
+	postgres "catalog/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// Car is locked, so concurrent transitions are applied one by one
+	qrGetCarStatus = `SELECT status FROM car WHERE car_id = $1 AND tenant_id = $2
+					  AND ($3::TEXT[] IS NULL OR region = ANY($3)) FOR UPDATE;`
+	qrSetCarStatus       = `UPDATE car SET status = $2 WHERE car_id = $1;`
+	qrNewCarStatusChange = `INSERT INTO car_status_history(car_id, tenant_id, from_status, to_status, reason, changed_by)
+							VALUES ($1, $2, $3, $4, $5, $6)
+							RETURNING history_id, changed_at;`
+)
+
+// Statuses of car lifecycle
+const (
+	StatusActive   = "active"
+	StatusInRepair = "in_repair"
+	StatusSold     = "sold"
+	StatusScrapped = "scrapped"
+	// StatusAny is filter of cars in every status, cars never have it
+	StatusAny = "any"
+)
+
+// CarStatuses are statuses car can have
+var CarStatuses = []string{StatusActive, StatusInRepair, StatusSold, StatusScrapped}
+
+// statusTransitions are allowed changes of status. Scrapped car is never back
+var statusTransitions = map[string][]string{
+	StatusActive:   {StatusInRepair, StatusSold, StatusScrapped},
+	StatusInRepair: {StatusActive, StatusSold, StatusScrapped},
+	StatusSold:     {StatusActive},
+	StatusScrapped: {},
+}
+
+// ErrIllegalTransition is returned on change of status not allowed by statusTransitions
+var ErrIllegalTransition = errors.New("illegal status transition")
+
+// IsCarStatus tells if status is one of CarStatuses
+func IsCarStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// CanTransit tells if car in status from can be moved to status to
+func CanTransit(from, to string) bool {
+	for _, s := range statusTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusChange is record of car status transition
+type StatusChange struct {
+	HistoryID int       `json:"historyId"`
+	CarID     int       `json:"carId"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Reason    string    `json:"reason,omitempty"`
+	ChangedBy string    `json:"changedBy"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// New moves car sc.CarID to status sc.To and records the change. sc.From is set to
+// previous status. Missing car or car outside of scope gives ErrNotFound, transition
+// not allowed from current status gives ErrIllegalTransition
+func (sc *StatusChange) New(ctx context.Context, storage *postgres.Storage, scope Scope) error {
+	const op = "storage.entities.StatusChange.New"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		err := tx.QueryRow(qrGetCarStatus, sc.CarID, scope.TenantID, scope.regions()).Scan(&sc.From)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !CanTransit(sc.From, sc.To) {
+			return fmt.Errorf("%w from %s to %s", ErrIllegalTransition, sc.From, sc.To)
+		}
+
+		if _, err := tx.Exec(qrSetCarStatus, sc.CarID, sc.To); err != nil {
+			return err
+		}
+		return tx.QueryRow(qrNewCarStatusChange, sc.CarID, scope.TenantID, sc.From, sc.To, sc.Reason,
+			sc.ChangedBy).Scan(&sc.HistoryID, &sc.ChangedAt)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
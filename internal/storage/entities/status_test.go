@@ -0,0 +1,26 @@
+package entities
+
+import "testing"
+
+func TestCanTransit(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{StatusActive, StatusInRepair, true},
+		{StatusInRepair, StatusActive, true},
+		{StatusActive, StatusSold, true},
+		{StatusSold, StatusActive, true},
+		{StatusSold, StatusInRepair, false},
+		{StatusInRepair, StatusScrapped, true},
+		{StatusScrapped, StatusActive, false},
+		{StatusActive, StatusActive, false},
+		{StatusActive, StatusAny, false},
+		{"stolen", StatusActive, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransit(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransit(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package entities
+
+import (
+	"catalog/internal/lib/metrics"
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/query"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+const (
+	qrCountCarsInScope = `SELECT count(car_id) FROM car WHERE car_id = ANY($1) AND tenant_id = $2
+						  AND ($3::TEXT[] IS NULL OR region = ANY($3));`
+	qrNewTags = `INSERT INTO tag(tenant_id, "name") SELECT $1::TEXT, unnest($2::TEXT[])
+				 ON CONFLICT (tenant_id, "name") DO NOTHING;`
+	qrTagCars = `INSERT INTO car_tag(car_id, tag_id, tenant_id, created_by)
+				 SELECT c.car_id, t.tag_id, c.tenant_id, $4::TEXT FROM car c JOIN tag t ON t.tenant_id = c.tenant_id
+				 WHERE c.car_id = ANY($1) AND c.tenant_id = $2 AND t."name" = ANY($3)
+				 ON CONFLICT DO NOTHING;`
+	qrUntagCars = `DELETE FROM car_tag ct USING tag t
+				   WHERE t.tag_id = ct.tag_id AND ct.car_id = ANY($1) AND ct.tenant_id = $2 AND t."name" = ANY($3);`
+	qrGetCarTags = `SELECT t."name" FROM car_tag ct JOIN tag t ON t.tag_id = ct.tag_id
+					WHERE ct.car_id = $1 AND ct.tenant_id = $2 ORDER BY t."name";`
+	// Unused tags are kept, they aren't listed
+	qrGetTags = `SELECT t."name", count(c.car_id) FROM tag t
+				 JOIN car_tag ct ON ct.tag_id = t.tag_id JOIN car c ON c.car_id = ct.car_id
+				 WHERE t.tenant_id = $1 AND ($2::TEXT[] IS NULL OR c.region = ANY($2))
+				 GROUP BY t."name" ORDER BY count(c.car_id) DESC, t."name";`
+)
+
+// TagMaxLen limits length of tag name in characters
+const TagMaxLen = 50
+
+// Tag is name of cars group with count of cars in it
+type Tag struct {
+	Name string `json:"name"`
+	Cars int    `json:"cars"`
+}
+
+type Tags []Tag
+
+// TagFilter limits catalog to cars with any of Names or with all of them if All is set.
+// Names must be unique
+type TagFilter struct {
+	Names []string
+	All   bool
+}
+
+// NewTagFilter makes filter of trimmed names, repeated ones are dropped. Filter is nil
+// without names
+func NewTagFilter(names []string, all bool) (*TagFilter, error) {
+	tf := TagFilter{All: all}
+	seen := make(map[string]bool)
+	for _, t := range names {
+		t = strings.TrimSpace(t)
+		if !ValidTag(t) {
+			return nil, fmt.Errorf("tag must be 1 to %d characters, got %q", TagMaxLen, t)
+		}
+		if !seen[t] {
+			seen[t] = true
+			tf.Names = append(tf.Names, t)
+		}
+	}
+	if len(tf.Names) == 0 {
+		return nil, nil
+	}
+	return &tf, nil
+}
+
+// TagEdit adds tags Add and removes tags Remove of every car of CarIDs. Added and
+// Removed are counts of changed car tags, repeated changes are not counted
+type TagEdit struct {
+	CarIDs    []int
+	Add       []string
+	Remove    []string
+	CreatedBy string
+	Added     int
+	Removed   int
+}
+
+// ValidTag tells if name can be tag: not empty, without surrounding spaces and at most
+// TagMaxLen characters long
+func ValidTag(name string) bool {
+	return name != "" && name == strings.TrimSpace(name) && utf8.ValidString(name) &&
+		utf8.RuneCountInString(name) <= TagMaxLen
+}
+
+// Apply changes tags of cars at once. Any car missing or outside of scope gives
+// ErrNotFound and nothing is changed
+func (te *TagEdit) Apply(ctx context.Context, storage *postgres.Storage, scope Scope) error {
+	const op = "storage.entities.TagEdit.Apply"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	carIDs := uniqueInts(te.CarIDs)
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		var n int
+		if err := tx.QueryRow(qrCountCarsInScope, pq.Array(carIDs), scope.TenantID, scope.regions()).Scan(&n); err != nil {
+			return err
+		}
+		if n != len(carIDs) {
+			return ErrNotFound
+		}
+
+		if len(te.Remove) > 0 {
+			res, err := tx.Exec(qrUntagCars, pq.Array(carIDs), scope.TenantID, pq.Array(te.Remove))
+			if err != nil {
+				return err
+			}
+			removed, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			te.Removed = int(removed)
+		}
+
+		if len(te.Add) > 0 {
+			if _, err := tx.Exec(qrNewTags, scope.TenantID, pq.Array(te.Add)); err != nil {
+				return err
+			}
+			res, err := tx.Exec(qrTagCars, pq.Array(carIDs), scope.TenantID, pq.Array(te.Add), te.CreatedBy)
+			if err != nil {
+				return err
+			}
+			added, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			te.Added = int(added)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetCarTags returns sorted tags of car. Missing car or car outside of scope gives ErrNotFound
+func GetCarTags(ctx context.Context, storage *postgres.Storage, carID int, scope Scope) ([]string, error) {
+	const op = "storage.entities.GetCarTags"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	tags := []string{}
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(qrCarInScope, carID, scope.TenantID, scope.regions()).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound
+		}
+
+		qrResult, err := tx.Query(qrGetCarTags, carID, scope.TenantID)
+		if err != nil {
+			return err
+		}
+		defer qrResult.Close()
+
+		for qrResult.Next() {
+			var tag string
+			if err := qrResult.Scan(&tag); err != nil {
+				return err
+			}
+			tags = append(tags, tag)
+		}
+		return qrResult.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tags, nil
+}
+
+// Get returns tags of cars of scope, the most used first
+func (ts *Tags) Get(ctx context.Context, storage *postgres.Storage, scope Scope) error {
+	const op = "storage.entities.Tags.Get"
+	defer metrics.ObserveQuery(op, time.Now())
+
+	err := storage.InTenant(ctx, scope.TenantID, func(tx *postgres.Tx) error {
+		qrResult, err := tx.Query(qrGetTags, scope.TenantID, scope.regions())
+		if err != nil {
+			return err
+		}
+		defer qrResult.Close()
+
+		for qrResult.Next() {
+			var t Tag
+			if err := qrResult.Scan(&t.Name, &t.Cars); err != nil {
+				return err
+			}
+			*ts = append(*ts, t)
+		}
+		return qrResult.Err()
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// addTagCond adds condition of cars matching tf to cond
+func addTagCond(cond *query.Cond, tf *TagFilter) {
+	if len(tf.Names) == 0 {
+		return
+	}
+	if !tf.All {
+		cond.Add(`EXISTS (SELECT 1 FROM car_tag ct JOIN tag t ON t.tag_id = ct.tag_id
+			WHERE ct.car_id = c.car_id AND t."name" = ANY(?))`, pq.Array(tf.Names))
+		return
+	}
+	// Car has every tag once, so count of matched tags is count of names
+	cond.Add(`(SELECT count(*) FROM car_tag ct JOIN tag t ON t.tag_id = ct.tag_id
+		WHERE ct.car_id = c.car_id AND t."name" = ANY(?)) = ?`, pq.Array(tf.Names), len(tf.Names))
+}
+
+func uniqueInts(ns []int) []int {
+	seen := make(map[int]bool, len(ns))
+	unique := make([]int, 0, len(ns))
+	for _, n := range ns {
+		if !seen[n] {
+			seen[n] = true
+			unique = append(unique, n)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,149 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	postgres "catalog/internal/storage"
+	"catalog/internal/storage/storagetest"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	storage := storagetest.New(t)
+	ctx := context.Background()
+
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	scopeA := Scope{TenantID: "test-a-" + suffix}
+	scopeB := Scope{TenantID: "test-b-" + suffix}
+	mark := "Mark-" + suffix
+
+	// The same owner in both tenants must not conflict
+	owner := Person{Name: "Ivan", Surname: "Petrov", Patronymic: "Sergeevich"}
+	carA := Car{RegNum: "A111AA77", Mark: mark, Model: "A", Year: 2010, Owner: owner}
+	carB := Car{RegNum: "B222BB77", Mark: mark, Model: "B", Year: 2012, Owner: owner}
+	if err := carA.New(ctx, storage, scopeA); err != nil {
+		t.Fatalf("failed to add car of tenant A: %v", err)
+	}
+	if err := carB.New(ctx, storage, scopeB); err != nil {
+		t.Fatalf("failed to add car of tenant B: %v", err)
+	}
+
+	// Catalog and its count see own cars only
+	var cpA CatalogPage
+	if err := cpA.GetCatalogPage(ctx, storage, &Car{Mark: mark}, 1, scopeA); err != nil {
+		t.Fatalf("failed to get catalog of tenant A: %v", err)
+	}
+	if len(cpA.Cars) != 1 || cpA.Cars[0].RegNum != carA.RegNum || cpA.Pagination.TotalPage != 1 {
+		t.Fatalf("tenant A catalog = %+v, want only %s", cpA, carA.RegNum)
+	}
+
+	var cpB CatalogPage
+	if err := cpB.GetCatalogPage(ctx, storage, &Car{Mark: mark}, 1, scopeB); err != nil {
+		t.Fatalf("failed to get catalog of tenant B: %v", err)
+	}
+	if len(cpB.Cars) != 1 || cpB.Cars[0].RegNum != carB.RegNum {
+		t.Fatalf("tenant B catalog = %+v, want only %s", cpB, carB.RegNum)
+	}
+	carBID := cpB.Cars[0].CarID
+
+	// Tenant A can't change or delete car of tenant B
+	edit := Car{CarID: carBID, Model: "Hijacked"}
+	if err := edit.Edit(ctx, storage, scopeA); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("edit of foreign car: err = %v, want ErrNotFound", err)
+	}
+	var c Car
+	if err := c.Delete(ctx, storage, carBID, scopeA); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("delete of foreign car: err = %v, want ErrNotFound", err)
+	}
+
+	cpB = CatalogPage{}
+	if err := cpB.GetCatalogPage(ctx, storage, &Car{CarID: carBID}, 1, scopeB); err != nil {
+		t.Fatalf("failed to get car of tenant B: %v", err)
+	}
+	if len(cpB.Cars) != 1 || cpB.Cars[0].Model != carB.Model {
+		t.Fatalf("car of tenant B was changed: %+v", cpB.Cars)
+	}
+
+	// Change log of tenant A has no changes of tenant B
+	var ccs CarChanges
+	if err := ccs.GetSinceInScope(ctx, storage, 0, 1<<20, scopeA); err != nil {
+		t.Fatalf("failed to get changes of tenant A: %v", err)
+	}
+	for _, cc := range ccs {
+		if cc.TenantID != scopeA.TenantID {
+			t.Fatalf("change of tenant %s leaked to tenant A", cc.TenantID)
+		}
+	}
+
+	// Row level security hides foreign rows even without tenant condition in query
+	var superuser bool
+	if err := storage.DB.QueryRow(`SELECT rolsuper FROM pg_roles WHERE rolname = current_user;`).Scan(&superuser); err != nil {
+		t.Fatalf("failed to check role: %v", err)
+	}
+	if superuser {
+		t.Log("row level security is not checked: superusers bypass policies")
+		return
+	}
+	err := storage.InTenant(ctx, scopeA.TenantID, func(tx *postgres.Tx) error {
+		var n int
+		if err := tx.QueryRow(`SELECT count(*) FROM car WHERE mark = $1;`, mark).Scan(&n); err != nil {
+			return err
+		}
+		if n != 1 {
+			t.Errorf("row level security: tenant A sees %d cars, want 1", n)
+		}
+		if err := tx.QueryRow(`SELECT count(*) FROM person WHERE tenant_id = $1;`, scopeB.TenantID).Scan(&n); err != nil {
+			return err
+		}
+		if n != 0 {
+			t.Errorf("row level security: tenant A sees %d persons of tenant B", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to check row level security: %v", err)
+	}
+
+	// Without tenant nothing is visible, platform role sees all tenants
+	var n int
+	if err := storage.DB.QueryRow(`SELECT count(*) FROM car WHERE mark = $1;`, mark).Scan(&n); err != nil {
+		t.Fatalf("failed to count cars without tenant: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("row level security: %d cars are visible without tenant, want 0", n)
+	}
+	err = storage.AsPlatform(ctx, func(tx *postgres.Tx) error {
+		return tx.QueryRow(`SELECT count(*) FROM car WHERE mark = $1;`, mark).Scan(&n)
+	})
+	if err != nil {
+		t.Fatalf("failed to count cars as platform: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("platform sees %d cars, want 2", n)
+	}
+
+	// Metrics count active cars, as catalog shows them
+	sold := StatusChange{CarID: carBID, To: StatusSold, ChangedBy: "test"}
+	if err := sold.New(ctx, storage, scopeB); err != nil {
+		t.Fatalf("failed to sell car of tenant B: %v", err)
+	}
+	counts, err := CountCarsByTenant(ctx, storage)
+	if err != nil {
+		t.Fatalf("failed to count cars by tenant: %v", err)
+	}
+	if counts[scopeA.TenantID] != 1 || counts[scopeB.TenantID] != 0 {
+		t.Errorf("cars by tenant = %v, want 1 of A and 0 of B", counts)
+	}
+}
+
+func TestNoTenant(t *testing.T) {
+	storage := storagetest.New(t)
+
+	var cp CatalogPage
+	if err := cp.GetCatalogPage(context.Background(), storage, &Car{}, 1, Scope{}); !errors.Is(err, postgres.ErrNoTenant) {
+		t.Fatalf("err = %v, want ErrNoTenant", err)
+	}
+}
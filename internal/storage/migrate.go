@@ -0,0 +1,174 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"catalog/internal/storage/migrations"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/lib/pq"
+)
+
+const (
+	qrGetSchemaVersion = `SELECT version, dirty FROM schema_migrations;`
+)
+
+// Migrator applies migrations embedded into binary
+type Migrator struct {
+	m *migrate.Migrate
+}
+
+// NewMigrator connects to DB by info in form user:password@host:port/dbname?params
+func NewMigrator(info string) (*Migrator, error) {
+	const op = "storage.postgres.NewMigrator"
+
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", src, "postgres://"+info)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Migrator{m: m}, nil
+}
+
+func (mg *Migrator) Close() error {
+	srcErr, dbErr := mg.m.Close()
+	return errors.Join(srcErr, dbErr)
+}
+
+// Up applies all migrations. Up to date schema is not an error
+func (mg *Migrator) Up() error {
+	const op = "storage.postgres.Migrator.Up"
+
+	if err := mg.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Down reverts n last migrations
+func (mg *Migrator) Down(n int) error {
+	const op = "storage.postgres.Migrator.Down"
+
+	if err := mg.m.Steps(-n); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Goto migrates up or down to version
+func (mg *Migrator) Goto(version uint) error {
+	const op = "storage.postgres.Migrator.Goto"
+
+	if err := mg.m.Migrate(version); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Version returns applied version, 0 for empty DB
+func (mg *Migrator) Version() (uint, bool, error) {
+	const op = "storage.postgres.Migrator.Version"
+
+	version, dirty, err := mg.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+	return version, dirty, nil
+}
+
+// Force sets version without migrating and clears dirty flag. -1 means no version
+func (mg *Migrator) Force(version int) error {
+	const op = "storage.postgres.Migrator.Force"
+
+	if err := mg.m.Force(version); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UpMigration applies all embedded migrations
+func (s *Storage) UpMigration(info string) error {
+	const op = "storage.postgres.UpMigration"
+
+	mg, err := NewMigrator(info)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer mg.Close()
+
+	if err := mg.Up(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CheckSchema returns ErrSchemaOutdated if DB schema version is dirty or isn't the
+// latest embedded migration
+func (s *Storage) CheckSchema(ctx context.Context) error {
+	const op = "storage.postgres.CheckSchema"
+
+	latest, err := LatestMigration()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var version uint
+	var dirty bool
+	err = s.DB.QueryRowContext(ctx, qrGetSchemaVersion).Scan(&version, &dirty)
+	// Empty or missing migrations table
+	var pqErr *pq.Error
+	if errors.Is(err, sql.ErrNoRows) || errors.As(err, &pqErr) && pqErr.Code == "42P01" {
+		return fmt.Errorf("%s: %w: not migrated", op, ErrSchemaOutdated)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if dirty {
+		return fmt.Errorf("%s: %w: version %d is dirty", op, ErrSchemaOutdated, version)
+	}
+	if version != latest {
+		return fmt.Errorf("%s: %w: version %d, want %d", op, ErrSchemaOutdated, version, latest)
+	}
+
+	return nil
+}
+
+// LatestMigration returns version of the last embedded migration
+func LatestMigration() (uint, error) {
+	const op = "storage.postgres.LatestMigration"
+
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		version = next
+	}
+}
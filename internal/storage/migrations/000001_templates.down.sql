@@ -1,2 +1,2 @@
-DROP TABLE IF EXISTS car;
+DROP TABLE IF EXISTS car;
 DROP TABLE IF EXISTS person;
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS car_change_trigger ON car;
+DROP FUNCTION IF EXISTS car_change_notify();
+DROP TABLE IF EXISTS car_change;
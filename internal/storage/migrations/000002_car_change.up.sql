@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS car_change(
+	change_id BIGSERIAL PRIMARY KEY,
+	car_id INT NOT NULL,
+	op TEXT NOT NULL,
+	car JSONB NOT NULL,
+	changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS car_change_changed_at_idx ON car_change(changed_at);
+
+CREATE OR REPLACE FUNCTION car_change_notify() RETURNS trigger AS $$
+DECLARE
+	r car%ROWTYPE;
+	car_owner JSONB;
+	new_change_id BIGINT;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		r := OLD;
+	ELSE
+		r := NEW;
+	END IF;
+
+	-- Owner can be already gone when deletion cascades from person
+	SELECT jsonb_build_object('personId', p.person_id, 'name', p."name", 'surname', p.surname, 'patronymic', p.patronymic)
+	INTO car_owner FROM person p WHERE p.person_id = r."owner";
+
+	INSERT INTO car_change(car_id, op, car)
+	VALUES (r.car_id, lower(TG_OP), jsonb_build_object(
+		'carId', r.car_id,
+		'regNum', r.reg_num,
+		'mark', r.mark,
+		'model', r.model,
+		'year', r."year",
+		'owner', COALESCE(car_owner, jsonb_build_object('personId', r."owner"))
+	))
+	RETURNING change_id INTO new_change_id;
+
+	PERFORM pg_notify('car_change', new_change_id::text);
+
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER car_change_trigger
+AFTER INSERT OR UPDATE OR DELETE ON car
+FOR EACH ROW EXECUTE FUNCTION car_change_notify();
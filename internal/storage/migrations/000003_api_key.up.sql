@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS api_key(
+	key_id SERIAL PRIMARY KEY,
+	"name" TEXT NOT NULL,
+	prefix TEXT NOT NULL,
+	key_hash TEXT NOT NULL,
+	created_by TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_used_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ
+);
+
+ALTER TABLE api_key ADD CONSTRAINT key_hash_constraint UNIQUE (key_hash);
@@ -0,0 +1,40 @@
+-- Restore change payload without region
+CREATE OR REPLACE FUNCTION car_change_notify() RETURNS trigger AS $$
+DECLARE
+	r car%ROWTYPE;
+	car_owner JSONB;
+	new_change_id BIGINT;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		r := OLD;
+	ELSE
+		r := NEW;
+	END IF;
+
+	-- Owner can be already gone when deletion cascades from person
+	SELECT jsonb_build_object('personId', p.person_id, 'name', p."name", 'surname', p.surname, 'patronymic', p.patronymic)
+	INTO car_owner FROM person p WHERE p.person_id = r."owner";
+
+	INSERT INTO car_change(car_id, op, car)
+	VALUES (r.car_id, lower(TG_OP), jsonb_build_object(
+		'carId', r.car_id,
+		'regNum', r.reg_num,
+		'mark', r.mark,
+		'model', r.model,
+		'year', r."year",
+		'owner', COALESCE(car_owner, jsonb_build_object('personId', r."owner"))
+	))
+	RETURNING change_id INTO new_change_id;
+
+	PERFORM pg_notify('car_change', new_change_id::text);
+
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS car_region_idx;
+ALTER TABLE car DROP COLUMN IF EXISTS region;
+
+ALTER TABLE api_key DROP COLUMN IF EXISTS regions;
+ALTER TABLE api_key DROP CONSTRAINT IF EXISTS role_constraint;
+ALTER TABLE api_key DROP COLUMN IF EXISTS "role";
@@ -0,0 +1,45 @@
+ALTER TABLE api_key ADD COLUMN IF NOT EXISTS "role" TEXT NOT NULL DEFAULT 'viewer';
+ALTER TABLE api_key ADD CONSTRAINT role_constraint CHECK ("role" IN ('viewer', 'editor', 'admin'));
+-- NULL regions means access to cars of all regions
+ALTER TABLE api_key ADD COLUMN IF NOT EXISTS regions TEXT[];
+
+-- Keys created before roles had full access
+UPDATE api_key SET "role" = 'admin';
+
+ALTER TABLE car ADD COLUMN IF NOT EXISTS region TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS car_region_idx ON car(region);
+
+CREATE OR REPLACE FUNCTION car_change_notify() RETURNS trigger AS $$
+DECLARE
+	r car%ROWTYPE;
+	car_owner JSONB;
+	new_change_id BIGINT;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		r := OLD;
+	ELSE
+		r := NEW;
+	END IF;
+
+	-- Owner can be already gone when deletion cascades from person
+	SELECT jsonb_build_object('personId', p.person_id, 'name', p."name", 'surname', p.surname, 'patronymic', p.patronymic)
+	INTO car_owner FROM person p WHERE p.person_id = r."owner";
+
+	INSERT INTO car_change(car_id, op, car)
+	VALUES (r.car_id, lower(TG_OP), jsonb_build_object(
+		'carId', r.car_id,
+		'regNum', r.reg_num,
+		'mark', r.mark,
+		'model', r.model,
+		'year', r."year",
+		'region', r.region,
+		'owner', COALESCE(car_owner, jsonb_build_object('personId', r."owner"))
+	))
+	RETURNING change_id INTO new_change_id;
+
+	PERFORM pg_notify('car_change', new_change_id::text);
+
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,64 @@
+-- Restore change payload without tenant
+CREATE OR REPLACE FUNCTION car_change_notify() RETURNS trigger AS $$
+DECLARE
+	r car%ROWTYPE;
+	car_owner JSONB;
+	new_change_id BIGINT;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		r := OLD;
+	ELSE
+		r := NEW;
+	END IF;
+
+	-- Owner can be already gone when deletion cascades from person
+	SELECT jsonb_build_object('personId', p.person_id, 'name', p."name", 'surname', p.surname, 'patronymic', p.patronymic)
+	INTO car_owner FROM person p WHERE p.person_id = r."owner";
+
+	INSERT INTO car_change(car_id, op, car)
+	VALUES (r.car_id, lower(TG_OP), jsonb_build_object(
+		'carId', r.car_id,
+		'regNum', r.reg_num,
+		'mark', r.mark,
+		'model', r.model,
+		'year', r."year",
+		'region', r.region,
+		'owner', COALESCE(car_owner, jsonb_build_object('personId', r."owner"))
+	))
+	RETURNING change_id INTO new_change_id;
+
+	PERFORM pg_notify('car_change', new_change_id::text);
+
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP POLICY IF EXISTS tenant_isolation ON car_change;
+ALTER TABLE car_change NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE car_change DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON car;
+ALTER TABLE car NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE car DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON person;
+ALTER TABLE person NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE person DISABLE ROW LEVEL SECURITY;
+
+DROP INDEX IF EXISTS car_change_tenant_idx;
+DROP INDEX IF EXISTS car_tenant_idx;
+
+ALTER TABLE car DROP CONSTRAINT IF EXISTS car_owner_tenant_fkey;
+ALTER TABLE person DROP CONSTRAINT IF EXISTS person_tenant_constraint;
+ALTER TABLE person DROP CONSTRAINT IF EXISTS fullname_constraint;
+ALTER TABLE person ADD CONSTRAINT fullname_constraint UNIQUE ("name", surname, patronymic);
+
+ALTER TABLE api_key DROP COLUMN IF EXISTS tenant_id;
+
+-- Role itself is left: it can be shared by other databases of the cluster
+REVOKE CREATE ON SCHEMA public FROM catalog_platform;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE USAGE, SELECT ON SEQUENCES FROM catalog_platform;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE SELECT, INSERT, UPDATE, DELETE ON TABLES FROM catalog_platform;
+REVOKE USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public FROM catalog_platform;
+REVOKE SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public FROM catalog_platform;
+ALTER TABLE car_change DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE car DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE person DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,100 @@
+-- Existing records belong to the first depot
+ALTER TABLE person ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE person ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE car ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE car ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE car_change ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE car_change ALTER COLUMN tenant_id DROP DEFAULT;
+-- NULL tenant means key of platform administrator choosing tenant by X-Tenant-ID header
+ALTER TABLE api_key ADD COLUMN IF NOT EXISTS tenant_id TEXT;
+UPDATE api_key SET tenant_id = 'default';
+
+-- Persons are unique within tenant
+ALTER TABLE person DROP CONSTRAINT IF EXISTS fullname_constraint;
+ALTER TABLE person ADD CONSTRAINT fullname_constraint UNIQUE (tenant_id, "name", surname, patronymic);
+
+-- Car can't be owned by person of another tenant
+ALTER TABLE person ADD CONSTRAINT person_tenant_constraint UNIQUE (tenant_id, person_id);
+ALTER TABLE car ADD CONSTRAINT car_owner_tenant_fkey FOREIGN KEY (tenant_id, "owner")
+	REFERENCES person(tenant_id, person_id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS car_tenant_idx ON car(tenant_id, car_id);
+CREATE INDEX IF NOT EXISTS car_change_tenant_idx ON car_change(tenant_id, change_id);
+
+-- Platform role reads and changes rows of all tenants: change feed, metrics, stats refresh
+-- and maintenance. Service switches to it with SET ROLE only for such work. Creating role
+-- needs CREATEROLE, without it DBA creates the role before migration
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'catalog_platform') THEN
+		CREATE ROLE catalog_platform NOLOGIN BYPASSRLS;
+	END IF;
+	IF NOT pg_has_role(current_user, 'catalog_platform', 'MEMBER') THEN
+		EXECUTE format('GRANT catalog_platform TO %I', current_user);
+	END IF;
+EXCEPTION WHEN insufficient_privilege THEN
+	RAISE EXCEPTION 'role catalog_platform is missing: run CREATE ROLE catalog_platform NOLOGIN BYPASSRLS; GRANT catalog_platform TO %;', current_user;
+END;
+$$;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO catalog_platform;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO catalog_platform;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO catalog_platform;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO catalog_platform;
+-- Platform role owns materialized views, it must be able to create them
+GRANT CREATE ON SCHEMA public TO catalog_platform;
+
+-- Row level security as defense in depth. Application sets app.tenant_id in every tenant
+-- transaction, unset value (migrations included) matches no rows, only platform role sees
+-- all of them. Policies don't apply to superusers, so service must connect as ordinary role
+ALTER TABLE person ENABLE ROW LEVEL SECURITY;
+ALTER TABLE person FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON person
+	USING (tenant_id = current_setting('app.tenant_id', true))
+	WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE car ENABLE ROW LEVEL SECURITY;
+ALTER TABLE car FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON car
+	USING (tenant_id = current_setting('app.tenant_id', true))
+	WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE car_change ENABLE ROW LEVEL SECURITY;
+ALTER TABLE car_change FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON car_change
+	USING (tenant_id = current_setting('app.tenant_id', true))
+	WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+CREATE OR REPLACE FUNCTION car_change_notify() RETURNS trigger AS $$
+DECLARE
+	r car%ROWTYPE;
+	car_owner JSONB;
+	new_change_id BIGINT;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		r := OLD;
+	ELSE
+		r := NEW;
+	END IF;
+
+	-- Owner can be already gone when deletion cascades from person
+	SELECT jsonb_build_object('personId', p.person_id, 'name', p."name", 'surname', p.surname, 'patronymic', p.patronymic)
+	INTO car_owner FROM person p WHERE p.person_id = r."owner";
+
+	INSERT INTO car_change(car_id, op, car, tenant_id)
+	VALUES (r.car_id, lower(TG_OP), jsonb_build_object(
+		'carId', r.car_id,
+		'regNum', r.reg_num,
+		'mark', r.mark,
+		'model', r.model,
+		'year', r."year",
+		'region', r.region,
+		'owner', COALESCE(car_owner, jsonb_build_object('personId', r."owner"))
+	), r.tenant_id)
+	RETURNING change_id INTO new_change_id;
+
+	PERFORM pg_notify('car_change', new_change_id::text);
+
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,7 @@
+-- Token buckets shared by all service instances
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_bucket(
+	bucket_key TEXT PRIMARY KEY,
+	tokens DOUBLE PRECISION NOT NULL,
+	allowed BOOLEAN NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS stats_refresh;
+DROP MATERIALIZED VIEW IF EXISTS car_stats;
+DROP INDEX IF EXISTS car_created_at_idx;
+ALTER TABLE car DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,31 @@
+-- Creation time of cars added before this migration is unknown, they get migration time
+ALTER TABLE car ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS car_created_at_idx ON car(tenant_id, created_at);
+
+-- Snapshot of car counts by every field stats filter and group by, except car id and
+-- registration number. Unknown year is 0, so unique index covers all rows as
+-- REFRESH CONCURRENTLY requires. Materialized views have no row level security,
+-- stats queries always filter by tenant_id
+CREATE MATERIALIZED VIEW IF NOT EXISTS car_stats AS
+	SELECT tenant_id, region, mark, model, COALESCE("year", 0) AS "year", "owner",
+		date_trunc('month', created_at) AS created_month, count(car_id)::INT AS cars
+	FROM car
+	GROUP BY tenant_id, region, mark, model, COALESCE("year", 0), "owner", date_trunc('month', created_at)
+	WITH NO DATA;
+
+CREATE UNIQUE INDEX IF NOT EXISTS car_stats_key_idx
+	ON car_stats(tenant_id, region, mark, model, "year", "owner", created_month);
+
+-- Refresh runs the view query as its owner, so the owner must see all tenants
+ALTER MATERIALIZED VIEW car_stats OWNER TO catalog_platform;
+REFRESH MATERIALIZED VIEW car_stats;
+
+-- Time of the last refresh of materialized views
+CREATE TABLE IF NOT EXISTS stats_refresh(
+	view_name TEXT PRIMARY KEY,
+	refreshed_at TIMESTAMPTZ NOT NULL
+);
+
+INSERT INTO stats_refresh(view_name, refreshed_at) VALUES ('car_stats', now())
+	ON CONFLICT (view_name) DO UPDATE SET refreshed_at = EXCLUDED.refreshed_at;
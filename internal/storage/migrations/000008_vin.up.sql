@@ -0,0 +1,39 @@
+-- VIN is optional, cars added before this migration have none
+ALTER TABLE car ADD COLUMN IF NOT EXISTS vin TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS car_vin_idx ON car(tenant_id, vin) WHERE vin IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION car_change_notify() RETURNS trigger AS $$
+DECLARE
+	r car%ROWTYPE;
+	car_owner JSONB;
+	new_change_id BIGINT;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		r := OLD;
+	ELSE
+		r := NEW;
+	END IF;
+
+	-- Owner can be already gone when deletion cascades from person
+	SELECT jsonb_build_object('personId', p.person_id, 'name', p."name", 'surname', p.surname, 'patronymic', p.patronymic)
+	INTO car_owner FROM person p WHERE p.person_id = r."owner";
+
+	INSERT INTO car_change(car_id, op, car, tenant_id)
+	VALUES (r.car_id, lower(TG_OP), jsonb_build_object(
+		'carId', r.car_id,
+		'regNum', r.reg_num,
+		'mark', r.mark,
+		'model', r.model,
+		'year', r."year",
+		'region', r.region,
+		'vin', r.vin,
+		'owner', COALESCE(car_owner, jsonb_build_object('personId', r."owner"))
+	), r.tenant_id)
+	RETURNING change_id INTO new_change_id;
+
+	PERFORM pg_notify('car_change', new_change_id::text);
+
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS attachment;
+DROP FUNCTION IF EXISTS attachment_collect_blobs();
+DROP TABLE IF EXISTS blob_garbage;
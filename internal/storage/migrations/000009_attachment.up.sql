@@ -0,0 +1,48 @@
+CREATE TABLE IF NOT EXISTS attachment(
+	attachment_id SERIAL PRIMARY KEY,
+	car_id INT NOT NULL REFERENCES car(car_id) ON DELETE CASCADE,
+	tenant_id TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	file_name TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	"size" BIGINT NOT NULL,
+	blob_key TEXT NOT NULL,
+	-- NULL if thumbnail can't be made, e.g. for documents
+	thumbnail_key TEXT,
+	created_by TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE attachment ADD CONSTRAINT attachment_kind_constraint
+	CHECK (kind IN ('photo', 'registration', 'inspection', 'other'));
+
+CREATE INDEX IF NOT EXISTS attachment_car_idx ON attachment(tenant_id, car_id);
+
+ALTER TABLE attachment ENABLE ROW LEVEL SECURITY;
+ALTER TABLE attachment FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON attachment
+	USING (tenant_id = current_setting('app.tenant_id', true))
+	WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+-- Blobs of deleted attachments wait here until they are removed from blob storage.
+-- Keys are random, so table needs no tenant
+CREATE TABLE IF NOT EXISTS blob_garbage(
+	blob_key TEXT PRIMARY KEY,
+	deleted_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Attachments are deleted with their car too, so blobs are collected by trigger
+CREATE OR REPLACE FUNCTION attachment_collect_blobs() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO blob_garbage(blob_key) VALUES (OLD.blob_key) ON CONFLICT DO NOTHING;
+	IF OLD.thumbnail_key IS NOT NULL THEN
+		INSERT INTO blob_garbage(blob_key) VALUES (OLD.thumbnail_key) ON CONFLICT DO NOTHING;
+	END IF;
+
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER attachment_blob_trigger
+AFTER DELETE ON attachment
+FOR EACH ROW EXECUTE FUNCTION attachment_collect_blobs();
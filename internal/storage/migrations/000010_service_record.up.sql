@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS service_record(
+	record_id SERIAL PRIMARY KEY,
+	car_id INT NOT NULL REFERENCES car(car_id) ON DELETE CASCADE,
+	tenant_id TEXT NOT NULL,
+	service_date DATE NOT NULL,
+	odometer INT NOT NULL,
+	work_type TEXT NOT NULL,
+	cost NUMERIC(12, 2) NOT NULL DEFAULT 0,
+	notes TEXT NOT NULL DEFAULT '',
+	created_by TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE service_record ADD CONSTRAINT service_record_odometer_constraint CHECK (odometer >= 0);
+ALTER TABLE service_record ADD CONSTRAINT service_record_cost_constraint CHECK (cost >= 0);
+
+-- Catalog filter looks for the last service of every car
+CREATE INDEX IF NOT EXISTS service_record_car_idx ON service_record(car_id, service_date);
+CREATE INDEX IF NOT EXISTS service_record_tenant_idx ON service_record(tenant_id, car_id);
+
+ALTER TABLE service_record ENABLE ROW LEVEL SECURITY;
+ALTER TABLE service_record FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON service_record
+	USING (tenant_id = current_setting('app.tenant_id', true))
+	WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
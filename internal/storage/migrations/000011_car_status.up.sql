@@ -0,0 +1,60 @@
+-- Cars added before this migration are in use
+ALTER TABLE car ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE car ADD CONSTRAINT car_status_constraint CHECK (status IN ('active', 'in_repair', 'sold', 'scrapped'));
+
+CREATE INDEX IF NOT EXISTS car_status_idx ON car(tenant_id, status);
+
+CREATE TABLE IF NOT EXISTS car_status_history(
+	history_id SERIAL PRIMARY KEY,
+	car_id INT NOT NULL REFERENCES car(car_id) ON DELETE CASCADE,
+	tenant_id TEXT NOT NULL,
+	from_status TEXT NOT NULL,
+	to_status TEXT NOT NULL,
+	reason TEXT NOT NULL DEFAULT '',
+	changed_by TEXT NOT NULL,
+	changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS car_status_history_car_idx ON car_status_history(car_id, history_id);
+
+ALTER TABLE car_status_history ENABLE ROW LEVEL SECURITY;
+ALTER TABLE car_status_history FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON car_status_history
+	USING (tenant_id = current_setting('app.tenant_id', true))
+	WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+CREATE OR REPLACE FUNCTION car_change_notify() RETURNS trigger AS $$
+DECLARE
+	r car%ROWTYPE;
+	car_owner JSONB;
+	new_change_id BIGINT;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		r := OLD;
+	ELSE
+		r := NEW;
+	END IF;
+
+	-- Owner can be already gone when deletion cascades from person
+	SELECT jsonb_build_object('personId', p.person_id, 'name', p."name", 'surname', p.surname, 'patronymic', p.patronymic)
+	INTO car_owner FROM person p WHERE p.person_id = r."owner";
+
+	INSERT INTO car_change(car_id, op, car, tenant_id)
+	VALUES (r.car_id, lower(TG_OP), jsonb_build_object(
+		'carId', r.car_id,
+		'regNum', r.reg_num,
+		'mark', r.mark,
+		'model', r.model,
+		'year', r."year",
+		'region', r.region,
+		'vin', r.vin,
+		'status', r.status,
+		'owner', COALESCE(car_owner, jsonb_build_object('personId', r."owner"))
+	), r.tenant_id)
+	RETURNING change_id INTO new_change_id;
+
+	PERFORM pg_notify('car_change', new_change_id::text);
+
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS tag(
+	tag_id SERIAL PRIMARY KEY,
+	tenant_id TEXT NOT NULL,
+	"name" TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE tag ADD CONSTRAINT tag_name_constraint UNIQUE (tenant_id, "name");
+
+CREATE TABLE IF NOT EXISTS car_tag(
+	car_id INT NOT NULL REFERENCES car(car_id) ON DELETE CASCADE,
+	tag_id INT NOT NULL REFERENCES tag(tag_id) ON DELETE CASCADE,
+	tenant_id TEXT NOT NULL,
+	created_by TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (car_id, tag_id)
+);
+
+-- Catalog filter looks for cars of tags
+CREATE INDEX IF NOT EXISTS car_tag_tag_idx ON car_tag(tag_id, car_id);
+
+ALTER TABLE tag ENABLE ROW LEVEL SECURITY;
+ALTER TABLE tag FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON tag
+	USING (tenant_id = current_setting('app.tenant_id', true))
+	WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE car_tag ENABLE ROW LEVEL SECURITY;
+ALTER TABLE car_tag FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON car_tag
+	USING (tenant_id = current_setting('app.tenant_id', true))
+	WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
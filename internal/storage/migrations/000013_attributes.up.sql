@@ -0,0 +1,65 @@
+-- Attributes of car are validated against definitions of its tenant by application
+ALTER TABLE car ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+-- Catalog filters attributes by containment, c.attributes @> '{"color": "red"}'
+CREATE INDEX IF NOT EXISTS car_attributes_idx ON car USING GIN (attributes jsonb_path_ops);
+
+CREATE TABLE IF NOT EXISTS attribute_def(
+	tenant_id TEXT NOT NULL,
+	"name" TEXT NOT NULL,
+	"type" TEXT NOT NULL,
+	required BOOLEAN NOT NULL DEFAULT false,
+	enum_values TEXT[],
+	pattern TEXT NOT NULL DEFAULT '',
+	min_value DOUBLE PRECISION,
+	max_value DOUBLE PRECISION,
+	updated_by TEXT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (tenant_id, "name")
+);
+
+ALTER TABLE attribute_def ADD CONSTRAINT attribute_def_type_constraint
+	CHECK ("type" IN ('string', 'number', 'integer', 'boolean'));
+
+ALTER TABLE attribute_def ENABLE ROW LEVEL SECURITY;
+ALTER TABLE attribute_def FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON attribute_def
+	USING (tenant_id = current_setting('app.tenant_id', true))
+	WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+CREATE OR REPLACE FUNCTION car_change_notify() RETURNS trigger AS $$
+DECLARE
+	r car%ROWTYPE;
+	car_owner JSONB;
+	new_change_id BIGINT;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		r := OLD;
+	ELSE
+		r := NEW;
+	END IF;
+
+	-- Owner can be already gone when deletion cascades from person
+	SELECT jsonb_build_object('personId', p.person_id, 'name', p."name", 'surname', p.surname, 'patronymic', p.patronymic)
+	INTO car_owner FROM person p WHERE p.person_id = r."owner";
+
+	INSERT INTO car_change(car_id, op, car, tenant_id)
+	VALUES (r.car_id, lower(TG_OP), jsonb_build_object(
+		'carId', r.car_id,
+		'regNum', r.reg_num,
+		'mark', r.mark,
+		'model', r.model,
+		'year', r."year",
+		'region', r.region,
+		'vin', r.vin,
+		'status', r.status,
+		'attributes', r.attributes,
+		'owner', COALESCE(car_owner, jsonb_build_object('personId', r."owner"))
+	), r.tenant_id)
+	RETURNING change_id INTO new_change_id;
+
+	PERFORM pg_notify('car_change', new_change_id::text);
+
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
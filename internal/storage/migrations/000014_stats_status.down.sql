@@ -0,0 +1,17 @@
+DROP MATERIALIZED VIEW IF EXISTS car_stats;
+
+CREATE MATERIALIZED VIEW car_stats AS
+	SELECT tenant_id, region, mark, model, COALESCE("year", 0) AS "year", "owner",
+		date_trunc('month', created_at) AS created_month, count(car_id)::INT AS cars
+	FROM car
+	GROUP BY tenant_id, region, mark, model, COALESCE("year", 0), "owner", date_trunc('month', created_at)
+	WITH NO DATA;
+
+CREATE UNIQUE INDEX IF NOT EXISTS car_stats_key_idx
+	ON car_stats(tenant_id, region, mark, model, "year", "owner", created_month);
+
+-- View is filled by owner seeing all tenants, see 000007
+ALTER MATERIALIZED VIEW car_stats OWNER TO catalog_platform;
+REFRESH MATERIALIZED VIEW car_stats;
+
+UPDATE stats_refresh SET refreshed_at = now() WHERE view_name = 'car_stats';
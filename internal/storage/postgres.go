@@ -1,46 +1,118 @@
-package postgres
-
-import (
-	"database/sql"
-	"fmt"
-
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/lib/pq"
-)
-
-const (
-	migrationPath = "C:/Users/Leonid/Desktop/catalog/internal/storage/migrations"
-)
-
-type Storage struct {
-	DB *sql.DB
-}
-
-func New(driver, info string) (*Storage, error) {
-	const op = "storage.postgres.NewStorage" // Current function
-
-	DB, err := sql.Open(driver, info) // Connet to DB
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
-	}
-
-	return &Storage{DB: DB}, nil
-}
-
-func (s *Storage) UpMigration(info string) error {
-	const op = "storage.postgres.UpMigration"
-
-	m, err := migrate.New(
-		"file://"+migrationPath,
-		"postgres://"+info)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-	if err := m.Up(); err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-
-	return nil
-}
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+var (
+	ErrNoTenant = errors.New("tenant is not set")
+	// ErrSchemaOutdated is returned when database schema differs from migrations of this build
+	ErrSchemaOutdated = errors.New("schema is not up to date")
+)
+
+type Storage struct {
+	DB *sql.DB
+}
+
+func New(driver, info string) (*Storage, error) {
+	const op = "storage.postgres.NewStorage" // Current function
+
+	DB, err := sql.Open(driver, info) // Connet to DB
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{DB: DB}, nil
+}
+
+// Connect pings DB until it answers or timeout expires. Delay between attempts
+// doubles up to 5 seconds, onRetry is called before every next attempt
+func (s *Storage) Connect(ctx context.Context, timeout time.Duration, onRetry func(err error, delay time.Duration)) error {
+	const op = "storage.postgres.Connect"
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	delay := 200 * time.Millisecond
+	for {
+		err := s.DB.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		if onRetry != nil {
+			onRetry(err, delay)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", op, err)
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, 5*time.Second)
+	}
+}
+
+// InTenant runs fn in transaction bound to tenant by app.tenant_id setting,
+// which is checked by row level security policies. Transaction is committed if fn succeeds
+func (s *Storage) InTenant(ctx context.Context, tenantID string, fn func(tx *Tx) error) error {
+	const op = "storage.postgres.InTenant"
+
+	if tenantID == "" {
+		return fmt.Errorf("%s: %w", op, ErrNoTenant)
+	}
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT set_config('app.tenant_id', $1, true);`, tenantID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PlatformRole bypasses row level security, it's granted to service role by migrations
+const PlatformRole = "catalog_platform"
+
+// AsPlatform runs fn in transaction of PlatformRole seeing rows of all tenants. It's for
+// change feed, metrics and maintenance only. Transaction is committed if fn succeeds
+func (s *Storage) AsPlatform(ctx context.Context, fn func(tx *Tx) error) error {
+	const op = "storage.postgres.AsPlatform"
+
+	tx, err := s.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SET LOCAL ROLE ` + PlatformRole + `;`); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
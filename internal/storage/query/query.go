@@ -0,0 +1,169 @@
+// Package query builds parameterized SQL statements. Values never get into SQL text,
+// placeholders $1, $2... are numbered in order arguments are added to statement
+package query
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ErrNoFields is returned by Set without fields, UPDATE can't have empty SET list
+var ErrNoFields = errors.New("no fields to set")
+
+// Field is column with value of struct field
+type Field struct {
+	Column string
+	Value  interface{}
+}
+
+// Fields returns non-zero fields of struct v tagged by db, e.g. `db:"reg_num"`, in order
+// of declaration. v can be pointer. Fields of nested structs are not included
+func Fields(v interface{}) []Field {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rt := rv.Type()
+
+	var fields []Field
+	for i := 0; i < rt.NumField(); i++ {
+		column := rt.Field(i).Tag.Get("db")
+		if column == "" || column == "-" || rv.Field(i).IsZero() {
+			continue
+		}
+		fields = append(fields, Field{Column: column, Value: rv.Field(i).Interface()})
+	}
+	return fields
+}
+
+// Column returns quoted column name with optional table alias, e.g. c."year"
+func Column(alias, column string) string {
+	if alias == "" {
+		return pq.QuoteIdentifier(column)
+	}
+	return alias + "." + pq.QuoteIdentifier(column)
+}
+
+// Cond is conjunction of conditions. Values are bound when Cond is added to Query,
+// so the same Cond can be used by several queries, e.g. count and page ones
+type Cond struct {
+	exprs []expr
+}
+
+// expr is SQL with ? in place of every argument
+type expr struct {
+	sql  string
+	args []interface{}
+}
+
+// Add adds condition, ? in sql stands for next argument of args
+func (c *Cond) Add(sql string, args ...interface{}) *Cond {
+	c.exprs = append(c.exprs, expr{sql: sql, args: args})
+	return c
+}
+
+// Eq adds condition column = value, alias is table alias or empty string
+func (c *Cond) Eq(alias, column string, value interface{}) *Cond {
+	return c.Add(Column(alias, column)+" = ?", value)
+}
+
+// Fields adds equality conditions for every field
+func (c *Cond) Fields(alias string, fields []Field) *Cond {
+	for _, f := range fields {
+		c.Eq(alias, f.Column, f.Value)
+	}
+	return c
+}
+
+func (c *Cond) Empty() bool {
+	return len(c.exprs) == 0
+}
+
+// Query is SQL statement with its arguments
+type Query struct {
+	sql  strings.Builder
+	args []interface{}
+}
+
+// New starts query with sql without arguments, e.g. "SELECT ... FROM car"
+func New(sql string) *Query {
+	q := &Query{}
+	q.sql.WriteString(sql)
+	return q
+}
+
+// Arg adds argument and returns its placeholder
+func (q *Query) Arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// Write appends sql, ? in it stands for next argument of args.
+// Count mismatch of ? and args is programming error and panics
+func (q *Query) Write(sql string, args ...interface{}) *Query {
+	if n := strings.Count(sql, "?"); n != len(args) {
+		panic("query: " + strconv.Itoa(n) + " placeholders with " + strconv.Itoa(len(args)) + " arguments in " + sql)
+	}
+	for _, arg := range args {
+		before, after, _ := strings.Cut(sql, "?")
+		q.sql.WriteString(before)
+		q.sql.WriteString(q.Arg(arg))
+		sql = after
+	}
+	q.sql.WriteString(sql)
+	return q
+}
+
+// Where appends WHERE clause, nothing for empty c
+func (q *Query) Where(c *Cond) *Query {
+	for i, e := range c.exprs {
+		if i == 0 {
+			q.Write(" WHERE ")
+		} else {
+			q.Write(" AND ")
+		}
+		q.Write(e.sql, e.args...)
+	}
+	return q
+}
+
+// Set appends SET list of UPDATE
+func (q *Query) Set(fields []Field) (*Query, error) {
+	if len(fields) == 0 {
+		return q, ErrNoFields
+	}
+	for i, f := range fields {
+		if i == 0 {
+			q.Write(" SET ")
+		} else {
+			q.Write(", ")
+		}
+		q.Write(Column("", f.Column)+" = ?", f.Value)
+	}
+	return q, nil
+}
+
+// OrderBy appends ORDER BY clause by quoted column
+func (q *Query) OrderBy(alias, column string, desc bool) *Query {
+	q.Write(" ORDER BY " + Column(alias, column))
+	if desc {
+		q.Write(" DESC")
+	}
+	return q
+}
+
+// Limit appends LIMIT and OFFSET clauses
+func (q *Query) Limit(limit, offset int) *Query {
+	return q.Write(" LIMIT ? OFFSET ?", limit, offset)
+}
+
+// String returns SQL of query
+func (q *Query) String() string {
+	return q.sql.String() + ";"
+}
+
+// Args returns arguments in order of placeholders
+func (q *Query) Args() []interface{} {
+	return q.args
+}
@@ -0,0 +1,87 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type filter struct {
+	ID      int    `db:"id"`
+	Name    string `db:"name"`
+	Year    int    `db:"year"`
+	Ignored string `db:"-"`
+	Plain   string
+	Nested  struct {
+		Field string `db:"field"`
+	}
+}
+
+func TestFields(t *testing.T) {
+	f := filter{Name: "Lada", Year: 2020, Ignored: "x", Plain: "x"}
+	f.Nested.Field = "x"
+
+	want := []Field{{Column: "name", Value: "Lada"}, {Column: "year", Value: 2020}}
+	if got := Fields(&f); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Fields(&f) = %v, want %v", got, want)
+	}
+	if got := Fields(f); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Fields(f) = %v, want %v", got, want)
+	}
+	if got := Fields(filter{}); len(got) != 0 {
+		t.Fatalf("fields of empty filter = %v", got)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	cond := (&Cond{}).Eq("c", "tenant_id", "depot")
+	cond.Fields("c", Fields(filter{ID: 7, Year: 2020}))
+	cond.Add("c.region = ANY(?)", []string{"moscow"})
+
+	// The same condition is bound in every query from $1
+	count := New("SELECT count(*) FROM car c").Where(cond)
+	if want := `SELECT count(*) FROM car c WHERE c."tenant_id" = $1 AND c."id" = $2 AND c."year" = $3 AND c.region = ANY($4);`; count.String() != want {
+		t.Fatalf("count query = %s, want %s", count, want)
+	}
+
+	page := New("SELECT * FROM car c").Where(cond).OrderBy("c", "car_id", true).Limit(2, 4)
+	if want := `SELECT * FROM car c WHERE c."tenant_id" = $1 AND c."id" = $2 AND c."year" = $3 AND c.region = ANY($4) ORDER BY c."car_id" DESC LIMIT $5 OFFSET $6;`; page.String() != want {
+		t.Fatalf("page query = %s, want %s", page, want)
+	}
+	wantArgs := []interface{}{"depot", 7, 2020, []string{"moscow"}, 2, 4}
+	if !reflect.DeepEqual(page.Args(), wantArgs) {
+		t.Fatalf("args = %v, want %v", page.Args(), wantArgs)
+	}
+
+	if got := New("SELECT 1").Where(&Cond{}).String(); got != "SELECT 1;" {
+		t.Fatalf("query with empty condition = %s", got)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	q, err := New("UPDATE car").Set(Fields(filter{Name: "Kia", Year: 2001}))
+	if err != nil {
+		t.Fatalf("failed to set fields: %v", err)
+	}
+	q.Where((&Cond{}).Eq("", "id", 5))
+
+	if want := `UPDATE car SET "name" = $1, "year" = $2 WHERE "id" = $3;`; q.String() != want {
+		t.Fatalf("query = %s, want %s", q, want)
+	}
+	if want := []interface{}{"Kia", 2001, 5}; !reflect.DeepEqual(q.Args(), want) {
+		t.Fatalf("args = %v, want %v", q.Args(), want)
+	}
+
+	if _, err := New("UPDATE car").Set(nil); !errors.Is(err, ErrNoFields) {
+		t.Fatalf("empty SET: err = %v, want ErrNoFields", err)
+	}
+}
+
+func TestWritePlaceholdersMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("mismatch of placeholders and arguments doesn't panic")
+		}
+	}()
+	New("SELECT").Write(" ? + ?", 1)
+}
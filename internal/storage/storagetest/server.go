@@ -0,0 +1,125 @@
+package storagetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// PGBinEnv is directory with initdb and pg_ctl of PostgreSQL used for local test cluster
+const PGBinEnv = "CATALOG_TEST_PG_BIN"
+
+// server is PostgreSQL cluster started for tests
+type server struct {
+	dir   string
+	pgCtl string
+}
+
+// startServer initializes cluster in temporary directory and starts it on free local port.
+// Returns DSN of ordinary role owning test database, so row level security is enforced
+func startServer() (*server, string, error) {
+	binDir, err := findBinDir()
+	if err != nil {
+		return nil, "", err
+	}
+	if os.Geteuid() == 0 {
+		return nil, "", errors.New("PostgreSQL can't be run by root")
+	}
+
+	dir, err := os.MkdirTemp("", "catalog-pg-")
+	if err != nil {
+		return nil, "", err
+	}
+	s := &server{dir: dir, pgCtl: filepath.Join(binDir, "pg_ctl")}
+	data := filepath.Join(dir, "data")
+
+	out, err := exec.Command(filepath.Join(binDir, "initdb"), "-D", data, "-U", "postgres", "-A", "trust",
+		"-E", "UTF8", "--no-sync").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, "", fmt.Errorf("initdb: %w: %s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, "", err
+	}
+	opts := fmt.Sprintf("-p %d -h 127.0.0.1 -k %s -F -c fsync=off -c full_page_writes=off", port, dir)
+	out, err = exec.Command(s.pgCtl, "-D", data, "-l", filepath.Join(dir, "log"), "-o", opts, "-w", "start").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, "", fmt.Errorf("pg_ctl start: %w: %s", err, out)
+	}
+
+	hostPort := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	if err := createDatabase("postgres://postgres@" + hostPort + "/postgres?sslmode=disable"); err != nil {
+		s.stop()
+		return nil, "", err
+	}
+
+	return s, "postgres://catalog@" + hostPort + "/catalog?sslmode=disable", nil
+}
+
+func (s *server) stop() {
+	exec.Command(s.pgCtl, "-D", filepath.Join(s.dir, "data"), "-m", "immediate", "stop").Run()
+	os.RemoveAll(s.dir)
+}
+
+// findBinDir looks for initdb in PGBinEnv, PATH and Debian style /usr/lib/postgresql/<version>/bin
+func findBinDir() (string, error) {
+	if dir := os.Getenv(PGBinEnv); dir != "" {
+		return dir, nil
+	}
+	if path, err := exec.LookPath("initdb"); err == nil {
+		return filepath.Dir(path), nil
+	}
+	paths, _ := filepath.Glob("/usr/lib/postgresql/*/bin/initdb")
+	if len(paths) == 0 {
+		return "", errors.New("initdb is not found, set " + PGBinEnv)
+	}
+	// Use the latest version, plain string order would put 9 after 16
+	sort.Slice(paths, func(i, j int) bool {
+		return len(paths[i]) < len(paths[j]) || len(paths[i]) == len(paths[j]) && paths[i] < paths[j]
+	})
+	return filepath.Dir(paths[len(paths)-1]), nil
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+func createDatabase(superDSN string) error {
+	db, err := sql.Open("postgres", superDSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, query := range []string{
+		`CREATE ROLE catalog LOGIN;`,
+		`CREATE ROLE catalog_platform NOLOGIN BYPASSRLS;`,
+		`GRANT catalog_platform TO catalog;`,
+		`CREATE DATABASE catalog OWNER catalog;`,
+	} {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("%s: %w", query, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package storagetest
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+// txConnector gives database/sql the only connection, which works inside transaction
+// rolled back on close. Transactions of code under test become savepoints of it,
+// so tests see their own changes and leave nothing behind
+type txConnector struct {
+	dsn string
+
+	mu   sync.Mutex
+	conn *txConn
+}
+
+func (c *txConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil && !c.conn.closed {
+		return c.conn, nil
+	}
+
+	connector, err := pq.NewConnector(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := conn.(driver.ConnBeginTx).BeginTx(ctx, driver.TxOptions{})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c.conn = &txConn{conn: conn, tx: tx}
+	return c.conn, nil
+}
+
+func (c *txConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// txConn is pq connection with open outer transaction
+type txConn struct {
+	conn driver.Conn
+	tx   driver.Tx
+	// savepoints is count of nested transactions, names of savepoints are sp_1, sp_2...
+	savepoints int
+	closed     bool
+}
+
+// Close rolls back everything test has done
+func (c *txConn) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return errors.Join(c.tx.Rollback(), c.conn.Close())
+}
+
+func (c *txConn) Prepare(query string) (driver.Stmt, error) {
+	return c.conn.Prepare(query)
+}
+
+func (c *txConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+}
+
+func (c *txConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c *txConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (c *txConn) Ping(ctx context.Context) error {
+	return c.conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *txConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *txConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.savepoints++
+	sp := &savepoint{conn: c, name: "sp_" + strconv.Itoa(c.savepoints)}
+	if err := sp.exec(ctx, "SAVEPOINT "+sp.name); err != nil {
+		c.savepoints--
+		return nil, err
+	}
+	return sp, nil
+}
+
+// savepoint stands for transaction of code under test
+type savepoint struct {
+	conn *txConn
+	name string
+}
+
+func (sp *savepoint) Commit() error {
+	return sp.end("RELEASE SAVEPOINT " + sp.name)
+}
+
+func (sp *savepoint) Rollback() error {
+	return sp.end("ROLLBACK TO SAVEPOINT " + sp.name)
+}
+
+// end finishes savepoint and resets settings, as real transaction end drops
+// SET LOCAL and set_config(..., true) values, e.g. app.tenant_id. RESET ALL keeps
+// role, so SET LOCAL ROLE of Storage.AsPlatform is reset on its own
+func (sp *savepoint) end(query string) error {
+	sp.conn.savepoints--
+	ctx := context.Background()
+	if err := sp.exec(ctx, query); err != nil {
+		return err
+	}
+	if err := sp.exec(ctx, "RESET ALL"); err != nil {
+		return err
+	}
+	return sp.exec(ctx, "RESET ROLE")
+}
+
+func (sp *savepoint) exec(ctx context.Context, query string) error {
+	_, err := sp.conn.ExecContext(ctx, query, nil)
+	return err
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"catalog/internal/lib/tracing"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exec runs statement with span, child of span in ctx. Same for Query and QueryRow
+func (s *Storage) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startSpan(ctx, query)
+	res, err := s.DB.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+	return res, err
+}
+
+func (s *Storage) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startSpan(ctx, query)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	tracing.End(span, err)
+	return rows, err
+}
+
+func (s *Storage) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startSpan(ctx, query)
+	row := s.DB.QueryRowContext(ctx, query, args...)
+	tracing.End(span, row.Err())
+	return row
+}
+
+// Tx is transaction tracing its statements as children of span in ctx it was started with
+type Tx struct {
+	tx  *sql.Tx
+	ctx context.Context
+}
+
+func (s *Storage) Begin(ctx context.Context) (*Tx, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, ctx: ctx}, nil
+}
+
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startSpan(t.ctx, query)
+	res, err := t.tx.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+	return res, err
+}
+
+func (t *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startSpan(t.ctx, query)
+	rows, err := t.tx.QueryContext(ctx, query, args...)
+	tracing.End(span, err)
+	return rows, err
+}
+
+func (t *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	ctx, span := startSpan(t.ctx, query)
+	row := t.tx.QueryRowContext(ctx, query, args...)
+	tracing.End(span, row.Err())
+	return row
+}
+
+// Prepare prepares statement, e.g. COPY of pq.CopyIn. Span is started on preparation only
+func (t *Tx) Prepare(query string) (*sql.Stmt, error) {
+	ctx, span := startSpan(t.ctx, query)
+	stmt, err := t.tx.PrepareContext(ctx, query)
+	tracing.End(span, err)
+	return stmt, err
+}
+
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// startSpan starts client span named by SQL operation, e.g. "SELECT"
+func startSpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	query = strings.TrimSpace(query)
+	name := "SQL"
+	if fields := strings.Fields(query); len(fields) > 0 {
+		name = strings.ToUpper(fields[0])
+	}
+	return tracing.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBStatement(query),
+		))
+}